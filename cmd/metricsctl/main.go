@@ -0,0 +1,53 @@
+// Утилита командной строки для чтения и записи метрик через JSON API сервера
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/metricsctl"
+	"github.com/PiskarevSA/go-advanced/internal/app/pkg/configreader"
+)
+
+func main() {
+	exitCode := 0
+	defer func() {
+		os.Exit(exitCode)
+	}()
+
+	// the output is for the user, config reading steps are not logged
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+
+	config := metricsctl.NewConfig()
+	err := configreader.Do(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 2
+		return
+	}
+
+	c, err := metricsctl.NewClient(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 2
+		return
+	}
+	ctl, err := metricsctl.NewCtl(c, config.Output, os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 2
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+		syscall.SIGTERM)
+	defer stop()
+	if err := ctl.Run(ctx, config.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+	}
+}
@@ -11,7 +11,12 @@ import (
 	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/push"
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/spool"
 	"github.com/PiskarevSA/go-advanced/internal/app/agent/workers"
+	"github.com/PiskarevSA/go-advanced/internal/app/pkg/reloader"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	rsamiddleware "github.com/PiskarevSA/go-advanced/internal/middleware/rsa"
 )
 
 type Agent struct{}
@@ -60,28 +65,102 @@ func (a *Agent) startWorkers(ctx context.Context, config *Config) error {
 	// Wait group to ensure all goroutines finish before exiting
 	var wg sync.WaitGroup
 
+	histogramBuckets, err := metrics.ParseBuckets(config.HistogramBuckets)
+	if err != nil {
+		return fmt.Errorf("histogram buckets: %w", err)
+	}
+	collectorSpecs, err := metrics.ParseCollectors(config.Collectors)
+	if err != nil {
+		return fmt.Errorf("collectors: %w", err)
+	}
+	collectors, err := metrics.NewCollectors(collectorSpecs,
+		metrics.CollectorOptions{HistogramBuckets: histogramBuckets})
+	if err != nil {
+		return fmt.Errorf("collectors: %w", err)
+	}
+
+	agentID, err := loadOrCreateAgentID(config.AgentIDFile)
+	if err != nil {
+		return fmt.Errorf("agent id: %w", err)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("hostname: %w", err)
+	}
+	slog.Info("[main] agent identity", "agentID", agentID, "hostname", hostname)
+
+	// failed batches are spooled to disk and replayed later
+	var batchSpool *spool.Spool
+	if len(config.SpoolDir) > 0 {
+		batchSpool, err = spool.Open(config.SpoolDir, config.SpoolMaxSize,
+			time.Duration(config.SpoolMaxAge)*time.Second)
+		if err != nil {
+			return fmt.Errorf("spool: %w", err)
+		}
+	}
+
+	// metrics pushed by applications are polled as any other collector
+	if len(config.StatsDAddress) > 0 || len(config.PushSocket) > 0 {
+		pushBuckets, err := metrics.ParseBuckets(config.PushBuckets)
+		if err != nil {
+			return fmt.Errorf("push buckets: %w", err)
+		}
+		receiver := push.NewReceiver(pushBuckets)
+		if len(config.StatsDAddress) > 0 {
+			if err := receiver.ListenStatsD(ctx, &wg, config.StatsDAddress); err != nil {
+				return err
+			}
+		}
+		if len(config.PushSocket) > 0 {
+			if err := receiver.ListenSocket(ctx, &wg, config.PushSocket); err != nil {
+				return err
+			}
+		}
+		collectors = append(collectors, metrics.Collector{Name: "push", Poll: receiver.Poll})
+	}
+
 	// poll metrics periodically
 	pollInterval := time.Duration(config.PollIntervalSec) * time.Second
 	pollerLauncher := workers.NewPollerLauncher(pollInterval, &wg)
-	runtimePollerMetrics := pollerLauncher.StartPollRuntime(ctx)
-	gopsutilPollerMetrics := pollerLauncher.StartPollGopsutil(ctx)
+	pollers := make([]*metrics.Poller, 0, len(collectors)+1)
+	for _, collector := range collectors {
+		pollers = append(pollers, pollerLauncher.StartPollCollector(ctx, collector))
+	}
+	if batchSpool != nil {
+		pollers = append(pollers, pollerLauncher.StartPollSpool(ctx, batchSpool))
+	}
 
 	// schedule metrics for reporting periodically
 	reportInterval := time.Duration(config.ReportIntervalSec) * time.Second
 	schedulerLauncher := workers.NewSchedulerLauncher(
 		reportInterval, &wg)
-	metricsChan := schedulerLauncher.StartScheduler(ctx, []*metrics.Poller{
-		runtimePollerMetrics,
-		gopsutilPollerMetrics,
-	})
+	metricsChan := schedulerLauncher.StartScheduler(ctx, pollers)
 
 	// report metrics to server periodically
+	keys, err := keyring.Load(config.Key, config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+	var encrypter *rsamiddleware.Encrypter // requests are not encrypted if nil
+	if len(config.CryptoKey) > 0 {
+		if encrypter, err = rsamiddleware.NewEncrypter(config.CryptoKey); err != nil {
+			return fmt.Errorf("rsa encrypter: %w", err)
+		}
+	}
 	reporterPool := workers.NewReporterPool(
-		&wg, config.RateLimit, metricsChan, config.ServerAddress, config.Key, config.CryptoKey)
+		&wg, config.RateLimit, metricsChan, config.ServerAddress, keys, encrypter,
+		config.GRPCAddress, agentID, hostname, batchSpool)
 	if err := reporterPool.StartReporters(ctx); err != nil {
 		return fmt.Errorf("start reporters: %w", err)
 	}
 
+	// keys are rotated on SIGHUP without restart
+	reloaders := []reloader.Reloader{keys}
+	if encrypter != nil {
+		reloaders = append(reloaders, encrypter)
+	}
+	reloader.Start(ctx, &wg, reloaders...)
+
 	// Wait for all goroutines to finish
 	wg.Wait()
 	return nil
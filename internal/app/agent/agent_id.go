@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const agentIDSize = 16 // bytes
+
+// loadOrCreateAgentID reads agent id from the file; if the file doesn't exist,
+// new random id is generated and stored to the file, so the agent keeps the
+// same id between restarts
+func loadOrCreateAgentID(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(content))
+		if len(id) == 0 {
+			return "", fmt.Errorf("empty agent id in %v", path)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("read agent id: %w", err)
+	}
+
+	buf := make([]byte, agentIDSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate agent id: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("store agent id: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("store agent id: %w", err)
+	}
+	return id, nil
+}
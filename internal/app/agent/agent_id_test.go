@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrCreateAgentID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "agent_id")
+
+	id, err := loadOrCreateAgentID(path)
+	require.NoError(t, err)
+	assert.Len(t, id, 2*agentIDSize)
+
+	// the same id after restart
+	again, err := loadOrCreateAgentID(path)
+	require.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	require.NoError(t, os.WriteFile(path, []byte("  \n"), 0o644))
+	_, err = loadOrCreateAgentID(path)
+	assert.Error(t, err)
+}
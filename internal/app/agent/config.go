@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
 	"github.com/caarlos0/env/v6"
 )
 
@@ -17,8 +19,19 @@ const (
 	defaultReportIntervalSec = 10
 	defaultServerAddress     = "localhost:8080"
 	defaultKey               = ""
+	defaultKeyFile           = ""
 	defaultRateLimit         = 1
 	defaultCryptoKey         = ""
+	defaultGRPCAddress       = ""
+	defaultHistogramBuckets  = "0.00001,0.00005,0.0001,0.0005,0.001,0.005,0.01"
+	defaultPushBuckets       = "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10"
+	defaultAgentIDFile       = "agent_id"
+	defaultSpoolDir          = ""
+	defaultSpoolMaxSize      = 64 << 20
+	defaultSpoolMaxAge       = 24 * 60 * 60
+	defaultCollectors        = "runtime,mem,cpu"
+	defaultStatsDAddress     = ""
+	defaultPushSocket        = ""
 )
 
 type Config struct {
@@ -27,8 +40,19 @@ type Config struct {
 	ReportIntervalSec int    `env:"REPORT_INTERVAL" json:"report_interval"`
 	ServerAddress     string `env:"ADDRESS" json:"address"`
 	Key               string `env:"KEY" json:"key"`
+	KeyFile           string `env:"KEY_FILE" json:"key_file"`
 	RateLimit         int    `env:"RATE_LIMIT" json:"rate_limit"`
 	CryptoKey         string `env:"CRYPTO_KEY" json:"crypto_key"`
+	GRPCAddress       string `env:"GRPC_ADDRESS" json:"grpc_address"`
+	HistogramBuckets  string `env:"HISTOGRAM_BUCKETS" json:"histogram_buckets"`
+	AgentIDFile       string `env:"AGENT_ID_FILE" json:"agent_id_file"`
+	SpoolDir          string `env:"SPOOL_DIR" json:"spool_dir"`
+	SpoolMaxSize      int64  `env:"SPOOL_MAX_SIZE" json:"spool_max_size"`
+	SpoolMaxAge       int    `env:"SPOOL_MAX_AGE" json:"spool_max_age"`
+	Collectors        string `env:"COLLECTORS" json:"collectors"`
+	StatsDAddress     string `env:"STATSD_ADDRESS" json:"statsd_address"`
+	PushSocket        string `env:"PUSH_SOCKET" json:"push_socket"`
+	PushBuckets       string `env:"PUSH_BUCKETS" json:"push_buckets"`
 }
 
 func NewConfig() *Config {
@@ -38,8 +62,19 @@ func NewConfig() *Config {
 		ReportIntervalSec: defaultReportIntervalSec,
 		ServerAddress:     defaultServerAddress,
 		Key:               defaultKey,
+		KeyFile:           defaultKeyFile,
 		RateLimit:         defaultRateLimit,
 		CryptoKey:         defaultCryptoKey,
+		GRPCAddress:       defaultGRPCAddress,
+		HistogramBuckets:  defaultHistogramBuckets,
+		AgentIDFile:       defaultAgentIDFile,
+		SpoolDir:          defaultSpoolDir,
+		SpoolMaxSize:      defaultSpoolMaxSize,
+		SpoolMaxAge:       defaultSpoolMaxAge,
+		Collectors:        defaultCollectors,
+		StatsDAddress:     defaultStatsDAddress,
+		PushSocket:        defaultPushSocket,
+		PushBuckets:       defaultPushBuckets,
 	}
 	flag.StringVar(&result.jsonConfigPath, "c", result.jsonConfigPath,
 		"path to .json config file; env: CONFIG")
@@ -51,10 +86,33 @@ func NewConfig() *Config {
 		"server address; env: ADDRESS")
 	flag.StringVar(&result.Key, "k", result.Key,
 		"the key for signing the request body (the signature is in the HashSHA256 header); env: KEY")
+	flag.StringVar(&result.KeyFile, "key-file", result.KeyFile,
+		"path to file with signing keys and their ids, one space separated id and key per line, requests are signed with the first one; reloaded on SIGHUP; env: KEY_FILE")
 	flag.IntVar(&result.RateLimit, "l", result.RateLimit,
 		"max number of concurrent calls to server, flush to console if 0; env: RATE_LIMIT")
 	flag.StringVar(&result.CryptoKey, "crypto-key", result.CryptoKey,
-		"the path to the file with the server's public key for encrypting the message from the agent to the server; env: CRYPTO_KEY")
+		"the path to the file with the server's public key for encrypting the message from the agent to the server; reloaded on SIGHUP; env: CRYPTO_KEY")
+	flag.StringVar(&result.GRPCAddress, "g", result.GRPCAddress,
+		"grpc server address, metrics are reported via grpc instead of http if set; env: GRPC_ADDRESS")
+	flag.StringVar(&result.HistogramBuckets, "b", result.HistogramBuckets,
+		"comma separated upper bounds of GC pause histogram buckets, seconds; env: HISTOGRAM_BUCKETS")
+	flag.StringVar(&result.AgentIDFile, "i", result.AgentIDFile,
+		"path to the file with agent id, created on the first start; env: AGENT_ID_FILE")
+	flag.StringVar(&result.SpoolDir, "spool-dir", result.SpoolDir,
+		"directory, where batches failed to report are stored until the server is reachable, failed batches are dropped if empty; env: SPOOL_DIR")
+	flag.Int64Var(&result.SpoolMaxSize, "spool-max-size", result.SpoolMaxSize,
+		"max total size of stored batches in bytes, the oldest ones are dropped if exceeded; env: SPOOL_MAX_SIZE")
+	flag.IntVar(&result.SpoolMaxAge, "spool-max-age", result.SpoolMaxAge,
+		"stored batches older than this are dropped, seconds, never if 0; env: SPOOL_MAX_AGE")
+	flag.StringVar(&result.Collectors, "collectors", result.Collectors,
+		"comma separated enabled collectors, each with optional own poll interval in seconds, e.g. runtime,cpu:5; available: "+
+			strings.Join(metrics.RegisteredCollectors(), ",")+"; env: COLLECTORS")
+	flag.StringVar(&result.StatsDAddress, "statsd-address", result.StatsDAddress,
+		"udp address, e.g. :8125, where metrics pushed by applications in StatsD format are received, disabled if empty; env: STATSD_ADDRESS")
+	flag.StringVar(&result.PushSocket, "push-socket", result.PushSocket,
+		"path to unix socket, where metrics pushed by applications as JSON lines are received, disabled if empty; env: PUSH_SOCKET")
+	flag.StringVar(&result.PushBuckets, "push-buckets", result.PushBuckets,
+		"comma separated upper bounds of buckets of histograms pushed by applications, seconds; env: PUSH_BUCKETS")
 	return result
 }
 
@@ -69,8 +127,19 @@ func (c Config) LogValue() slog.Value {
 		slog.Int("ReportIntervalSec", c.ReportIntervalSec),
 		slog.String("ServerAddress", c.ServerAddress),
 		slog.String("Key", c.Key),
+		slog.String("KeyFile", c.KeyFile),
 		slog.Int("RateLimit", c.RateLimit),
 		slog.String("CryptoKey", c.CryptoKey),
+		slog.String("GRPCAddress", c.GRPCAddress),
+		slog.String("HistogramBuckets", c.HistogramBuckets),
+		slog.String("AgentIDFile", c.AgentIDFile),
+		slog.String("SpoolDir", c.SpoolDir),
+		slog.Int64("SpoolMaxSize", c.SpoolMaxSize),
+		slog.Int("SpoolMaxAge", c.SpoolMaxAge),
+		slog.String("Collectors", c.Collectors),
+		slog.String("StatsDAddress", c.StatsDAddress),
+		slog.String("PushSocket", c.PushSocket),
+		slog.String("PushBuckets", c.PushBuckets),
 	)
 }
 
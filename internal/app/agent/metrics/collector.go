@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CollectorOptions are agent settings, available to collector factories
+type CollectorOptions struct {
+	// HistogramBuckets are upper bounds of histogram buckets, seconds
+	HistogramBuckets []float64
+}
+
+// CollectorFactory creates PollFunc of the collector; it is called once on
+// the agent start, so PollFunc may keep state between polls
+type CollectorFactory func(options CollectorOptions) (PollFunc, error)
+
+// Collector is an enabled source of metrics, polled by its own poller
+type Collector struct {
+	Name     string
+	Poll     PollFunc
+	Interval time.Duration // the agent poll interval is used if 0
+}
+
+// CollectorSpec is an entry of the agent collectors setting:
+// "name" or "name:interval_seconds"
+type CollectorSpec struct {
+	Name     string
+	Interval time.Duration // the agent poll interval is used if 0
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]CollectorFactory)
+)
+
+// RegisterCollector makes the collector available by name in the agent
+// config; it is intended to be called from init of the file implementing
+// the collector and panics if the name is already registered
+func RegisterCollector(name string, factory CollectorFactory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, exists := registry[name]; exists {
+		panic("metrics: collector registered twice: " + name)
+	}
+	registry[name] = factory
+}
+
+// RegisteredCollectors returns sorted names of all registered collectors
+func RegisteredCollectors() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// ParseCollectors parses comma separated collector specs, e.g.
+// "runtime,cpu:5,mem"; collectors missing in the list are disabled
+func ParseCollectors(s string) ([]CollectorSpec, error) {
+	var result []CollectorSpec
+	if len(strings.TrimSpace(s)) == 0 {
+		return result, nil
+	}
+	for field := range strings.SplitSeq(s, ",") {
+		name, interval, hasInterval := strings.Cut(strings.TrimSpace(field), ":")
+		if len(name) == 0 {
+			return nil, fmt.Errorf("empty collector name: %v", s)
+		}
+		if slices.ContainsFunc(result, func(spec CollectorSpec) bool {
+			return spec.Name == name
+		}) {
+			return nil, fmt.Errorf("collector %v is listed twice", name)
+		}
+		spec := CollectorSpec{Name: name}
+		if hasInterval {
+			seconds, err := strconv.Atoi(interval)
+			if err != nil || seconds <= 0 {
+				return nil, fmt.Errorf("collector %v: poll interval must be positive number of seconds: %v",
+					name, interval)
+			}
+			spec.Interval = time.Duration(seconds) * time.Second
+		}
+		result = append(result, spec)
+	}
+	return result, nil
+}
+
+// NewCollectors creates registered collectors by specs
+func NewCollectors(specs []CollectorSpec, options CollectorOptions) ([]Collector, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	result := make([]Collector, 0, len(specs))
+	var errs []error
+	for _, spec := range specs {
+		factory, ok := registry[spec.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown collector %v, registered: %v",
+				spec.Name, strings.Join(slices.Sorted(maps.Keys(registry)), ",")))
+			continue
+		}
+		poll, err := factory(options)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("collector %v: %w", spec.Name, err))
+			continue
+		}
+		result = append(result, Collector{
+			Name:     spec.Name,
+			Poll:     poll,
+			Interval: spec.Interval,
+		})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCollectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		given   string
+		want    []CollectorSpec
+		wantErr bool
+	}{
+		{
+			name:  "names and intervals",
+			given: "runtime, cpu:5,mem",
+			want: []CollectorSpec{
+				{Name: "runtime"},
+				{Name: "cpu", Interval: 5 * time.Second},
+				{Name: "mem"},
+			},
+		},
+		{
+			name:  "all disabled",
+			given: " ",
+			want:  nil,
+		},
+		{
+			name:    "empty name",
+			given:   "runtime,,cpu",
+			wantErr: true,
+		},
+		{
+			name:    "invalid interval",
+			given:   "cpu:0",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			given:   "cpu,cpu:5",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCollectors(tt.given)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewCollectors(t *testing.T) {
+	assert.Subset(t, RegisteredCollectors(), []string{"runtime", "mem", "cpu"})
+
+	collectors, err := NewCollectors([]CollectorSpec{
+		{Name: "runtime", Interval: time.Second},
+	}, CollectorOptions{HistogramBuckets: []float64{1}})
+	require.NoError(t, err)
+	require.Len(t, collectors, 1)
+	assert.Equal(t, "runtime", collectors[0].Name)
+	assert.Equal(t, time.Second, collectors[0].Interval)
+
+	gauge := map[string]Gauge{}
+	histogram := map[string]*Histogram{}
+	collectors[0].Poll(gauge, map[string]Counter{}, histogram)
+	assert.Contains(t, gauge, "HeapAlloc")
+	assert.Equal(t, []float64{1}, histogram[GCPauseHistogram].Bounds)
+
+	_, err = NewCollectors([]CollectorSpec{{Name: "unknown"}}, CollectorOptions{})
+	assert.ErrorContains(t, err, "unknown collector unknown")
+}
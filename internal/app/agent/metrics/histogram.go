@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Histogram accumulates distribution of observed values, see
+// entities.Histogram for the meaning of the fields
+type Histogram struct {
+	Bounds []float64
+	Counts []uint64 // len(Counts) == len(Bounds)+1, the last one is +Inf bucket
+	Count  uint64
+	Sum    float64
+}
+
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds value to the first bucket with upper bound >= value
+func (h *Histogram) Observe(value float64) {
+	i, _ := slices.BinarySearch(h.Bounds, value)
+	h.Counts[i]++
+	h.Count++
+	h.Sum += value
+}
+
+// Clone returns deep copy of histogram
+func (h *Histogram) Clone() *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
+
+// Sub returns observations of h, which are not in base, i.e. h - base; h is
+// returned as is if base is nil or has different bounds
+func (h *Histogram) Sub(base *Histogram) *Histogram {
+	result := h.Clone()
+	if base == nil || !slices.Equal(h.Bounds, base.Bounds) {
+		return result
+	}
+	for i := range result.Counts {
+		result.Counts[i] -= base.Counts[i]
+	}
+	result.Count -= base.Count
+	result.Sum -= base.Sum
+	return result
+}
+
+// Add adds observations of other histogram with the same bounds to h
+func (h *Histogram) Add(other *Histogram) {
+	if !slices.Equal(h.Bounds, other.Bounds) {
+		return
+	}
+	for i := range h.Counts {
+		h.Counts[i] += other.Counts[i]
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+}
+
+// ParseBuckets parses comma separated strictly increasing bucket bounds,
+// e.g. "0.001,0.01,0.1"
+func ParseBuckets(s string) ([]float64, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return nil, errors.New("empty buckets")
+	}
+	fields := strings.Split(s, ",")
+	result := make([]float64, 0, len(fields))
+	for _, field := range fields {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse bucket bound: %w", err)
+		}
+		if len(result) > 0 && bound <= result[len(result)-1] {
+			return nil, fmt.Errorf("bucket bounds must be strictly increasing: %v", s)
+		}
+		result = append(result, bound)
+	}
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{1, 2})
+	h.Observe(0.5)
+	h.Observe(1)
+	h.Observe(1.5)
+	h.Observe(3)
+	assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(4), h.Count)
+	assert.Equal(t, 6.0, h.Sum)
+
+	clone := h.Clone()
+	h.Observe(0)
+	assert.Equal(t, []uint64{2, 1, 1}, clone.Counts)
+}
+
+func TestHistogram_SubAdd(t *testing.T) {
+	base := NewHistogram([]float64{1})
+	base.Observe(0.5)
+	h := base.Clone()
+	h.Observe(2)
+	h.Observe(0.25)
+
+	delta := h.Sub(base)
+	assert.Equal(t, []uint64{1, 1}, delta.Counts)
+	assert.Equal(t, uint64(2), delta.Count)
+	assert.Equal(t, 2.25, delta.Sum)
+
+	base.Add(delta)
+	assert.Equal(t, h, base)
+
+	// different bounds
+	other := NewHistogram([]float64{2})
+	assert.Equal(t, h, h.Sub(other))
+}
+
+func TestParseBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		given   string
+		want    []float64
+		wantErr bool
+	}{
+		{name: "positive", given: "0.001, 0.01,0.1", want: []float64{0.001, 0.01, 0.1}},
+		{name: "empty", given: " ", wantErr: true},
+		{name: "not a number", given: "0.1,foo", wantErr: true},
+		{name: "not increasing", given: "0.1,0.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBuckets(tt.given)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -6,13 +6,25 @@ type (
 )
 
 type Metrics struct {
-	Gauge   map[string]Gauge
-	Counter map[string]Counter
+	Gauge     map[string]Gauge
+	Counter   map[string]Counter
+	Histogram map[string]*Histogram
+	// Settle, if not nil, must be called once the metrics are reported or the
+	// report failed, see Poller.TakeDeltas
+	Settle func(delivered bool)
 }
 
 func NewMetrics() *Metrics {
 	return &Metrics{
-		Gauge:   make(map[string]Gauge, 0),
-		Counter: make(map[string]Counter, 0),
+		Gauge:     make(map[string]Gauge, 0),
+		Counter:   make(map[string]Counter, 0),
+		Histogram: make(map[string]*Histogram, 0),
+	}
+}
+
+// Done calls Settle if it's set
+func (m Metrics) Done(delivered bool) {
+	if m.Settle != nil {
+		m.Settle(delivered)
 	}
 }
@@ -1,18 +1,211 @@
 package metrics
 
 import (
+	"strconv"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
 	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
 	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// labels of disk and network metrics
+const (
+	mountLabel     = "mount"
+	deviceLabel    = "device"
+	interfaceLabel = "interface"
 )
 
-func PollGopsutilMetrics(gaugeMap map[string]Gauge, counter map[string]Counter) {
+// Host metrics are split into groups, each one is a separate collector.
+// Metrics of disks and network interfaces are labeled with mount point,
+// device or interface name, e.g. DiskUsedBytes{mount="/"}.
+func init() {
+	RegisterCollector("mem", func(options CollectorOptions) (PollFunc, error) {
+		return PollMemMetrics, nil
+	})
+	RegisterCollector("swap", func(options CollectorOptions) (PollFunc, error) {
+		return PollSwapMetrics, nil
+	})
+	RegisterCollector("cpu", func(options CollectorOptions) (PollFunc, error) {
+		return PollCPUMetrics, nil
+	})
+	RegisterCollector("load", func(options CollectorOptions) (PollFunc, error) {
+		return PollLoadMetrics, nil
+	})
+	RegisterCollector("process", func(options CollectorOptions) (PollFunc, error) {
+		return NewPollProcessMetrics(), nil
+	})
+	RegisterCollector("disk", func(options CollectorOptions) (PollFunc, error) {
+		return NewPollDiskMetrics(), nil
+	})
+	RegisterCollector("net", func(options CollectorOptions) (PollFunc, error) {
+		return NewPollNetMetrics(), nil
+	})
+}
+
+// PollMemMetrics polls virtual memory of the host
+func PollMemMetrics(gaugeMap map[string]Gauge, counter map[string]Counter,
+	histogram map[string]*Histogram,
+) {
 	v, err := mem.VirtualMemory()
 	if err == nil {
 		gaugeMap["TotalMemory"] = Gauge(v.Total)
 		gaugeMap["FreeMemory"] = Gauge(v.Free)
+		gaugeMap["AvailableMemory"] = Gauge(v.Available)
+		gaugeMap["UsedMemory"] = Gauge(v.Used)
 	}
-	c, err := cpu.Percent(0, false)
+}
+
+// PollSwapMetrics polls swap usage of the host
+func PollSwapMetrics(gaugeMap map[string]Gauge, counter map[string]Counter,
+	histogram map[string]*Histogram,
+) {
+	s, err := mem.SwapMemory()
 	if err == nil {
-		gaugeMap["CPUutilization1"] = Gauge(c[0])
+		gaugeMap["SwapTotal"] = Gauge(s.Total)
+		gaugeMap["SwapUsed"] = Gauge(s.Used)
+		gaugeMap["SwapFree"] = Gauge(s.Free)
+	}
+}
+
+// PollCPUMetrics polls utilization of every CPU core since the previous poll:
+// CPUutilization1 is the first core, CPUutilization2 is the second one, etc.
+func PollCPUMetrics(gaugeMap map[string]Gauge, counter map[string]Counter,
+	histogram map[string]*Histogram,
+) {
+	percents, err := cpu.Percent(0, true)
+	if err == nil {
+		setCPUUtilization(gaugeMap, percents)
+	}
+}
+
+func setCPUUtilization(gaugeMap map[string]Gauge, percents []float64) {
+	for i, percent := range percents {
+		gaugeMap["CPUutilization"+strconv.Itoa(i+1)] = Gauge(percent)
+	}
+}
+
+// PollLoadMetrics polls load averages of the host
+func PollLoadMetrics(gaugeMap map[string]Gauge, counter map[string]Counter,
+	histogram map[string]*Histogram,
+) {
+	avg, err := load.Avg()
+	if err == nil {
+		gaugeMap["Load1"] = Gauge(avg.Load1)
+		gaugeMap["Load5"] = Gauge(avg.Load5)
+		gaugeMap["Load15"] = Gauge(avg.Load15)
+	}
+}
+
+// NewPollProcessMetrics returns PollFunc, which polls process counts of the
+// host and counts created processes and context switches since the agent start
+func NewPollProcessMetrics() PollFunc {
+	counters := newOSCounters()
+	return func(gaugeMap map[string]Gauge, counter map[string]Counter,
+		histogram map[string]*Histogram,
+	) {
+		misc, err := load.Misc()
+		if err != nil {
+			return
+		}
+		gaugeMap["ProcessCount"] = Gauge(misc.ProcsTotal)
+		gaugeMap["ProcessRunning"] = Gauge(misc.ProcsRunning)
+		gaugeMap["ProcessBlocked"] = Gauge(misc.ProcsBlocked)
+		counters.update(counter, "ProcessCreated", uint64(misc.ProcsCreated))
+		counters.update(counter, "ContextSwitches", uint64(misc.Ctxt))
+	}
+}
+
+// NewPollDiskMetrics returns PollFunc, which polls usage of every mounted
+// partition and counts IO of every block device since the agent start
+func NewPollDiskMetrics() PollFunc {
+	counters := newOSCounters()
+	return func(gaugeMap map[string]Gauge, counter map[string]Counter,
+		histogram map[string]*Histogram,
+	) {
+		partitions, err := disk.Partitions(false)
+		if err == nil {
+			for _, partition := range partitions {
+				usage, err := disk.Usage(partition.Mountpoint)
+				if err != nil {
+					continue
+				}
+				gaugeMap[labeled("DiskTotalBytes", mountLabel, partition.Mountpoint)] = Gauge(usage.Total)
+				gaugeMap[labeled("DiskUsedBytes", mountLabel, partition.Mountpoint)] = Gauge(usage.Used)
+				gaugeMap[labeled("DiskFreeBytes", mountLabel, partition.Mountpoint)] = Gauge(usage.Free)
+				gaugeMap[labeled("DiskUsedPercent", mountLabel, partition.Mountpoint)] = Gauge(usage.UsedPercent)
+			}
+		}
+
+		io, err := disk.IOCounters()
+		if err == nil {
+			for device, stat := range io {
+				counters.update(counter, labeled("DiskReads", deviceLabel, device), stat.ReadCount)
+				counters.update(counter, labeled("DiskWrites", deviceLabel, device), stat.WriteCount)
+				counters.update(counter, labeled("DiskReadBytes", deviceLabel, device), stat.ReadBytes)
+				counters.update(counter, labeled("DiskWriteBytes", deviceLabel, device), stat.WriteBytes)
+			}
+		}
+	}
+}
+
+// NewPollNetMetrics returns PollFunc, which counts bytes, packets, errors and
+// dropped packets of every network interface since the agent start
+func NewPollNetMetrics() PollFunc {
+	counters := newOSCounters()
+	return func(gaugeMap map[string]Gauge, counter map[string]Counter,
+		histogram map[string]*Histogram,
+	) {
+		stats, err := net.IOCounters(true)
+		if err != nil {
+			return
+		}
+		for _, stat := range stats {
+			counters.update(counter, labeled("NetBytesSent", interfaceLabel, stat.Name), stat.BytesSent)
+			counters.update(counter, labeled("NetBytesRecv", interfaceLabel, stat.Name), stat.BytesRecv)
+			counters.update(counter, labeled("NetPacketsSent", interfaceLabel, stat.Name), stat.PacketsSent)
+			counters.update(counter, labeled("NetPacketsRecv", interfaceLabel, stat.Name), stat.PacketsRecv)
+			counters.update(counter, labeled("NetErrorsIn", interfaceLabel, stat.Name), stat.Errin)
+			counters.update(counter, labeled("NetErrorsOut", interfaceLabel, stat.Name), stat.Errout)
+			counters.update(counter, labeled("NetDropIn", interfaceLabel, stat.Name), stat.Dropin)
+			counters.update(counter, labeled("NetDropOut", interfaceLabel, stat.Name), stat.Dropout)
+		}
+	}
+}
+
+// labeled returns metric key with single label in the form name{label="value"}
+func labeled(name string, label string, value string) string {
+	return entities.MetricKey{
+		Name:   entities.MetricName(name),
+		Labels: entities.Labels{label: value}.String(),
+	}.String()
+}
+
+// osCounters converts OS counters, cumulative since boot, to counters
+// cumulative since the agent start
+type osCounters struct {
+	last map[string]uint64
+}
+
+func newOSCounters() *osCounters {
+	return &osCounters{last: make(map[string]uint64)}
+}
+
+// update adds increase of the OS counter since the previous poll to counter;
+// the first poll only remembers the value. If the OS counter is less than
+// before, it was reset or wrapped around and is counted from zero.
+func (c *osCounters) update(counter map[string]Counter, key string, value uint64) {
+	last, ok := c.last[key]
+	c.last[key] = value
+	if !ok {
+		counter[key] += 0
+		return
+	}
+	if value < last {
+		counter[key] += Counter(value)
+		return
 	}
+	counter[key] += Counter(value - last)
 }
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOSCounters(t *testing.T) {
+	counters := newOSCounters()
+	counter := map[string]Counter{}
+
+	// the first poll only remembers the value
+	counters.update(counter, "NetBytesRecv", 1000)
+	assert.Equal(t, Counter(0), counter["NetBytesRecv"])
+	assert.Contains(t, counter, "NetBytesRecv")
+
+	counters.update(counter, "NetBytesRecv", 1500)
+	assert.Equal(t, Counter(500), counter["NetBytesRecv"])
+
+	// reset is counted from zero
+	counters.update(counter, "NetBytesRecv", 200)
+	assert.Equal(t, Counter(700), counter["NetBytesRecv"])
+}
+
+func TestSetCPUUtilization(t *testing.T) {
+	gauge := map[string]Gauge{}
+	setCPUUtilization(gauge, []float64{10, 20.5})
+	assert.Equal(t, map[string]Gauge{
+		"CPUutilization1": 10,
+		"CPUutilization2": 20.5,
+	}, gauge)
+}
+
+func TestLabeled(t *testing.T) {
+	assert.Equal(t, `DiskUsedBytes{mount="/"}`, labeled("DiskUsedBytes", mountLabel, "/"))
+	assert.Equal(t, `NetBytesSent{interface="a\"b"}`, labeled("NetBytesSent", interfaceLabel, `a"b`))
+}
+
+func TestHostCollectors(t *testing.T) {
+	specs, err := ParseCollectors("mem,swap,cpu,load,process,disk,net")
+	assert.NoError(t, err)
+	collectors, err := NewCollectors(specs, CollectorOptions{})
+	assert.NoError(t, err)
+	for _, collector := range collectors {
+		// sources unavailable in the environment are skipped
+		for range 2 {
+			collector.Poll(map[string]Gauge{}, map[string]Counter{}, map[string]*Histogram{})
+		}
+	}
+}
@@ -5,12 +5,53 @@ import (
 	"math"
 	"math/big"
 	"runtime"
+	"time"
 )
 
-func PollRuntimeMetrics(gaugeMap map[string]Gauge, counter map[string]Counter) {
+// GCPauseHistogram is the name of histogram of GC pauses, seconds
+const GCPauseHistogram = "GCPauseSeconds"
+
+func init() {
+	RegisterCollector("runtime", func(options CollectorOptions) (PollFunc, error) {
+		return NewPollRuntimeMetrics(options.HistogramBuckets), nil
+	})
+}
+
+// NewPollRuntimeMetrics returns PollFunc, which polls runtime metrics and
+// observes GC pauses, happened since previous poll, in histogram with given
+// bucket bounds
+func NewPollRuntimeMetrics(buckets []float64) PollFunc {
+	var lastNumGC uint32
+	return func(gaugeMap map[string]Gauge, counter map[string]Counter,
+		histogram map[string]*Histogram,
+	) {
+		ms := runtime.MemStats{}
+		runtime.ReadMemStats(&ms)
+		pollRuntimeMetrics(&ms, gaugeMap, counter)
+
+		gcPause, ok := histogram[GCPauseHistogram]
+		if !ok {
+			gcPause = NewHistogram(buckets)
+			histogram[GCPauseHistogram] = gcPause
+		}
+		// PauseNs is circular buffer of recent GC pauses, the most recent one
+		// is at PauseNs[(NumGC+255)%256]
+		first := lastNumGC
+		if ms.NumGC-first > uint32(len(ms.PauseNs)) {
+			first = ms.NumGC - uint32(len(ms.PauseNs))
+		}
+		for gc := first; gc < ms.NumGC; gc++ {
+			pauseNs := ms.PauseNs[gc%uint32(len(ms.PauseNs))]
+			gcPause.Observe(float64(pauseNs) / float64(time.Second))
+		}
+		lastNumGC = ms.NumGC
+	}
+}
+
+func pollRuntimeMetrics(ms *runtime.MemStats, gaugeMap map[string]Gauge,
+	counter map[string]Counter,
+) {
 	// runtime metrics
-	ms := runtime.MemStats{}
-	runtime.ReadMemStats(&ms)
 	gaugeMap["Alloc"] = Gauge(ms.Alloc)
 	gaugeMap["BuckHashSys"] = Gauge(ms.BuckHashSys)
 	gaugeMap["Frees"] = Gauge(ms.Frees)
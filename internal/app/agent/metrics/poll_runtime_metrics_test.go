@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the server adds received histograms to stored ones, so every report must
+// contain only GC pauses observed since the previous delivered report
+func TestNewPollRuntimeMetrics_GCPauseDeltas(t *testing.T) {
+	p := NewPoller(NewPollRuntimeMetrics([]float64{0.001, 0.01}))
+	var reported uint64
+	for range 3 {
+		runtime.GC()
+		p.Poll()
+		_, _, _, histogram, settle := p.TakeDeltas()
+		settle(true)
+		gcPause := histogram[GCPauseHistogram]
+		require.NotNil(t, gcPause)
+		assert.GreaterOrEqual(t, gcPause.Count, uint64(1))
+		reported += gcPause.Count
+	}
+
+	// each pause is reported once, so reports sum up to at most all GCs
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	assert.LessOrEqual(t, reported, uint64(ms.NumGC))
+}
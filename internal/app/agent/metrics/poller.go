@@ -5,7 +5,8 @@ import (
 	"sync"
 )
 
-type PollFunc func(gauge map[string]Gauge, counter map[string]Counter)
+type PollFunc func(gauge map[string]Gauge, counter map[string]Counter,
+	histogram map[string]*Histogram)
 
 type Poller struct {
 	mutex           sync.RWMutex
@@ -14,6 +15,9 @@ type Poller struct {
 	readyRead       chan struct{}
 	readyReadCloser func()
 	pollCount       int
+	// cumulative counters and histograms, which are sent or being sent
+	sentCounter   map[string]Counter
+	sentHistogram map[string]*Histogram
 }
 
 func NewPoller(pollFunc PollFunc) *Poller {
@@ -25,6 +29,8 @@ func NewPoller(pollFunc PollFunc) *Poller {
 		readyReadCloser: sync.OnceFunc(func() {
 			close(readyRead)
 		}),
+		sentCounter:   make(map[string]Counter),
+		sentHistogram: make(map[string]*Histogram),
 	}
 }
 
@@ -33,7 +39,7 @@ func (p *Poller) Poll() int {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	p.pollFunc(p.metrics.Gauge, p.metrics.Counter)
+	p.pollFunc(p.metrics.Gauge, p.metrics.Counter, p.metrics.Histogram)
 
 	p.readyReadCloser()
 
@@ -41,7 +47,9 @@ func (p *Poller) Poll() int {
 	return p.pollCount
 }
 
-func (p *Poller) Get() (int, map[string]Gauge, map[string]Counter) {
+func (p *Poller) Get() (int, map[string]Gauge, map[string]Counter,
+	map[string]*Histogram,
+) {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
 
@@ -51,9 +59,69 @@ func (p *Poller) Get() (int, map[string]Gauge, map[string]Counter) {
 	counter := make(map[string]Counter)
 	maps.Copy(counter, p.metrics.Counter)
 
-	return p.pollCount, gauge, counter
+	histogram := make(map[string]*Histogram, len(p.metrics.Histogram))
+	for name, h := range p.metrics.Histogram {
+		histogram[name] = h.Clone()
+	}
+
+	return p.pollCount, gauge, counter, histogram
 }
 
 func (p *Poller) ReadyRead() chan struct{} {
 	return p.readyRead
 }
+
+// TakeDeltas returns current gauges, and counters and histograms accumulated
+// since the previous call, which are marked as sent. The caller must call
+// settle when the report is finished: if it's not delivered, the deltas are
+// returned back and will be included in the next call. So every increment is
+// reported exactly once, even if several reports are in flight.
+func (p *Poller) TakeDeltas() (pollCount int, gauge map[string]Gauge,
+	counter map[string]Counter, histogram map[string]*Histogram,
+	settle func(delivered bool),
+) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	gauge = make(map[string]Gauge, len(p.metrics.Gauge))
+	maps.Copy(gauge, p.metrics.Gauge)
+
+	counter = make(map[string]Counter, len(p.metrics.Counter))
+	for name, value := range p.metrics.Counter {
+		counter[name] = value - p.sentCounter[name]
+		p.sentCounter[name] = value
+	}
+
+	histogram = make(map[string]*Histogram, len(p.metrics.Histogram))
+	for name, h := range p.metrics.Histogram {
+		histogram[name] = h.Sub(p.sentHistogram[name])
+		p.sentHistogram[name] = h.Clone()
+	}
+
+	// deltas are cloned, so the caller may modify the returned maps
+	counterDeltas := maps.Clone(counter)
+	histogramDeltas := make(map[string]*Histogram, len(histogram))
+	for name, h := range histogram {
+		histogramDeltas[name] = h.Clone()
+	}
+	var once sync.Once
+	settle = func(delivered bool) {
+		once.Do(func() {
+			if delivered {
+				return
+			}
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			for name, delta := range counterDeltas {
+				p.sentCounter[name] -= delta
+			}
+			for name, delta := range histogramDeltas {
+				if sent, ok := p.sentHistogram[name]; ok {
+					p.sentHistogram[name] = sent.Sub(delta)
+				}
+			}
+		})
+	}
+
+	return p.pollCount, gauge, counter, histogram, settle
+}
@@ -7,7 +7,9 @@ import (
 )
 
 func Test_metrics_Poll(t *testing.T) {
-	pollFunc := func(gauge map[string]Gauge, counter map[string]Counter) {
+	pollFunc := func(gauge map[string]Gauge, counter map[string]Counter,
+		histogram map[string]*Histogram,
+	) {
 		gauge["foo"] += 1.234
 		counter["bar"] += 456
 	}
@@ -22,15 +24,63 @@ func Test_metrics_Poll(t *testing.T) {
 }
 
 func Test_metrics_Read(t *testing.T) {
-	pollFunc := func(gauge map[string]Gauge, counter map[string]Counter) {
+	pollFunc := func(gauge map[string]Gauge, counter map[string]Counter,
+		histogram map[string]*Histogram,
+	) {
 		gauge["foo"] += 1.234
 		counter["bar"] += 456
 	}
 	m := NewPoller(pollFunc)
 	m.Poll()
 	m.Poll()
-	pollCount, g, c := m.Get()
+	pollCount, g, c, _ := m.Get()
 	assert.Equal(t, 2, pollCount)
 	assert.GreaterOrEqual(t, Gauge(2.468), g["foo"])
 	assert.Equal(t, Counter(912), c["bar"])
 }
+
+func Test_metrics_TakeDeltas(t *testing.T) {
+	pollFunc := func(gauge map[string]Gauge, counter map[string]Counter,
+		histogram map[string]*Histogram,
+	) {
+		gauge["foo"] = 1.234
+		counter["bar"] += 1
+		h, ok := histogram["baz"]
+		if !ok {
+			h = NewHistogram([]float64{1})
+			histogram["baz"] = h
+		}
+		h.Observe(0.5)
+	}
+	m := NewPoller(pollFunc)
+	m.Poll()
+	m.Poll()
+
+	// the first report fails
+	_, g, c, h, settle := m.TakeDeltas()
+	assert.Equal(t, Gauge(1.234), g["foo"])
+	assert.Equal(t, Counter(2), c["bar"])
+	assert.Equal(t, uint64(2), h["baz"].Count)
+
+	// the second report is in flight while the first one fails
+	m.Poll()
+	_, _, c2, h2, settle2 := m.TakeDeltas()
+	assert.Equal(t, Counter(1), c2["bar"])
+	assert.Equal(t, uint64(1), h2["baz"].Count)
+	settle(false)
+	settle(false) // settled only once
+	settle2(true)
+
+	// failed increments are sent once again
+	m.Poll()
+	_, _, c3, h3, settle3 := m.TakeDeltas()
+	assert.Equal(t, Counter(3), c3["bar"])
+	assert.Equal(t, uint64(3), h3["baz"].Count)
+	assert.Equal(t, []uint64{3, 0}, h3["baz"].Counts)
+	settle3(true)
+
+	// nothing new
+	_, _, c4, h4, _ := m.TakeDeltas()
+	assert.Equal(t, Counter(0), c4["bar"])
+	assert.Equal(t, uint64(0), h4["baz"].Count)
+}
@@ -0,0 +1,106 @@
+// Package push receives metrics, which applications on the host push to the
+// agent, and accumulates them until they are polled and reported
+package push
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// InvalidMessagesCounter counts pushed messages, which were dropped because
+// they can't be parsed
+const InvalidMessagesCounter = "PushInvalidMessages"
+
+var errInvalidMessage = errors.New("invalid message")
+
+// Receiver accumulates pushed metrics: the last value of gauges, the sum of
+// counters and observations of histograms since the agent start. Metrics with
+// labels are keyed as name{labels}.
+type Receiver struct {
+	mutex           sync.Mutex
+	buckets         []float64 // bounds of histograms created on observation
+	gauge           map[string]metrics.Gauge
+	counter         map[string]metrics.Counter
+	histogram       map[string]*metrics.Histogram
+	invalidMessages metrics.Counter
+}
+
+func NewReceiver(buckets []float64) *Receiver {
+	return &Receiver{
+		buckets:   buckets,
+		gauge:     make(map[string]metrics.Gauge),
+		counter:   make(map[string]metrics.Counter),
+		histogram: make(map[string]*metrics.Histogram),
+	}
+}
+
+// Poll copies accumulated metrics; it matches metrics.PollFunc, so the
+// receiver is polled and reported as any other collector
+func (r *Receiver) Poll(gauge map[string]metrics.Gauge, counter map[string]metrics.Counter,
+	histogram map[string]*metrics.Histogram,
+) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	maps.Copy(gauge, r.gauge)
+	maps.Copy(counter, r.counter)
+	for key, h := range r.histogram {
+		histogram[key] = h.Clone()
+	}
+	counter[InvalidMessagesCounter] = r.invalidMessages
+}
+
+// setGauge sets the gauge, or adds value to it if relative
+func (r *Receiver) setGauge(key string, value float64, relative bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if relative {
+		r.gauge[key] += metrics.Gauge(value)
+		return
+	}
+	r.gauge[key] = metrics.Gauge(value)
+}
+
+func (r *Receiver) addCounter(key string, delta int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.counter[key] += metrics.Counter(delta)
+}
+
+func (r *Receiver) observe(key string, value float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	h, ok := r.histogram[key]
+	if !ok {
+		h = metrics.NewHistogram(r.buckets)
+		r.histogram[key] = h
+	}
+	h.Observe(value)
+}
+
+// reject logs and counts the message, which can't be parsed
+func (r *Receiver) reject(source string, message string, err error) {
+	r.mutex.Lock()
+	r.invalidMessages++
+	r.mutex.Unlock()
+	slog.Warn("["+source+"] invalid message", "message", message, "error", err)
+}
+
+// metricKey returns key of the metric in the form name{labels}
+func metricKey(name string, labels entities.Labels) (string, error) {
+	if err := entities.MetricName(name).Validate(); err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidMessage, err)
+	}
+	if err := labels.Validate(); err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidMessage, err)
+	}
+	return entities.MetricKey{
+		Name:   entities.MetricName(name),
+		Labels: labels.String(),
+	}.String(), nil
+}
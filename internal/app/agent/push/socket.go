@@ -0,0 +1,133 @@
+package push
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+const maxSocketMessageSize = 64 << 10
+
+// ListenSocket receives metrics over unix stream socket until ctx is done.
+// Every line is JSON encoded models.Metric of gauge type with value or of
+// counter type with delta, e.g.
+//
+//	{"id":"Requests","type":"counter","delta":1,"labels":{"path":"/"}}
+//
+// A socket file left from the previous run is removed; any other file at the
+// path is kept and the error is returned.
+func (r *Receiver) ListenSocket(ctx context.Context, wg *sync.WaitGroup, path string,
+) error {
+	if err := removeStaleSocket(path); err != nil {
+		return fmt.Errorf("push socket: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("push socket: %w", err)
+	}
+
+	var connections sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slog.Info("[push socket] start", "path", path)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+					slog.Error("[push socket] accept", "error", err)
+					continue
+				}
+				connections.Wait()
+				slog.Info("[push socket] stopping", "reason", ctx.Err())
+				return
+			}
+			connections.Add(1)
+			go func() {
+				defer connections.Done()
+				r.serveSocket(ctx, conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// removeStaleSocket removes the socket file at the path, if any; the path is
+// not followed if it's a symlink
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != os.ModeSocket {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+func (r *Receiver) serveSocket(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), maxSocketMessageSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := r.applyJSON(line); err != nil {
+			r.reject("push socket", string(line), err)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		slog.Warn("[push socket] read", "error", err)
+	}
+}
+
+func (r *Receiver) applyJSON(line []byte) error {
+	var metric models.Metric
+	if err := json.Unmarshal(line, &metric); err != nil {
+		return fmt.Errorf("%w: %w", errInvalidMessage, err)
+	}
+	key, err := metricKey(metric.ID, entities.Labels(metric.Labels))
+	if err != nil {
+		return err
+	}
+	switch metric.MType {
+	case "gauge":
+		if metric.Value == nil {
+			return fmt.Errorf("%w: %w", errInvalidMessage, entities.ErrMissingValue)
+		}
+		r.setGauge(key, *metric.Value, false)
+	case "counter":
+		if metric.Delta == nil {
+			return fmt.Errorf("%w: %w", errInvalidMessage, entities.ErrMissingDelta)
+		}
+		r.addCounter(key, *metric.Delta)
+	default:
+		return fmt.Errorf("%w: %w", errInvalidMessage,
+			entities.NewInvalidMetricTypeError(metric.MType))
+	}
+	return nil
+}
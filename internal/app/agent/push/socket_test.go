@@ -0,0 +1,72 @@
+package push
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenSocket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	r := NewReceiver(nil)
+	path := filepath.Join(t.TempDir(), "push.sock")
+	require.NoError(t, r.ListenSocket(ctx, &wg, path))
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte(
+		`{"id":"Requests","type":"counter","delta":2,"labels":{"path":"/"}}` + "\n" +
+			`{"id":"Queue","type":"gauge","value":1.5}` + "\n" +
+			`{"id":"Queue","type":"gauge"}` + "\n" +
+			`not json` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	assert.Eventually(t, func() bool {
+		_, counter, _ := poll(r)
+		return counter[InvalidMessagesCounter] == 2
+	}, time.Second, 10*time.Millisecond)
+	gauge, counter, _ := poll(r)
+	assert.Equal(t, map[string]metrics.Gauge{"Queue": 1.5}, gauge)
+	assert.Equal(t, metrics.Counter(2), counter[`Requests{path="/"}`])
+}
+
+func TestListenSocket_ExistingFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	r := NewReceiver(nil)
+
+	// not a socket is kept
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+	assert.Error(t, r.ListenSocket(ctx, &wg, path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	// the socket left from the previous run is replaced
+	path = filepath.Join(t.TempDir(), "push.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	listener.SetUnlinkOnClose(false)
+	require.NoError(t, listener.Close())
+	require.NoError(t, r.ListenSocket(ctx, &wg, path))
+}
@@ -0,0 +1,127 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+const maxStatsDPacketSize = 64 << 10
+
+// ListenStatsD receives StatsD packets over UDP until ctx is done. Every line
+// of a packet is a metric in the form
+//
+//	name:value|type[|@sample_rate][|#tag:value,...]
+//
+// where type is c (counter), g (gauge, relative if value is signed), ms
+// (timer, observed in histogram in seconds) or h (histogram); DogStatsD tags
+// become metric labels.
+func (r *Receiver) ListenStatsD(ctx context.Context, wg *sync.WaitGroup, address string,
+) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("statsd: %w", err)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slog.Info("[statsd] start", "address", conn.LocalAddr().String())
+		buf := make([]byte, maxStatsDPacketSize)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+					slog.Error("[statsd] read", "error", err)
+					time.Sleep(time.Second)
+					continue
+				}
+				slog.Info("[statsd] stopping", "reason", ctx.Err())
+				return
+			}
+			r.receiveStatsD(string(buf[:n]))
+		}
+	}()
+	return nil
+}
+
+func (r *Receiver) receiveStatsD(packet string) {
+	for line := range strings.SplitSeq(packet, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err := r.applyStatsD(line); err != nil {
+			r.reject("statsd", line, err)
+		}
+	}
+}
+
+func (r *Receiver) applyStatsD(line string) error {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok {
+		return fmt.Errorf("%w: missing value", errInvalidMessage)
+	}
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return fmt.Errorf("%w: missing type", errInvalidMessage)
+	}
+	rawValue, metricType := fields[0], fields[1]
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: value %q", errInvalidMessage, rawValue)
+	}
+
+	sampleRate := 1.0
+	var labels entities.Labels
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			sampleRate, err = strconv.ParseFloat(field[1:], 64)
+			if err != nil || sampleRate <= 0 || sampleRate > 1 {
+				return fmt.Errorf("%w: sample rate %q", errInvalidMessage, field)
+			}
+		case strings.HasPrefix(field, "#"):
+			labels = make(entities.Labels)
+			for tag := range strings.SplitSeq(field[1:], ",") {
+				tagName, tagValue, _ := strings.Cut(tag, ":")
+				labels[tagName] = tagValue
+			}
+		}
+	}
+
+	key, err := metricKey(name, labels)
+	if err != nil {
+		return err
+	}
+	switch metricType {
+	case "c":
+		r.addCounter(key, int64(math.Round(value/sampleRate)))
+	case "g":
+		relative := strings.HasPrefix(rawValue, "+") || strings.HasPrefix(rawValue, "-")
+		r.setGauge(key, value, relative)
+	case "ms":
+		r.observe(key, value/1000)
+	case "h":
+		r.observe(key, value)
+	default:
+		return fmt.Errorf("%w: unsupported type %q", errInvalidMessage, metricType)
+	}
+	return nil
+}
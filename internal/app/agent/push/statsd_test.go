@@ -0,0 +1,102 @@
+package push
+
+import (
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func poll(r *Receiver) (map[string]metrics.Gauge, map[string]metrics.Counter,
+	map[string]*metrics.Histogram,
+) {
+	gauge := map[string]metrics.Gauge{}
+	counter := map[string]metrics.Counter{}
+	histogram := map[string]*metrics.Histogram{}
+	r.Poll(gauge, counter, histogram)
+	return gauge, counter, histogram
+}
+
+func TestReceiveStatsD(t *testing.T) {
+	tests := []struct {
+		name          string
+		given         string
+		wantGauge     map[string]metrics.Gauge
+		wantCounter   map[string]metrics.Counter
+		wantHistogram map[string]*metrics.Histogram
+		wantInvalid   metrics.Counter
+	}{
+		{
+			name:        "counters are summed",
+			given:       "requests:1|c\nrequests:2|c\n",
+			wantCounter: map[string]metrics.Counter{"requests": 3},
+		},
+		{
+			name:        "counter sample rate",
+			given:       "requests:1|c|@0.1",
+			wantCounter: map[string]metrics.Counter{"requests": 10},
+		},
+		{
+			name:      "gauge is replaced or adjusted",
+			given:     "queue:10|g\nqueue:-3|g\nqueue:+1|g\nload:5|g\nload:2|g",
+			wantGauge: map[string]metrics.Gauge{"queue": 8, "load": 2},
+		},
+		{
+			name:  "timer in seconds",
+			given: "latency:250|ms\nlatency:2000|ms",
+			wantHistogram: map[string]*metrics.Histogram{
+				"latency": {Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 2, Sum: 2.25},
+			},
+		},
+		{
+			name:        "tags are labels",
+			given:       "requests:1|c|#path:/api,method:GET",
+			wantCounter: map[string]metrics.Counter{`requests{method="GET",path="/api"}`: 1},
+		},
+		{
+			name:        "invalid lines are counted",
+			given:       "requests\nrequests:x|c\nrequests:1|s\nrequests:1|c|#bad-tag:1\n:1|c",
+			wantInvalid: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReceiver([]float64{1})
+			r.receiveStatsD(tt.given)
+			gauge, counter, histogram := poll(r)
+			invalid := counter[InvalidMessagesCounter]
+			delete(counter, InvalidMessagesCounter)
+			if tt.wantCounter == nil {
+				tt.wantCounter = map[string]metrics.Counter{}
+			}
+			if tt.wantGauge == nil {
+				tt.wantGauge = map[string]metrics.Gauge{}
+			}
+			if tt.wantHistogram == nil {
+				tt.wantHistogram = map[string]*metrics.Histogram{}
+			}
+			assert.Equal(t, tt.wantGauge, gauge)
+			assert.Equal(t, tt.wantCounter, counter)
+			assert.Equal(t, tt.wantHistogram, histogram)
+			assert.Equal(t, tt.wantInvalid, invalid)
+		})
+	}
+}
+
+func TestReceiverPollIsCumulative(t *testing.T) {
+	r := NewReceiver(nil)
+	r.receiveStatsD("requests:1|c")
+	poller := metrics.NewPoller(r.Poll)
+	poller.Poll()
+	_, _, counter, _, settle := poller.TakeDeltas()
+	settle(true)
+	require.Equal(t, metrics.Counter(1), counter["requests"])
+
+	// only increments since the previous report are sent
+	r.receiveStatsD("requests:2|c")
+	poller.Poll()
+	_, _, counter, _, settle = poller.TakeDeltas()
+	settle(true)
+	assert.Equal(t, metrics.Counter(2), counter["requests"])
+}
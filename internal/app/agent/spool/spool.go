@@ -0,0 +1,380 @@
+// Package spool stores batches, which the agent failed to report, on disk and
+// replays them in order when the server is reachable again
+package spool
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+)
+
+// A segment is a <seq>.seg file, records are appended to it sequentially:
+//
+//	len(batch) (4 bytes, big endian) | crc32(batch) (4 bytes, big endian) | batch
+//
+// Batches are replayed from the oldest segment to the newest one; a segment is
+// deleted when all its batches are delivered, and is rewritten with the
+// undelivered rest on a delivery error.
+
+const (
+	segmentExt         = ".seg"
+	recordHeaderSize   = 8
+	maxSegmentSize     = 1 << 20 // a new segment is started when exceeded
+	segmentFilePerm    = 0o600
+	spoolDirectoryPerm = 0o755
+)
+
+// ErrRejected must be wrapped by the error returned from replay send function
+// if the server rejected the batch; such a batch is dropped instead of being
+// retried
+var ErrRejected = errors.New("batch rejected")
+
+var errCorruptedRecord = errors.New("corrupted record")
+
+type segment struct {
+	seq     uint64
+	size    int64
+	batches int
+	modTime time.Time // the time of the last append
+}
+
+// Spool is a bounded on-disk queue of batches; the oldest segments are dropped
+// if the total size exceeds maxSize or they are older than maxAge
+type Spool struct {
+	mutex    sync.Mutex
+	dir      string
+	maxSize  int64
+	maxAge   time.Duration // unlimited if 0
+	segments []*segment    // from the oldest to the newest
+	current  *segment      // the newest segment, open for appending; may be nil
+	nextSeq  uint64
+	size     int64
+
+	// dropped since the agent start and the part of them already reported
+	droppedBatches       int64
+	droppedBytes         int64
+	polledDroppedBatches int64
+	polledDroppedBytes   int64
+
+	// replayMutex serializes replays, so batches are sent in order
+	replayMutex sync.Mutex
+}
+
+// Open creates the spool directory if needed and loads segments left from the
+// previous run
+func Open(dir string, maxSize int64, maxAge time.Duration) (*Spool, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("spool: max size must be positive, got %v", maxSize)
+	}
+	if err := os.MkdirAll(dir, spoolDirectoryPerm); err != nil {
+		return nil, fmt.Errorf("spool: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("spool: %w", err)
+	}
+
+	s := &Spool{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		batches, valid, err := s.readSegment(seq)
+		if err != nil {
+			return nil, fmt.Errorf("spool: %w", err)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("spool: %w", err)
+		}
+		seg := &segment{
+			seq:     seq,
+			size:    info.Size(),
+			batches: len(batches),
+			modTime: info.ModTime(),
+		}
+		s.segments = append(s.segments, seg)
+		s.size += seg.size
+		s.nextSeq = max(s.nextSeq, seq+1)
+		if !valid {
+			// the tail was not written completely, e.g. on crash
+			if err := s.rewriteSegment(seg, batches); err != nil {
+				return nil, fmt.Errorf("spool: %w", err)
+			}
+		}
+	}
+	slices.SortFunc(s.segments, func(a, b *segment) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	s.enforceLimits(time.Now())
+	return s, nil
+}
+
+// Append adds the batch to the newest segment; the oldest segments are
+// dropped if the limits are exceeded
+func (s *Spool) Append(batch []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	recordSize := int64(recordHeaderSize + len(batch))
+	if recordSize > s.maxSize {
+		s.drop(1, recordSize, "batch exceeds spool size")
+		return nil
+	}
+	if s.current == nil || s.current.size+recordSize > maxSegmentSize {
+		s.current = &segment{seq: s.nextSeq}
+		s.nextSeq++
+		s.segments = append(s.segments, s.current)
+	}
+
+	f, err := os.OpenFile(s.segmentPath(s.current.seq),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, segmentFilePerm)
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(encodeRecord(batch)); err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	now := time.Now()
+	s.current.size += recordSize
+	s.current.batches++
+	s.current.modTime = now
+	s.size += recordSize
+	s.enforceLimits(now)
+	return nil
+}
+
+// Empty reports whether there are no batches to replay
+func (s *Spool) Empty() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.segments) == 0
+}
+
+// Replay sends spooled batches from the oldest to the newest one and deletes
+// the delivered ones; it stops on the first send error, except ErrRejected,
+// and returns it. Concurrent replays wait for each other.
+func (s *Spool) Replay(send func(batch []byte) error) error {
+	s.replayMutex.Lock()
+	defer s.replayMutex.Unlock()
+
+	for {
+		seg := s.oldestSegment()
+		if seg == nil {
+			return nil
+		}
+		batches, valid, err := s.readSegment(seg.seq)
+		if err != nil {
+			return fmt.Errorf("spool: %w", err)
+		}
+		if !valid {
+			s.mutex.Lock()
+			s.drop(1, 0, "corrupted segment tail")
+			s.mutex.Unlock()
+		}
+
+		for i, batch := range batches {
+			err := send(batch)
+			if errors.Is(err, ErrRejected) {
+				s.mutex.Lock()
+				s.drop(1, int64(recordHeaderSize+len(batch)), err.Error())
+				s.mutex.Unlock()
+				continue
+			}
+			if err != nil {
+				s.mutex.Lock()
+				defer s.mutex.Unlock()
+				if !slices.Contains(s.segments, seg) {
+					// dropped by limits while sending
+					return err
+				}
+				if rewriteErr := s.rewriteSegment(seg, batches[i:]); rewriteErr != nil {
+					return errors.Join(err, fmt.Errorf("spool: %w", rewriteErr))
+				}
+				return err
+			}
+		}
+
+		s.mutex.Lock()
+		err = s.removeSegment(seg)
+		s.mutex.Unlock()
+		if err != nil {
+			return fmt.Errorf("spool: %w", err)
+		}
+	}
+}
+
+// Poll reports spool state: pending batches and bytes as gauges, batches and
+// bytes dropped since the previous poll as counter increments; it matches
+// metrics.PollFunc
+func (s *Spool) Poll(gauge map[string]metrics.Gauge, counter map[string]metrics.Counter,
+	histogram map[string]*metrics.Histogram,
+) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var batches int
+	for _, seg := range s.segments {
+		batches += seg.batches
+	}
+	gauge["SpoolBatches"] = metrics.Gauge(batches)
+	gauge["SpoolBytes"] = metrics.Gauge(s.size)
+	counter["SpoolDroppedBatches"] += metrics.Counter(s.droppedBatches - s.polledDroppedBatches)
+	counter["SpoolDroppedBytes"] += metrics.Counter(s.droppedBytes - s.polledDroppedBytes)
+	s.polledDroppedBatches = s.droppedBatches
+	s.polledDroppedBytes = s.droppedBytes
+}
+
+// oldestSegment returns the oldest not expired segment; the current segment
+// is closed for appending, so new batches go to the next one
+func (s *Spool) oldestSegment() *segment {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.enforceLimits(time.Now())
+	if len(s.segments) == 0 {
+		return nil
+	}
+	seg := s.segments[0]
+	if seg == s.current {
+		s.current = nil
+	}
+	return seg
+}
+
+// enforceLimits drops expired segments and the oldest segments exceeding the
+// max size
+func (s *Spool) enforceLimits(now time.Time) {
+	for len(s.segments) > 0 {
+		seg := s.segments[0]
+		expired := s.maxAge > 0 && now.Sub(seg.modTime) > s.maxAge
+		if !expired && s.size <= s.maxSize {
+			return
+		}
+		batches, size := seg.batches, seg.size
+		if err := s.removeSegment(seg); err != nil {
+			slog.Error("[spool] remove segment", "error", err)
+			return
+		}
+		reason := "spool size exceeded"
+		if expired {
+			reason = "segment expired"
+		}
+		s.drop(int64(batches), size, reason)
+	}
+}
+
+func (s *Spool) removeSegment(seg *segment) error {
+	if !slices.Contains(s.segments, seg) {
+		return nil
+	}
+	if err := os.Remove(s.segmentPath(seg.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.segments = slices.DeleteFunc(s.segments, func(x *segment) bool { return x == seg })
+	s.size -= seg.size
+	if seg == s.current {
+		s.current = nil
+	}
+	return nil
+}
+
+// rewriteSegment atomically replaces segment content with batches
+func (s *Spool) rewriteSegment(seg *segment, batches [][]byte) error {
+	var buf bytes.Buffer
+	for _, batch := range batches {
+		buf.Write(encodeRecord(batch))
+	}
+	path := s.segmentPath(seg.seq)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), segmentFilePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	s.size += int64(buf.Len()) - seg.size
+	seg.size = int64(buf.Len())
+	seg.batches = len(batches)
+	return nil
+}
+
+func (s *Spool) drop(batches int64, size int64, reason string) {
+	s.droppedBatches += batches
+	s.droppedBytes += size
+	slog.Warn("[spool] dropped", "batches", batches, "bytes", size, "reason", reason,
+		"droppedBatches", s.droppedBatches, "droppedBytes", s.droppedBytes)
+}
+
+// readSegment returns batches of the segment; valid is false if the segment
+// has a corrupted or incomplete tail, which is skipped
+func (s *Spool) readSegment(seq uint64) (batches [][]byte, valid bool, err error) {
+	data, err := os.ReadFile(s.segmentPath(seq))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		batch, err := decodeRecord(r)
+		if err != nil {
+			return batches, false, nil
+		}
+		batches = append(batches, batch)
+	}
+	return batches, true, nil
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+func encodeRecord(batch []byte) []byte {
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(batch))
+	binary.BigEndian.PutUint32(record, uint32(len(batch)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(batch))
+	return append(record, batch...)
+}
+
+func decodeRecord(r *bytes.Reader) ([]byte, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errCorruptedRecord
+	}
+	size := binary.BigEndian.Uint32(header)
+	if int64(size) > int64(r.Len()) {
+		return nil, errCorruptedRecord
+	}
+	batch := make([]byte, size)
+	if _, err := io.ReadFull(r, batch); err != nil {
+		return nil, errCorruptedRecord
+	}
+	if crc32.ChecksumIEEE(batch) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errCorruptedRecord
+	}
+	return batch, nil
+}
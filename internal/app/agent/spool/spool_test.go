@@ -0,0 +1,135 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, s *Spool) []string {
+	var result []string
+	require.NoError(t, s.Replay(func(batch []byte) error {
+		result = append(result, string(batch))
+		return nil
+	}))
+	return result
+}
+
+func TestSpoolReplayInOrder(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 1<<20, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, s.Empty())
+
+	for i := range 3 {
+		require.NoError(t, s.Append([]byte(fmt.Sprint("batch", i))))
+	}
+	assert.False(t, s.Empty())
+
+	// the server is still unreachable after the first batch
+	unreachable := errors.New("unreachable")
+	var sent []string
+	err = s.Replay(func(batch []byte) error {
+		if len(sent) == 1 {
+			return unreachable
+		}
+		sent = append(sent, string(batch))
+		return nil
+	})
+	assert.ErrorIs(t, err, unreachable)
+	assert.Equal(t, []string{"batch0"}, sent)
+
+	// new batches are appended after the rest, and survive restart
+	require.NoError(t, s.Append([]byte("batch3")))
+	s, err = Open(dir, 1<<20, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"batch1", "batch2", "batch3"}, collect(t, s))
+	assert.True(t, s.Empty())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpoolRejected(t *testing.T) {
+	s, err := Open(t.TempDir(), 1<<20, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Append([]byte("bad")))
+	require.NoError(t, s.Append([]byte("good")))
+
+	var sent []string
+	require.NoError(t, s.Replay(func(batch []byte) error {
+		if string(batch) == "bad" {
+			return fmt.Errorf("%w: 400 Bad Request", ErrRejected)
+		}
+		sent = append(sent, string(batch))
+		return nil
+	}))
+	assert.Equal(t, []string{"good"}, sent)
+
+	gauge := map[string]metrics.Gauge{}
+	counter := map[string]metrics.Counter{}
+	s.Poll(gauge, counter, nil)
+	assert.Equal(t, metrics.Counter(1), counter["SpoolDroppedBatches"])
+	assert.Equal(t, metrics.Counter(recordHeaderSize+3), counter["SpoolDroppedBytes"])
+	assert.Equal(t, metrics.Gauge(0), gauge["SpoolBatches"])
+}
+
+func TestSpoolLimits(t *testing.T) {
+	batch := make([]byte, 100)
+	recordSize := int64(recordHeaderSize + len(batch))
+
+	// the oldest batches are dropped when the size is exceeded
+	s, err := Open(t.TempDir(), 2*recordSize, 0)
+	require.NoError(t, err)
+	for range 3 {
+		// every batch in its own segment
+		s.current = nil
+		require.NoError(t, s.Append(batch))
+	}
+	gauge := map[string]metrics.Gauge{}
+	counter := map[string]metrics.Counter{}
+	s.Poll(gauge, counter, nil)
+	assert.Equal(t, metrics.Gauge(2), gauge["SpoolBatches"])
+	assert.Equal(t, metrics.Counter(1), counter["SpoolDroppedBatches"])
+
+	// too large batch is dropped immediately; only the increment since the
+	// previous poll is added
+	require.NoError(t, s.Append(make([]byte, 2*recordSize)))
+	s.Poll(gauge, counter, nil)
+	assert.Equal(t, metrics.Counter(2), counter["SpoolDroppedBatches"])
+
+	// expired segments are dropped on replay
+	for _, seg := range s.segments {
+		seg.modTime = time.Now().Add(-2 * time.Hour)
+	}
+	s.maxAge = time.Hour
+	assert.Empty(t, collect(t, s))
+	counter = map[string]metrics.Counter{}
+	s.Poll(gauge, counter, nil)
+	assert.Equal(t, metrics.Counter(2), counter["SpoolDroppedBatches"])
+}
+
+func TestSpoolCorruptedTail(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 1<<20, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Append([]byte("batch0")))
+	require.NoError(t, s.Append([]byte("batch1")))
+
+	// simulate crash in the middle of append
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, segmentExt))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data[:len(data)-2], 0o600))
+
+	s, err = Open(dir, 1<<20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"batch0"}, collect(t, s))
+}
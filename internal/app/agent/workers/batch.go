@@ -0,0 +1,57 @@
+package workers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+const batchKeySize = 16 // bytes
+
+// batch is JSON encoded []models.Metric with idempotency key, which is the
+// same for all retries and replays of the batch, so the server applies it once
+type batch struct {
+	Key     string          `json:"key"`
+	Metrics json.RawMessage `json:"metrics"`
+}
+
+func newBatch(metrics []models.Metric) (batch, error) {
+	body, err := json.Marshal(metrics)
+	if err != nil {
+		return batch{}, err
+	}
+	key, err := newBatchKey()
+	if err != nil {
+		return batch{}, err
+	}
+	return batch{Key: key, Metrics: body}, nil
+}
+
+func newBatchKey() (string, error) {
+	buf := make([]byte, batchKeySize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate batch key: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// decodeBatch decodes spooled batch; batches spooled without key, i.e. plain
+// []models.Metric, get a new one
+func decodeBatch(record []byte) (batch, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(record), []byte("[")) {
+		key, err := newBatchKey()
+		if err != nil {
+			return batch{}, err
+		}
+		return batch{Key: key, Metrics: record}, nil
+	}
+	var result batch
+	if err := json.Unmarshal(record, &result); err != nil {
+		return batch{}, fmt.Errorf("decode spooled batch: %w", err)
+	}
+	return result, nil
+}
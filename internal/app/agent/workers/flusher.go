@@ -37,7 +37,10 @@ func (f *Flusher) Start(ctx context.Context) {
 					return
 				}
 				slog.Info("[flusher] flush",
-					"metric", metric)
+					"gauge", metric.Gauge,
+					"counter", metric.Counter,
+					"histogram", metric.Histogram)
+				metric.Done(true)
 			}
 		}
 	}()
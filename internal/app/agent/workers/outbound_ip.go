@@ -0,0 +1,22 @@
+package workers
+
+import (
+	"fmt"
+	"net"
+)
+
+// outboundIP returns local address of the interface used to reach the server;
+// no packets are sent, because UDP "connection" only selects the route
+func outboundIP(serverAddress string) (net.IP, error) {
+	conn, err := net.Dial("udp", serverAddress)
+	if err != nil {
+		return nil, fmt.Errorf("net.Dial(): %w", err)
+	}
+	defer conn.Close()
+
+	localAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, fmt.Errorf("unexpected local address: %v", conn.LocalAddr())
+	}
+	return localAddr.IP, nil
+}
@@ -0,0 +1,92 @@
+package workers
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/spool"
+)
+
+// maxPendingBatches limits batches kept in memory if spool is not configured
+const maxPendingBatches = 100
+
+// batchQueue keeps batches, which were not confirmed by the server, to be
+// resent later with the same idempotency key; spool.Spool keeps them on disk
+type batchQueue interface {
+	Append(record []byte) error
+	Empty() bool
+	// Replay sends batches from the oldest to the newest one and deletes the
+	// delivered ones and the ones failed with spool.ErrRejected; it stops on
+	// the first other error and returns it
+	Replay(send func(record []byte) error) error
+}
+
+type pendingRecord struct {
+	seq    uint64
+	record []byte
+}
+
+// memoryQueue is the batchQueue kept in memory; the oldest batches are dropped
+// if there are more than maxSize of them
+type memoryQueue struct {
+	mutex   sync.Mutex
+	maxSize int
+	records []pendingRecord // from the oldest to the newest
+	nextSeq uint64
+
+	// replayMutex serializes replays, so batches are sent in order
+	replayMutex sync.Mutex
+}
+
+func newMemoryQueue(maxSize int) *memoryQueue {
+	return &memoryQueue{maxSize: maxSize}
+}
+
+func (q *memoryQueue) Append(record []byte) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.records = append(q.records, pendingRecord{seq: q.nextSeq, record: record})
+	q.nextSeq++
+	if dropped := len(q.records) - q.maxSize; dropped > 0 {
+		q.records = slices.Delete(q.records, 0, dropped)
+		slog.Warn("[reporter] pending batches dropped", "batches", dropped,
+			"reason", "too many pending batches")
+	}
+	return nil
+}
+
+func (q *memoryQueue) Empty() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.records) == 0
+}
+
+func (q *memoryQueue) Replay(send func(record []byte) error) error {
+	q.replayMutex.Lock()
+	defer q.replayMutex.Unlock()
+
+	for {
+		q.mutex.Lock()
+		if len(q.records) == 0 {
+			q.mutex.Unlock()
+			return nil
+		}
+		oldest := q.records[0]
+		q.mutex.Unlock()
+
+		err := send(oldest.record)
+		if errors.Is(err, spool.ErrRejected) {
+			slog.Warn("[reporter] pending batch dropped", "reason", err.Error())
+		} else if err != nil {
+			return err
+		}
+		q.mutex.Lock()
+		// the record may be dropped by Append while sending
+		q.records = slices.DeleteFunc(q.records, func(r pendingRecord) bool {
+			return r.seq == oldest.seq
+		})
+		q.mutex.Unlock()
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/spool"
 )
 
 type PollerLauncher struct {
@@ -22,7 +23,7 @@ func NewPollerLauncher(interval time.Duration, wg *sync.WaitGroup) *PollerLaunch
 }
 
 func (l *PollerLauncher) startPoll(
-	ctx context.Context, poller *metrics.Poller, name string,
+	ctx context.Context, poller *metrics.Poller, name string, interval time.Duration,
 ) {
 	l.wg.Add(1)
 
@@ -52,7 +53,7 @@ func (l *PollerLauncher) startPoll(
 		}
 
 		// use ticker after that
-		ticker := time.NewTicker(l.interval)
+		ticker := time.NewTicker(interval)
 		for {
 			select {
 			case <-ctx.Done():
@@ -64,14 +65,23 @@ func (l *PollerLauncher) startPoll(
 	}()
 }
 
-func (l *PollerLauncher) StartPollRuntime(ctx context.Context) *metrics.Poller {
-	poller := metrics.NewPoller(metrics.PollRuntimeMetrics)
-	l.startPoll(ctx, poller, "runtime poller")
+// StartPollCollector polls the collector with its own interval, or with the
+// launcher one if not set
+func (l *PollerLauncher) StartPollCollector(ctx context.Context,
+	collector metrics.Collector,
+) *metrics.Poller {
+	interval := collector.Interval
+	if interval <= 0 {
+		interval = l.interval
+	}
+	poller := metrics.NewPoller(collector.Poll)
+	l.startPoll(ctx, poller, collector.Name+" poller", interval)
 	return poller
 }
 
-func (l *PollerLauncher) StartPollGopsutil(ctx context.Context) *metrics.Poller {
-	poller := metrics.NewPoller(metrics.PollGopsutilMetrics)
-	l.startPoll(ctx, poller, "gopsutil poller")
+func (l *PollerLauncher) StartPollSpool(ctx context.Context, spool *spool.Spool,
+) *metrics.Poller {
+	poller := metrics.NewPoller(spool.Poll)
+	l.startPoll(ctx, poller, "spool poller", l.interval)
 	return poller
 }
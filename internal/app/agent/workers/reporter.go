@@ -4,47 +4,87 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/spool"
 	httpretry "github.com/PiskarevSA/go-advanced/internal/app/agent/workers/http_retry"
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/grpchandlers"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/PiskarevSA/go-advanced/internal/middleware"
 	"github.com/PiskarevSA/go-advanced/internal/models"
+	pb "github.com/PiskarevSA/go-advanced/internal/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+const reportTimeout = 15 * time.Second
+
+// errUnreachable means the batch may be delivered later, e.g. on network
+// error or 5xx status; the server may have applied it anyway, so it must be
+// resent with the same idempotency key
+var errUnreachable = errors.New("server is unreachable")
+
+// errRejected means the server definitely did not apply the batch, e.g. on 4xx
+// status, so its increments may be reported in another batch
+var errRejected = errors.New("batch rejected")
+
+// errPending means the batch is kept in the pending queue to be resent later
+var errPending = errors.New("batch is pending")
+
 type Reporter struct {
 	wg            *sync.WaitGroup
 	index         int
 	metricsChan   <-chan metrics.Metrics
 	serverAddress string
-	key           string
+	keys          *keyring.Keyring // requests are signed with the primary key, if any
+	realIP        string           // X-Real-IP header value, not set if empty
 	httpClient    *http.Client
 	encoder       func(*http.Request) error
+	grpcClient    pb.MetricsClient // metrics are reported via grpc if not nil
+	agentID       string
+	hostname      string
+	labels        map[string]string // agent labels, attached to every metric
+	pending       batchQueue        // not confirmed batches, e.g. spool
 }
 
 func NewReporter(
 	wg *sync.WaitGroup, index int, metricsChan <-chan metrics.Metrics,
-	serverAddress string, key string, encoder func(*http.Request) error,
+	serverAddress string, keys *keyring.Keyring, realIP string,
+	encoder func(*http.Request) error, grpcClient pb.MetricsClient,
+	agentID string, hostname string, pending batchQueue,
 ) *Reporter {
 	return &Reporter{
 		wg:            wg,
 		index:         index,
 		metricsChan:   metricsChan,
 		serverAddress: serverAddress,
-		key:           key,
+		keys:          keys,
+		realIP:        realIP,
 		httpClient: &http.Client{
-			Timeout:   15 * time.Second,
+			Timeout:   reportTimeout,
 			Transport: httpretry.NewRetryableTransport(),
 		},
-		encoder: encoder,
+		encoder:    encoder,
+		grpcClient: grpcClient,
+		agentID:    agentID,
+		hostname:   hostname,
+		labels: map[string]string{
+			models.AgentIDLabel:       agentID,
+			models.AgentHostnameLabel: hostname,
+		},
+		pending: pending,
 	}
 }
 
@@ -66,7 +106,11 @@ func (r *Reporter) Start(ctx context.Context) {
 						"reason", "metrics channel closed")
 					return
 				}
-				if err := r.report(metric.Gauge, metric.Counter); err != nil {
+				err := r.report(metric.Gauge, metric.Counter, metric.Histogram)
+				// pending increments will be delivered on replay, so they
+				// must not be sent once again
+				metric.Done(err == nil || errors.Is(err, errPending))
+				if err != nil {
 					slog.Error("[reporter] report failed",
 						"index", r.index,
 						"error", err)
@@ -80,43 +124,122 @@ func (r *Reporter) Start(ctx context.Context) {
 }
 
 func (r *Reporter) report(gauge map[string]metrics.Gauge,
-	counter map[string]metrics.Counter,
+	counter map[string]metrics.Counter, histogram map[string]*metrics.Histogram,
 ) error {
-	url := "http://" + r.serverAddress + "/updates/"
-
-	metrics := make([]models.Metric, 0, len(gauge)+len(counter))
+	metrics := make([]models.Metric, 0, len(gauge)+len(counter)+len(histogram))
 	for key, gauge := range gauge {
 		value := float64(gauge)
+		id, labels := r.identify(key)
 		m := models.Metric{
-			ID:    key,
-			MType: "gauge",
-			Value: &value,
+			ID:     id,
+			MType:  "gauge",
+			Labels: labels,
+			Value:  &value,
 		}
 		metrics = append(metrics, m)
 	}
 
 	for key, counter := range counter {
 		delta := int64(counter)
+		id, labels := r.identify(key)
 		m := models.Metric{
-			ID:    key,
-			MType: "counter",
-			Delta: &delta,
+			ID:     id,
+			MType:  "counter",
+			Labels: labels,
+			Delta:  &delta,
 		}
 		metrics = append(metrics, m)
 	}
 
-	body, err := json.Marshal(metrics)
+	for key, histogram := range histogram {
+		id, labels := r.identify(key)
+		m := models.Metric{
+			ID:      id,
+			MType:   "histogram",
+			Labels:  labels,
+			Buckets: histogram.Bounds,
+			Counts:  histogram.Counts,
+			Count:   &histogram.Count,
+			Sum:     &histogram.Sum,
+		}
+		metrics = append(metrics, m)
+	}
+
+	batch, err := newBatch(metrics)
 	if err != nil {
 		return err
 	}
 
-	if err := r.reportToURL(url, body, r.key); err != nil {
-		return err
+	// pending batches are older, so they are sent first
+	if !r.pending.Empty() {
+		if err := r.pending.Replay(r.replay); err != nil {
+			return r.keepPending(batch, fmt.Errorf("replay: %w", err))
+		}
+	}
+	if err := r.send(batch); err != nil {
+		if errors.Is(err, errRejected) {
+			return err
+		}
+		return r.keepPending(batch, err)
 	}
 	return nil
 }
 
-func (r *Reporter) reportToURL(url string, body []byte, key string) error {
+// identify splits metric key to metric id and labels: collectors may label
+// metrics in the form name{labels}, agent labels are added to every metric
+func (r *Reporter) identify(key string) (string, map[string]string) {
+	metricKey := entities.ParseMetricKey(key)
+	if len(metricKey.Labels) == 0 {
+		return key, r.labels
+	}
+	labels := metricKey.LabelSet()
+	maps.Copy(labels, r.labels)
+	return string(metricKey.Name), labels
+}
+
+// send reports the batch via http or grpc
+func (r *Reporter) send(batch batch) error {
+	if r.grpcClient != nil {
+		var metrics []models.Metric
+		if err := json.Unmarshal(batch.Metrics, &metrics); err != nil {
+			return err
+		}
+		return r.reportViaGRPC(batch.Key, metrics)
+	}
+	url := "http://" + r.serverAddress + "/updates/"
+	return r.reportToURL(url, batch.Key, batch.Metrics)
+}
+
+// replay sends pending batch; the batch is dropped only if the server
+// rejected it
+func (r *Reporter) replay(record []byte) error {
+	batch, err := decodeBatch(record)
+	if err != nil {
+		return fmt.Errorf("%w: %w", spool.ErrRejected, err)
+	}
+	err = r.send(batch)
+	if errors.Is(err, errRejected) {
+		return fmt.Errorf("%w: %w", spool.ErrRejected, err)
+	}
+	return err
+}
+
+// keepPending stores the batch, which failed to report with err, to be
+// replayed later with the same key; err is returned to be logged
+func (r *Reporter) keepPending(batch batch, err error) error {
+	record, marshalErr := json.Marshal(batch)
+	if marshalErr != nil {
+		return errors.Join(err, marshalErr)
+	}
+	if appendErr := r.pending.Append(record); appendErr != nil {
+		return errors.Join(err, appendErr)
+	}
+	return fmt.Errorf("%w: %w", errPending, err)
+}
+
+// reportToURL posts JSON encoded []models.Metric; idempotencyKey stays the
+// same while http client retries the request
+func (r *Reporter) reportToURL(url string, idempotencyKey string, body []byte) error {
 	compressedBodyBuffer := bytes.NewBuffer(nil)
 	gzipWriter := gzip.NewWriter(compressedBodyBuffer)
 	// write compressed body to buffer
@@ -128,24 +251,21 @@ func (r *Reporter) reportToURL(url string, body []byte, key string) error {
 		return fmt.Errorf("gzipWriter.Close(): %w", err)
 	}
 
-	var hexSum string
-	if len(key) > 0 {
-		h := hmac.New(sha256.New, []byte(key))
-		compressedBody := compressedBodyBuffer.Bytes()
-		h.Write(compressedBody)
-		sign := h.Sum(nil)
-		hexSum = hex.EncodeToString(sign[:])
-	}
-
 	req, err := http.NewRequest(http.MethodPost, url, compressedBodyBuffer)
 	if err != nil {
 		return fmt.Errorf("http.NewRequest(): %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-Encoding", "gzip")
-	if len(hexSum) > 0 {
-		req.Header.Set("HashSHA256", hexSum)
+	// the response is not decompressed transparently, so that its signature
+	// is verified over the received bytes; its body is not used anyway
+	req.Header.Set("Accept-Encoding", "gzip")
+	if len(r.realIP) > 0 {
+		req.Header.Set(middleware.RealIPHeader, r.realIP)
 	}
+	req.Header.Set(models.AgentIDHeader, r.agentID)
+	req.Header.Set(models.AgentHostnameHeader, r.hostname)
+	req.Header.Set(models.IdempotencyKeyHeader, idempotencyKey)
 
 	if r.encoder != nil {
 		err = r.encoder(req)
@@ -153,24 +273,116 @@ func (r *Reporter) reportToURL(url string, body []byte, key string) error {
 			return fmt.Errorf("rsa encode: %w", err)
 		}
 	}
+	// the signature covers the body as sent, i.e. compressed and encrypted
+	key, signed := r.keys.Primary()
+	if signed {
+		if err := middleware.SignRequest(req, key); err != nil {
+			return fmt.Errorf("sign: %w", err)
+		}
+	}
 
 	res, err := r.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("httpClient.Do(): %w", err)
+		return fmt.Errorf("%w: httpClient.Do(): %w", errUnreachable, err)
 	}
 	defer res.Body.Close()
 
-	// The default HTTP client's Transport may not
-	// reuse HTTP/1.x "keep-alive" TCP connections if the Body is
-	// not read to completion and closed.
-	_, err = io.Copy(io.Discard, res.Body)
+	// read body to completion, so that the default HTTP client's Transport
+	// reuses HTTP/1.x "keep-alive" TCP connection
+	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return fmt.Errorf("io.Copy(): %w", err)
+		return fmt.Errorf("io.ReadAll(): %w", err)
 	}
 
+	// 5xx may come from a proxy, which does not sign responses
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: POST %v returns %v", errUnreachable, url, res.Status)
+	}
+	if signed {
+		if err := middleware.VerifyResponse(res, resBody, r.keys); err != nil {
+			return fmt.Errorf("POST %v returns %v: response: %w", url, res.Status, err)
+		}
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: POST %v returns %v", errRejected, url, res.Status)
+	}
 	if res.StatusCode != http.StatusOK {
 		return fmt.Errorf("POST %v returns %v", url, res.Status)
 	}
 
 	return nil
 }
+
+func (r *Reporter) reportViaGRPC(idempotencyKey string, metrics []models.Metric) error {
+	req := &pb.UpdateMetricsRequest{
+		Metrics: make([]*pb.Metric, 0, len(metrics)),
+	}
+	for _, m := range metrics {
+		metric := &pb.Metric{
+			Id:      m.ID,
+			Buckets: m.Buckets,
+			Counts:  m.Counts,
+			Labels:  m.Labels,
+		}
+		switch m.MType {
+		case "gauge":
+			metric.Type = pb.Metric_GAUGE
+		case "counter":
+			metric.Type = pb.Metric_COUNTER
+		case "histogram":
+			metric.Type = pb.Metric_HISTOGRAM
+		}
+		if m.Value != nil {
+			metric.Value = *m.Value
+		}
+		if m.Delta != nil {
+			metric.Delta = *m.Delta
+		}
+		if m.Count != nil {
+			metric.Count = *m.Count
+		}
+		if m.Sum != nil {
+			metric.Sum = *m.Sum
+		}
+		req.Metrics = append(req.Metrics, metric)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	if len(r.realIP) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpchandlers.RealIPKey, r.realIP)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		grpchandlers.AgentIDKey, r.agentID,
+		grpchandlers.AgentHostnameKey, r.hostname,
+		grpchandlers.IdempotencyKeyKey, idempotencyKey)
+
+	key, signed := r.keys.Primary()
+	if signed {
+		signature, err := grpchandlers.SignedMetadata(req, key)
+		if err != nil {
+			return fmt.Errorf("sign: %w", err)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, signature...)
+	}
+
+	var header metadata.MD
+	res, err := r.grpcClient.UpdateMetrics(ctx, req, grpc.Header(&header))
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Internal:
+			return fmt.Errorf("%w: grpcClient.UpdateMetrics(): %w", errUnreachable, err)
+		case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied,
+			codes.FailedPrecondition, codes.Unimplemented:
+			return fmt.Errorf("%w: grpcClient.UpdateMetrics(): %w", errRejected, err)
+		}
+		return fmt.Errorf("grpcClient.UpdateMetrics(): %w", err)
+	}
+	if signed {
+		if err := grpchandlers.VerifyResponse(res, header, r.keys); err != nil {
+			return fmt.Errorf("grpcClient.UpdateMetrics(): response: %w", err)
+		}
+	}
+	return nil
+}
@@ -8,29 +8,60 @@ import (
 	"sync"
 
 	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/spool"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/PiskarevSA/go-advanced/internal/middleware"
 	rsamiddleware "github.com/PiskarevSA/go-advanced/internal/middleware/rsa"
+	pb "github.com/PiskarevSA/go-advanced/internal/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// grpcServiceConfig retries calls to server on UNAVAILABLE status, the same
+// way as httpretry.RetryableTransport does for 502, 503 and 504 HTTP codes
+const grpcServiceConfig = `{
+	"methodConfig": [{
+		"name": [{"service": "metrics.Metrics"}],
+		"retryPolicy": {
+			"maxAttempts": 4,
+			"initialBackoff": "1s",
+			"maxBackoff": "5s",
+			"backoffMultiplier": 3,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
 type ReporterPool struct {
 	wg            *sync.WaitGroup
 	rateLimit     int
 	metricsChan   <-chan metrics.Metrics
 	serverAddress string
-	key           string
-	cryptoKey     string
+	keys          *keyring.Keyring
+	encrypter     *rsamiddleware.Encrypter // requests are not encrypted if nil
+	grpcAddress   string
+	agentID       string
+	hostname      string
+	spool         *spool.Spool
 }
 
 func NewReporterPool(
 	wg *sync.WaitGroup, rateLimit int, metricsChan <-chan metrics.Metrics,
-	serverAddress string, key string, cryptoKey string,
+	serverAddress string, keys *keyring.Keyring, encrypter *rsamiddleware.Encrypter,
+	grpcAddress string,
+	agentID string, hostname string, spool *spool.Spool,
 ) *ReporterPool {
 	return &ReporterPool{
 		wg:            wg,
 		rateLimit:     rateLimit,
 		metricsChan:   metricsChan,
 		serverAddress: serverAddress,
-		key:           key,
-		cryptoKey:     cryptoKey,
+		keys:          keys,
+		encrypter:     encrypter,
+		grpcAddress:   grpcAddress,
+		agentID:       agentID,
+		hostname:      hostname,
+		spool:         spool,
 	}
 }
 
@@ -43,21 +74,69 @@ func (p *ReporterPool) StartReporters(ctx context.Context) error {
 		return nil
 	}
 
+	// X-Real-IP header value, so the server can check trusted subnet
+	targetAddress := p.serverAddress
+	if len(p.grpcAddress) > 0 {
+		targetAddress = p.grpcAddress
+	}
+	var realIP string
+	if ip, err := outboundIP(targetAddress); err != nil {
+		slog.Warn("[reporter pool] "+middleware.RealIPHeader+" is not set",
+			"error", err)
+	} else {
+		realIP = ip.String()
+	}
+
 	var encoder func(*http.Request) error
-	if len(p.cryptoKey) > 0 {
+	var grpcClient pb.MetricsClient
+	if len(p.grpcAddress) > 0 {
+		if p.encrypter != nil {
+			slog.Warn("[reporter pool] crypto key is ignored in grpc mode")
+		}
 		var err error
-		encoder, err = rsamiddleware.Encoder(p.cryptoKey)
+		grpcClient, err = p.startGRPCClient(ctx)
 		if err != nil {
-			return fmt.Errorf("rsaencoder: %w", err)
+			return fmt.Errorf("grpc client: %w", err)
 		}
+	} else if p.encrypter != nil {
+		encoder = p.encrypter.Encrypt
 	}
 
+	// batches, which were not confirmed by the server, are resent with the
+	// same key by any reporter
+	var pending batchQueue = newMemoryQueue(maxPendingBatches)
+	if p.spool != nil {
+		pending = p.spool
+	}
 	for reporterIndex := range p.rateLimit {
 		slog.Info("[reporter pool] start reporter",
 			"reporterIndex", reporterIndex)
 		reporter := NewReporter(p.wg, reporterIndex,
-			p.metricsChan, p.serverAddress, p.key, encoder)
+			p.metricsChan, p.serverAddress, p.keys, realIP, encoder, grpcClient,
+			p.agentID, p.hostname, pending)
 		reporter.Start(ctx)
 	}
 	return nil
 }
+
+// startGRPCClient creates client connection shared by all reporters; the
+// connection is closed on context cancellation
+func (p *ReporterPool) startGRPCClient(ctx context.Context) (pb.MetricsClient, error) {
+	conn, err := grpc.NewClient(p.grpcAddress,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(grpcServiceConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		<-ctx.Done()
+		if err := conn.Close(); err != nil {
+			slog.Error("[reporter pool] close grpc connection", "error", err)
+		}
+	}()
+
+	return pb.NewMetricsClient(conn), nil
+}
@@ -0,0 +1,75 @@
+package workers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/app/agent/metrics"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporter_PendingBatch(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		keys   []string
+		status = http.StatusInternalServerError
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		keys = append(keys, r.Header.Get(models.IdempotencyKeyHeader))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	reporter := NewReporter(&sync.WaitGroup{}, 0, nil,
+		strings.TrimPrefix(server.URL, "http://"), keyring.New(), "", nil, nil,
+		"agent", "host", newMemoryQueue(maxPendingBatches))
+	setStatus := func(code int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		status = code
+	}
+	counter := map[string]metrics.Counter{"PollCount": 1}
+
+	// the outcome of 5xx is unknown, so the batch is kept pending
+	err := reporter.report(nil, counter, nil)
+	require.ErrorIs(t, err, errPending)
+	assert.False(t, reporter.pending.Empty())
+
+	// the pending batch is resent with the same key before the new one
+	setStatus(http.StatusOK)
+	require.NoError(t, reporter.report(nil, counter, nil))
+	require.Len(t, keys, 3)
+	assert.Equal(t, keys[0], keys[1])
+	assert.NotEqual(t, keys[1], keys[2])
+	assert.True(t, reporter.pending.Empty())
+
+	// the rejected batch is not kept, so its increments are reported again
+	setStatus(http.StatusBadRequest)
+	err = reporter.report(nil, counter, nil)
+	require.ErrorIs(t, err, errRejected)
+	assert.NotErrorIs(t, err, errPending)
+	assert.True(t, reporter.pending.Empty())
+}
+
+func TestMemoryQueue_Limit(t *testing.T) {
+	q := newMemoryQueue(2)
+	for _, record := range []string{"a", "b", "c"} {
+		require.NoError(t, q.Append([]byte(record)))
+	}
+
+	var sent []string
+	require.NoError(t, q.Replay(func(record []byte) error {
+		sent = append(sent, string(record))
+		return nil
+	}))
+	assert.Equal(t, []string{"b", "c"}, sent)
+	assert.True(t, q.Empty())
+}
@@ -50,20 +50,25 @@ func (l *SchedulerLauncher) StartScheduler(
 		}
 
 		schedulePollerWithContext := func(ctx context.Context, pollerIndex int, poller *metrics.Poller) error {
-			pollCount, gauge, counter := poller.Get()
+			// counters and histograms are sent as increments since the
+			// previous report
+			pollCount, gauge, counter, histogram, settle := poller.TakeDeltas()
 			slog.Info("[scheduler] schedule",
 				"pollerIndex", pollerIndex,
 				"pollCount", pollCount)
 			select {
 			case <-ctx.Done():
+				settle(false)
 				slog.Info("[scheduler] canceled",
 					"pollerIndex", pollerIndex,
 					"pollCount", pollCount,
 					"error", ctx.Err())
 				return ctx.Err()
 			case result <- metrics.Metrics{
-				Gauge:   gauge,
-				Counter: counter,
+				Gauge:     gauge,
+				Counter:   counter,
+				Histogram: histogram,
+				Settle:    settle,
 			}:
 				slog.Info("[scheduler] complete",
 					"pollerIndex", pollerIndex,
@@ -0,0 +1,139 @@
+package metricsctl
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/caarlos0/env/v6"
+)
+
+const (
+	defaultJSONConfigPath = ""
+	defaultServerAddress  = "localhost:8080"
+	defaultKey            = ""
+	defaultKeyFile        = ""
+	defaultCryptoKey      = ""
+	defaultRealIP         = ""
+	defaultTimeoutSec     = 15
+	defaultOutput         = outputTable
+)
+
+// Config содержит те же параметры подключения к серверу, что и конфигурация
+// агента, поэтому файл конфигурации агента подходит и для metricsctl
+type Config struct {
+	jsonConfigPath string   `env:"CONFIG"`
+	ServerAddress  string   `env:"ADDRESS" json:"address"`
+	Key            string   `env:"KEY" json:"key"`
+	KeyFile        string   `env:"KEY_FILE" json:"key_file"`
+	CryptoKey      string   `env:"CRYPTO_KEY" json:"crypto_key"`
+	RealIP         string   `env:"REAL_IP" json:"real_ip"`
+	TimeoutSec     int      `env:"TIMEOUT" json:"timeout"`
+	Output         string   `json:"-"`
+	args           []string // команда и её аргументы
+}
+
+func NewConfig() *Config {
+	result := &Config{
+		jsonConfigPath: defaultJSONConfigPath,
+		ServerAddress:  defaultServerAddress,
+		Key:            defaultKey,
+		KeyFile:        defaultKeyFile,
+		CryptoKey:      defaultCryptoKey,
+		RealIP:         defaultRealIP,
+		TimeoutSec:     defaultTimeoutSec,
+		Output:         defaultOutput,
+	}
+	flag.StringVar(&result.jsonConfigPath, "c", result.jsonConfigPath,
+		"path to .json config file, e.g. the agent's one; env: CONFIG")
+	flag.StringVar(&result.ServerAddress, "a", result.ServerAddress,
+		"server address; env: ADDRESS")
+	flag.StringVar(&result.Key, "k", result.Key,
+		"the key for signing the request body (the signature is in the HashSHA256 header); env: KEY")
+	flag.StringVar(&result.KeyFile, "key-file", result.KeyFile,
+		"path to file with signing keys and their ids, one space separated id and key per line, requests are signed with the first one; env: KEY_FILE")
+	flag.StringVar(&result.CryptoKey, "crypto-key", result.CryptoKey,
+		"the path to the file with the server's public key for encrypting requests; env: CRYPTO_KEY")
+	flag.StringVar(&result.RealIP, "real-ip", result.RealIP,
+		"X-Real-IP header value checked by the server against its trusted subnet, not set if empty; env: REAL_IP")
+	flag.IntVar(&result.TimeoutSec, "t", result.TimeoutSec,
+		"request timeout including retries, seconds; env: TIMEOUT")
+	flag.StringVar(&result.Output, "o", result.Output,
+		"output format: table or json")
+	flag.Usage = usage
+	return result
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(),
+		"Usage: %s [flags] command [command flags] [arguments]\n\nCommands:\n%s\nFlags:\n",
+		os.Args[0], commandsUsage())
+	flag.PrintDefaults()
+}
+
+func (c Config) LogValue() slog.Value {
+	// hide key
+	if len(c.Key) > 0 {
+		c.Key = "[redacted]"
+	}
+	return slog.GroupValue(
+		slog.String("JSONConfigPath", c.jsonConfigPath),
+		slog.String("ServerAddress", c.ServerAddress),
+		slog.String("Key", c.Key),
+		slog.String("KeyFile", c.KeyFile),
+		slog.String("CryptoKey", c.CryptoKey),
+		slog.String("RealIP", c.RealIP),
+		slog.Int("TimeoutSec", c.TimeoutSec),
+		slog.String("Output", c.Output),
+		slog.Any("Args", c.args),
+	)
+}
+
+func (c *Config) ParseFlags() error {
+	flag.CommandLine.Init("", flag.ContinueOnError)
+	err := flag.CommandLine.Parse(os.Args[1:])
+	if err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return errors.New("command expected")
+	}
+	c.args = flag.Args()
+	return nil
+}
+
+func (c *Config) ReadEnv() error {
+	err := env.Parse(c)
+	if err != nil {
+		flag.Usage()
+		return fmt.Errorf("read env: %w", err)
+	}
+	return nil
+}
+
+func (c *Config) JSONConfigPath() string {
+	return c.jsonConfigPath
+}
+
+func (c *Config) ReadJSONFile() error {
+	f, err := os.Open(c.jsonConfigPath)
+	if err != nil {
+		return fmt.Errorf("read json file: %w", err)
+	}
+	defer f.Close()
+	decoder := json.NewDecoder(f)
+	err = decoder.Decode(c)
+	if err != nil {
+		return fmt.Errorf("read json file: %w", err)
+	}
+	return nil
+}
+
+// Args возвращает команду и её аргументы
+func (c *Config) Args() []string {
+	return c.args
+}
@@ -0,0 +1,282 @@
+package metricsctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/PiskarevSA/go-advanced/pkg/client"
+)
+
+// command описывает команду metricsctl
+type command struct {
+	name  string
+	usage string
+	run   func(c *Ctl, ctx context.Context, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{
+		name:  "get",
+		usage: "get [-l name=value]... type name\n\tprint the current value of the metric",
+		run:   (*Ctl).get,
+	},
+	{
+		name:  "set",
+		usage: "set [-l name=value]... name value\n\tset the gauge value",
+		run:   (*Ctl).set,
+	},
+	{
+		name:  "inc",
+		usage: "inc [-l name=value]... name [delta]\n\tincrease the counter by delta, 1 by default, and print its total",
+		run:   (*Ctl).inc,
+	},
+	{
+		name:  "batch",
+		usage: "batch [file]\n\tsend JSON array of metrics in the POST /updates/ format from the file or stdin, if file is omitted or -",
+		run:   (*Ctl).batch,
+	},
+	{
+		name:  "dump",
+		usage: "dump [-type type,...] [-prefix prefix] [-regex regex] [-sort type|name|value] [-desc] [-limit n] [-offset n]\n\tprint all metrics matching the filter",
+		run:   (*Ctl).dump,
+	},
+	{
+		name:  "ping",
+		usage: "ping\n\tcheck that the server and its storage are available",
+		run:   (*Ctl).ping,
+	},
+}
+
+func commandsUsage() string {
+	var b strings.Builder
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %s\n", cmd.usage)
+	}
+	return b.String()
+}
+
+// Ctl выполняет команды metricsctl с помощью клиента сервера
+type Ctl struct {
+	client *client.Client
+	output string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// NewClient создает клиента сервера по конфигурации
+func NewClient(config *Config) (*client.Client, error) {
+	keys, err := keyring.Load(config.Key, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, _ := keys.Primary() // requests are not signed with empty key
+	return client.New(config.ServerAddress,
+		client.WithKeyID(key.ID, key.Secret),
+		client.WithCryptoKey(config.CryptoKey),
+		client.WithRealIP(config.RealIP),
+		client.WithTimeout(time.Duration(config.TimeoutSec)*time.Second),
+	)
+}
+
+func NewCtl(c *client.Client, output string, stdin io.Reader, stdout io.Writer,
+	stderr io.Writer,
+) (*Ctl, error) {
+	if output != outputTable && output != outputJSON {
+		return nil, fmt.Errorf("unknown output format: %s", output)
+	}
+	return &Ctl{
+		client: c,
+		output: output,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}, nil
+}
+
+// Run выполняет команду args[0] с аргументами args[1:]
+func (c *Ctl) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("command expected")
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(c, ctx, c.newFlagSet(cmd), args[1:])
+		}
+	}
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+func (c *Ctl) newFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// labelsFlag collects repeated -l name=value flags
+type labelsFlag map[string]string
+
+func (l labelsFlag) String() string {
+	return formatLabels(l)
+}
+
+func (l labelsFlag) Set(value string) error {
+	name, labelValue, ok := strings.Cut(value, "=")
+	if !ok || len(name) == 0 {
+		return fmt.Errorf("name=value expected: %s", value)
+	}
+	l[name] = labelValue
+	return nil
+}
+
+// parseArgs parses command flags and checks the number of positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, minArgs int, maxArgs int,
+) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		fs.Usage()
+		return nil, fmt.Errorf("%s: unexpected number of arguments", fs.Name())
+	}
+	return fs.Args(), nil
+}
+
+// labelsOrNil returns nil for empty labels, so that they are omitted in request
+func labelsOrNil(labels labelsFlag) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+func (c *Ctl) get(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	labels := labelsFlag{}
+	fs.Var(labels, "l", "metric label name=value, may be repeated")
+	args, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	metric, err := c.client.Get(ctx, client.MetricType(args[0]), args[1],
+		labelsOrNil(labels))
+	if err != nil {
+		return err
+	}
+	return c.printMetric(metric)
+}
+
+func (c *Ctl) set(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	labels := labelsFlag{}
+	fs.Var(labels, "l", "metric label name=value, may be repeated")
+	args, err := parseArgs(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	value, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid gauge value: %w", err)
+	}
+
+	if err := c.client.Gauge(ctx, args[0], value, labelsOrNil(labels)); err != nil {
+		return err
+	}
+	return c.printMetric(client.GaugeMetric(args[0], value, labelsOrNil(labels)))
+}
+
+func (c *Ctl) inc(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	labels := labelsFlag{}
+	fs.Var(labels, "l", "metric label name=value, may be repeated")
+	args, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	delta := int64(1)
+	if len(args) > 1 {
+		if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return fmt.Errorf("invalid counter delta: %w", err)
+		}
+	}
+
+	total, err := c.client.Counter(ctx, args[0], delta, labelsOrNil(labels))
+	if err != nil {
+		return err
+	}
+	return c.printMetric(client.CounterMetric(args[0], total, labelsOrNil(labels)))
+}
+
+func (c *Ctl) batch(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	args, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	input := c.stdin
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	}
+	var metrics []client.Metric
+	if err := json.NewDecoder(input).Decode(&metrics); err != nil {
+		return fmt.Errorf("decode batch: %w", err)
+	}
+
+	updated, err := c.client.Batch(ctx, metrics)
+	if err != nil {
+		return err
+	}
+	return c.printMetrics(updated)
+}
+
+func (c *Ctl) dump(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	var filter client.Filter
+	var types string
+	fs.StringVar(&types, "type", "", "comma separated metric types, all if empty")
+	fs.StringVar(&filter.Prefix, "prefix", "", "metric name prefix")
+	fs.StringVar(&filter.Regex, "regex", "", "metric name regular expression")
+	fs.StringVar(&filter.Sort, "sort", "", "sort by type (default), name or value")
+	fs.BoolVar(&filter.Descending, "desc", false, "sort in descending order")
+	fs.IntVar(&filter.Limit, "limit", 0, "max number of metrics, unlimited if 0")
+	fs.IntVar(&filter.Offset, "offset", 0, "number of metrics to skip")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if len(types) > 0 {
+		for _, t := range strings.Split(types, ",") {
+			filter.Types = append(filter.Types, client.MetricType(t))
+		}
+	}
+
+	metrics, err := c.client.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return c.printMetrics(metrics)
+}
+
+func (c *Ctl) ping(ctx context.Context, fs *flag.FlagSet, args []string) error {
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	if err := c.client.Ping(ctx); err != nil {
+		return err
+	}
+	return c.printStatus("ok")
+}
@@ -0,0 +1,170 @@
+package metricsctl
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/handlers"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/PiskarevSA/go-advanced/internal/middleware"
+	"github.com/PiskarevSA/go-advanced/internal/storage/memstorage"
+	"github.com/PiskarevSA/go-advanced/internal/usecases"
+	"github.com/PiskarevSA/go-advanced/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "secret"
+
+// newTestCtl starts signing server and returns Ctl connected to it
+func newTestCtl(t *testing.T, output string, stdin string) (*Ctl, *bytes.Buffer) {
+	r := handlers.NewMetricsRouter(usecases.NewMetricsUsecase(memstorage.New())).
+		WithMiddlewares(middleware.Integrity(testKey), middleware.Encoding).
+		WithAllHandlers()
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+
+	config := &Config{ServerAddress: ts.URL, Key: testKey, TimeoutSec: 5}
+	c, err := NewClient(config)
+	require.NoError(t, err)
+	stdout := bytes.NewBuffer(nil)
+	ctl, err := NewCtl(c, output, strings.NewReader(stdin), stdout, bytes.NewBuffer(nil))
+	require.NoError(t, err)
+	return ctl, stdout
+}
+
+func TestCtl_Run(t *testing.T) {
+	batchFile := filepath.Join(t.TempDir(), "batch.json")
+	require.NoError(t, os.WriteFile(batchFile,
+		[]byte(`[{"id":"Requests","type":"counter","delta":2}]`), 0o600))
+
+	tests := []struct {
+		name     string
+		output   string
+		stdin    string
+		commands [][]string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "set and get gauge",
+			output:   outputTable,
+			commands: [][]string{{"set", "-l", "host=a", "Temperature", "36.6"}, {"get", "-l", "host=a", "gauge", "Temperature"}},
+			want: "TYPE   NAME         LABELS  VALUE\n" +
+				"gauge  Temperature  host=a  36.6\n" +
+				"TYPE   NAME         LABELS  VALUE\n" +
+				"gauge  Temperature  host=a  36.6\n",
+		},
+		{
+			name:     "inc counter",
+			output:   outputJSON,
+			commands: [][]string{{"inc", "Requests"}, {"inc", "Requests", "5"}},
+			want: "{\n  \"id\": \"Requests\",\n  \"type\": \"counter\",\n  \"delta\": 1\n}\n" +
+				"{\n  \"id\": \"Requests\",\n  \"type\": \"counter\",\n  \"delta\": 6\n}\n",
+		},
+		{
+			name:     "batch from stdin and file, then dump",
+			output:   outputTable,
+			stdin:    `[{"id":"Requests","type":"counter","delta":1},{"id":"Alloc","type":"gauge","value":1024}]`,
+			commands: [][]string{{"batch"}, {"batch", batchFile}, {"dump", "-sort", "name"}},
+			want: "TYPE     NAME      LABELS  VALUE\n" +
+				"counter  Requests          1\n" +
+				"gauge    Alloc             1024\n" +
+				"TYPE     NAME      LABELS  VALUE\n" +
+				"counter  Requests          3\n" +
+				"TYPE     NAME      LABELS  VALUE\n" +
+				"gauge    Alloc             1024\n" +
+				"counter  Requests          3\n",
+		},
+		{
+			name:     "dump empty",
+			output:   outputJSON,
+			commands: [][]string{{"dump"}},
+			want:     "[]\n",
+		},
+		{
+			name:     "ping",
+			output:   outputTable,
+			commands: [][]string{{"ping"}},
+			want:     "ok\n",
+		},
+		{
+			name:     "get missing metric",
+			output:   outputTable,
+			commands: [][]string{{"get", "gauge", "Temperature"}},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid gauge value",
+			output:   outputTable,
+			commands: [][]string{{"set", "Temperature", "hot"}},
+			wantErr:  true,
+		},
+		{
+			name:     "unexpected number of arguments",
+			output:   outputTable,
+			commands: [][]string{{"get", "Temperature"}},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown command",
+			output:   outputTable,
+			commands: [][]string{{"delete", "Temperature"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctl, stdout := newTestCtl(t, tt.output, tt.stdin)
+			var err error
+			for _, args := range tt.commands {
+				if err = ctl.Run(context.Background(), args); err != nil {
+					break
+				}
+			}
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
+
+func TestCtl_GetMissingMetricIsNotFound(t *testing.T) {
+	ctl, _ := newTestCtl(t, outputTable, "")
+	err := ctl.Run(context.Background(), []string{"get", "counter", "Requests"})
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func TestNewClient_KeyFile(t *testing.T) {
+	keys := keyring.New(keyring.Key{ID: "2", Secret: "new"}, keyring.Key{ID: "1", Secret: "old"})
+	r := handlers.NewMetricsRouter(usecases.NewMetricsUsecase(memstorage.New())).
+		WithMiddlewares(middleware.IntegrityKeyring(keys), middleware.Encoding).
+		WithAllHandlers()
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	// the first key of the file is used, the key without id is not
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(keyFile, []byte("# rotation in progress\n1 old\n2 new\n"), 0o600))
+	config := &Config{ServerAddress: ts.URL, Key: "unknown", KeyFile: keyFile, TimeoutSec: 5}
+	c, err := NewClient(config)
+	require.NoError(t, err)
+	assert.NoError(t, c.Gauge(context.Background(), "Temperature", 36.6, nil))
+
+	config.KeyFile = filepath.Join(t.TempDir(), "missing")
+	_, err = NewClient(config)
+	assert.Error(t, err)
+}
+
+func TestNewCtl_UnknownOutput(t *testing.T) {
+	_, err := NewCtl(nil, "xml", nil, nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package metricsctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/PiskarevSA/go-advanced/pkg/client"
+)
+
+// Форматы вывода
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func (c *Ctl) printMetric(metric client.Metric) error {
+	if c.output == outputJSON {
+		return c.printJSON(metric)
+	}
+	return c.printTable([]client.Metric{metric})
+}
+
+func (c *Ctl) printMetrics(metrics []client.Metric) error {
+	if c.output == outputJSON {
+		if metrics == nil {
+			// print empty array instead of null
+			metrics = []client.Metric{}
+		}
+		return c.printJSON(metrics)
+	}
+	return c.printTable(metrics)
+}
+
+func (c *Ctl) printStatus(status string) error {
+	if c.output == outputJSON {
+		return c.printJSON(map[string]string{"status": status})
+	}
+	_, err := fmt.Fprintln(c.stdout, status)
+	return err
+}
+
+func (c *Ctl) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *Ctl) printTable(metrics []client.Metric) error {
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tNAME\tLABELS\tVALUE")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Type, m.Name, formatLabels(m.Labels),
+			formatValue(m))
+	}
+	return w.Flush()
+}
+
+// formatLabels formats labels sorted by name, e.g. host=a,path=/
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, name+"="+labels[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatValue(m client.Metric) string {
+	switch m.Type {
+	case client.Gauge:
+		return strconv.FormatFloat(m.Value, 'g', -1, 64)
+	case client.Counter:
+		return strconv.FormatInt(m.Delta, 10)
+	case client.Histogram:
+		if m.Histogram == nil {
+			return ""
+		}
+		return fmt.Sprintf("count=%d sum=%s", m.Histogram.Count,
+			strconv.FormatFloat(m.Histogram.Sum, 'g', -1, 64))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,53 @@
+// Package reloader перечитывает ключи по сигналу SIGHUP без перезапуска
+// сервера или агента
+package reloader
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Reloader перечитывает свои файлы; при ошибке прежнее состояние сохраняется
+type Reloader interface {
+	Reload() error
+}
+
+// Start вызывает Reload у каждого из reloaders при получении SIGHUP, пока не
+// отменен ctx; ошибки перезагрузки журналируются
+func Start(ctx context.Context, wg *sync.WaitGroup, reloaders ...Reloader) {
+	if len(reloaders) == 0 {
+		return
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer signal.Stop(sigChan)
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("[key reloader] stopped")
+				return
+			case <-sigChan:
+				slog.Info("[key reloader] received SIGHUP, reloading keys")
+				Reload(reloaders...)
+			}
+		}
+	}()
+}
+
+// Reload вызывает Reload у каждого из reloaders
+func Reload(reloaders ...Reloader) {
+	for _, r := range reloaders {
+		if err := r.Reload(); err != nil {
+			slog.Error("[key reloader] reload failed, previous keys are kept",
+				"error", err)
+		}
+	}
+}
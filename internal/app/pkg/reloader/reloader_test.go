@@ -0,0 +1,41 @@
+package reloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingReloader struct {
+	count atomic.Int32
+	err   error
+}
+
+func (r *countingReloader) Reload() error {
+	r.count.Add(1)
+	return r.err
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	failing := &countingReloader{err: errors.New("broken key file")}
+	succeeding := &countingReloader{}
+	Start(ctx, &wg, failing, succeeding)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	// failed reload does not prevent the others
+	assert.Eventually(t, func() bool {
+		return failing.count.Load() == 1 && succeeding.count.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+}
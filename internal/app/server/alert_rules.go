@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// loadAlertRules reads the list of models.AlertRule from .json, .yaml or .yml
+// file
+func loadAlertRules(path string) ([]entities.AlertRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read alert rules: %w", err)
+	}
+	var rules []models.AlertRule
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &rules)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &rules)
+	default:
+		return nil, fmt.Errorf("read alert rules: unsupported file extension %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read alert rules: %w", err)
+	}
+
+	result := make([]entities.AlertRule, 0, len(rules))
+	names := make(map[string]struct{}, len(rules))
+	for i, rule := range rules {
+		var for_ time.Duration
+		if len(rule.For) > 0 {
+			if for_, err = time.ParseDuration(rule.For); err != nil {
+				return nil, fmt.Errorf("alert rule[%v]: %w: %s: for: %w",
+					i, entities.ErrInvalidAlertRule, rule.Name, err)
+			}
+		}
+		alertRule, err := entities.NewAlertRule(rule.Name, rule.Expr, for_)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule[%v]: %w", i, err)
+		}
+		if _, ok := names[rule.Name]; ok {
+			return nil, fmt.Errorf("alert rule[%v]: %w: %s: duplicate name",
+				i, entities.ErrInvalidAlertRule, rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+		result = append(result, alertRule)
+	}
+	return result, nil
+}
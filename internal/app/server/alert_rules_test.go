@@ -0,0 +1,81 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAlertRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		wantFor  []time.Duration
+		wantErr  error
+		wantFail bool
+	}{
+		{
+			name: "yaml",
+			file: "rules.yaml",
+			content: `
+- name: heap
+  expr: gauge HeapAlloc > 500MB for 2m
+- name: stuck
+  expr: rate(counter PollCount) == 0
+  for: 1m
+`,
+			wantFor: []time.Duration{2 * time.Minute, time.Minute},
+		},
+		{
+			name:    "json",
+			file:    "rules.json",
+			content: `[{"name":"heap","expr":"gauge HeapAlloc > 500MB"}]`,
+			wantFor: []time.Duration{0},
+		},
+		{
+			name:    "duplicate name",
+			file:    "rules.json",
+			content: `[{"name":"a","expr":"gauge A > 1"},{"name":"a","expr":"gauge B > 1"}]`,
+			wantErr: entities.ErrInvalidAlertRule,
+		},
+		{
+			name:    "invalid expression",
+			file:    "rules.yml",
+			content: `[{name: a, expr: "gauge A"}]`,
+			wantErr: entities.ErrInvalidAlertRule,
+		},
+		{
+			name:     "unsupported extension",
+			file:     "rules.txt",
+			content:  `[]`,
+			wantFail: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			rules, err := loadAlertRules(path)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			if tt.wantFail {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var gotFor []time.Duration
+			for _, rule := range rules {
+				gotFor = append(gotFor, rule.For)
+			}
+			assert.Equal(t, tt.wantFor, gotFor)
+		})
+	}
+}
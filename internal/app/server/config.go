@@ -21,7 +21,26 @@ const (
 	defaultRestore         = false
 	defaultDatabaseDSN     = ""
 	defaultKey             = ""
+	defaultKeyFile         = ""
 	defaultCryptoKey       = ""
+	defaultGRPCAddress     = ""
+	defaultTrustedSubnet   = ""
+	defaultPublicPaths     = "/,/ping,/metrics,/stream"
+
+	defaultCompactInterval    = 60
+	defaultSampleRetention    = 24 * 60 * 60
+	defaultAggregateRetention = 30 * 24 * 60 * 60
+
+	defaultAlertRules    = ""
+	defaultAlertInterval = 10
+	defaultAlertWebhook  = ""
+
+	defaultExpectedReportInterval = 10
+	defaultStaleFactor            = 3
+
+	// the agent replays unconfirmed batches for up to its -spool-max-age,
+	// which is 24 hours by default
+	defaultIdempotencyTTL = 24 * 60 * 60
 )
 
 type Config struct {
@@ -32,7 +51,24 @@ type Config struct {
 	Restore         bool   `env:"RESTORE" json:"restore"`
 	DatabaseDSN     string `env:"DATABASE_DSN" json:"database_dsn"`
 	Key             string `env:"KEY" json:"key"`
+	KeyFile         string `env:"KEY_FILE" json:"key_file"`
 	CryptoKey       string `env:"CRYPTO_KEY" json:"crypto_key"`
+	GRPCAddress     string `env:"GRPC_ADDRESS" json:"grpc_address"`
+	TrustedSubnet   string `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
+	PublicPaths     string `env:"PUBLIC_PATHS" json:"public_paths"`
+
+	CompactInterval    int `env:"COMPACT_INTERVAL" json:"compact_interval"`
+	SampleRetention    int `env:"SAMPLE_RETENTION" json:"sample_retention"`
+	AggregateRetention int `env:"AGGREGATE_RETENTION" json:"aggregate_retention"`
+
+	AlertRules    string `env:"ALERT_RULES" json:"alert_rules"`
+	AlertInterval int    `env:"ALERT_INTERVAL" json:"alert_interval"`
+	AlertWebhook  string `env:"ALERT_WEBHOOK" json:"alert_webhook"`
+
+	ExpectedReportInterval int `env:"EXPECTED_REPORT_INTERVAL" json:"expected_report_interval"`
+	StaleFactor            int `env:"STALE_FACTOR" json:"stale_factor"`
+
+	IdempotencyTTL int `env:"IDEMPOTENCY_TTL" json:"idempotency_ttl"`
 }
 
 func NewConfig() *Config {
@@ -44,7 +80,24 @@ func NewConfig() *Config {
 		Restore:         defaultRestore,
 		DatabaseDSN:     defaultDatabaseDSN,
 		Key:             defaultKey,
+		KeyFile:         defaultKeyFile,
 		CryptoKey:       defaultCryptoKey,
+		GRPCAddress:     defaultGRPCAddress,
+		TrustedSubnet:   defaultTrustedSubnet,
+		PublicPaths:     defaultPublicPaths,
+
+		CompactInterval:    defaultCompactInterval,
+		SampleRetention:    defaultSampleRetention,
+		AggregateRetention: defaultAggregateRetention,
+
+		AlertRules:    defaultAlertRules,
+		AlertInterval: defaultAlertInterval,
+		AlertWebhook:  defaultAlertWebhook,
+
+		ExpectedReportInterval: defaultExpectedReportInterval,
+		StaleFactor:            defaultStaleFactor,
+
+		IdempotencyTTL: defaultIdempotencyTTL,
 	}
 	flag.StringVar(&result.jsonConfigPath, "c", result.jsonConfigPath,
 		"path to .json config file; env: CONFIG")
@@ -60,8 +113,34 @@ func NewConfig() *Config {
 		"database data source name (DSN)")
 	flag.StringVar(&result.Key, "k", result.Key,
 		"the key for validating the request body and signing the response body (both signatures are in the HashSHA256 header); env: KEY")
+	flag.StringVar(&result.KeyFile, "key-file", result.KeyFile,
+		"path to file with signing keys and their ids, one space separated id and key per line, the first key is primary; reloaded on SIGHUP; env: KEY_FILE")
 	flag.StringVar(&result.CryptoKey, "crypto-key", result.CryptoKey,
-		"The path to the file with the server's private key for decrypting the message from the agent to the server; env: CRYPTO_KEY")
+		"comma separated paths to the files with the server's private keys for decrypting the message from the agent to the server; reloaded on SIGHUP; env: CRYPTO_KEY")
+	flag.StringVar(&result.GRPCAddress, "g", result.GRPCAddress,
+		"grpc server address, grpc server is disabled if empty; env: GRPC_ADDRESS")
+	flag.StringVar(&result.TrustedSubnet, "t", result.TrustedSubnet,
+		"trusted subnet in CIDR notation, requests with X-Real-IP outside of it are rejected, all requests are accepted if empty; env: TRUSTED_SUBNET")
+	flag.StringVar(&result.PublicPaths, "public-paths", result.PublicPaths,
+		"comma separated paths available by unsigned GET and HEAD when the key is set, their responses are not signed; env: PUBLIC_PATHS")
+	flag.IntVar(&result.CompactInterval, "compact-interval", result.CompactInterval,
+		"metric history compaction interval in seconds, compaction is disabled if 0; env: COMPACT_INTERVAL")
+	flag.IntVar(&result.SampleRetention, "sample-retention", result.SampleRetention,
+		"how long raw metric history samples are kept, in seconds, forever if 0; env: SAMPLE_RETENTION")
+	flag.IntVar(&result.AggregateRetention, "aggregate-retention", result.AggregateRetention,
+		"how long 1 minute aggregates of metric history are kept in database, in seconds, forever if 0; 1 hour aggregates are kept forever; env: AGGREGATE_RETENTION")
+	flag.StringVar(&result.AlertRules, "alert-rules", result.AlertRules,
+		"path to .json or .yaml file with alert rules, alerting is disabled if empty; env: ALERT_RULES")
+	flag.IntVar(&result.AlertInterval, "alert-interval", result.AlertInterval,
+		"alert rules evaluation interval in seconds; env: ALERT_INTERVAL")
+	flag.StringVar(&result.AlertWebhook, "alert-webhook", result.AlertWebhook,
+		"url, to which firing and resolved alerts are posted, notifications are disabled if empty; env: ALERT_WEBHOOK")
+	flag.IntVar(&result.ExpectedReportInterval, "expected-report-interval", result.ExpectedReportInterval,
+		"expected agent report interval in seconds; env: EXPECTED_REPORT_INTERVAL")
+	flag.IntVar(&result.StaleFactor, "stale-factor", result.StaleFactor,
+		"metric is stale if it is not updated within stale-factor expected report intervals, stale detection is disabled if 0; env: STALE_FACTOR")
+	flag.IntVar(&result.IdempotencyTTL, "idempotency-ttl", result.IdempotencyTTL,
+		"how long results of batches with Idempotency-Key are kept to answer their replays, in seconds, should cover agent -spool-max-age, deduplication is disabled if 0; env: IDEMPOTENCY_TTL")
 	return result
 }
 
@@ -85,10 +164,44 @@ func (c Config) LogValue() slog.Value {
 		slog.Bool("Restore", c.Restore),
 		slog.String("DatabaseDSN", c.DatabaseDSN),
 		slog.String("Key", c.Key),
+		slog.String("KeyFile", c.KeyFile),
 		slog.String("CryptoKey", c.CryptoKey),
+		slog.String("GRPCAddress", c.GRPCAddress),
+		slog.String("TrustedSubnet", c.TrustedSubnet),
+		slog.String("PublicPaths", c.PublicPaths),
+		slog.Int("CompactInterval", c.CompactInterval),
+		slog.Int("SampleRetention", c.SampleRetention),
+		slog.Int("AggregateRetention", c.AggregateRetention),
+		slog.String("AlertRules", c.AlertRules),
+		slog.Int("AlertInterval", c.AlertInterval),
+		slog.String("AlertWebhook", c.AlertWebhook),
+		slog.Int("ExpectedReportInterval", c.ExpectedReportInterval),
+		slog.Int("StaleFactor", c.StaleFactor),
+		slog.Int("IdempotencyTTL", c.IdempotencyTTL),
 	)
 }
 
+// CryptoKeys returns paths to private key files listed in CryptoKey
+func (c *Config) CryptoKeys() []string {
+	return splitList(c.CryptoKey)
+}
+
+// PublicPathList returns paths listed in PublicPaths
+func (c *Config) PublicPathList() []string {
+	return splitList(c.PublicPaths)
+}
+
+// splitList returns non-empty items of comma separated list
+func splitList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 func (c *Config) ParseFlags() error {
 	flag.CommandLine.Init("", flag.ContinueOnError)
 	err := flag.CommandLine.Parse(os.Args[1:])
@@ -3,30 +3,54 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/PiskarevSA/go-advanced/internal/app/pkg/reloader"
 	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/grpchandlers"
 	"github.com/PiskarevSA/go-advanced/internal/handlers"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
 	"github.com/PiskarevSA/go-advanced/internal/middleware"
 	rsamiddleware "github.com/PiskarevSA/go-advanced/internal/middleware/rsa"
+	"github.com/PiskarevSA/go-advanced/internal/notifier"
+	pb "github.com/PiskarevSA/go-advanced/internal/proto"
 	"github.com/PiskarevSA/go-advanced/internal/storage/filestorage"
 	"github.com/PiskarevSA/go-advanced/internal/storage/memstorage"
 	"github.com/PiskarevSA/go-advanced/internal/storage/pgstorage"
 	"github.com/PiskarevSA/go-advanced/internal/usecases"
+	"google.golang.org/grpc"
 )
 
+const idempotencyExpireInterval = time.Minute
+
 type usecaseStorage interface {
 	GetMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 	UpdateMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 	UpdateMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
-	GetMetricsByTypes(ctx context.Context, gauge map[entities.MetricName]entities.Gauge,
-		counter map[entities.MetricName]entities.Counter) error
+	UpdateMetricsOnce(ctx context.Context, idempotency entities.Idempotency,
+		metrics []entities.Metric) ([]entities.Metric, bool, error)
+	ExpireIdempotencyKeys(ctx context.Context, before time.Time) error
+	DeleteMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	DeleteMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+	ResetCounter(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	GetMetricsByTypes(ctx context.Context, gauge map[entities.MetricKey]entities.Gauge,
+		counter map[entities.MetricKey]entities.Counter,
+		histogram map[entities.MetricKey]entities.Histogram) error
+	FindMetrics(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error)
+	UpdateAgent(ctx context.Context, agent entities.Agent, metrics []entities.Metric) error
+	GetAgents(ctx context.Context) ([]entities.Agent, error)
+	GetSamples(ctx context.Context, metric entities.Metric, from time.Time,
+		to time.Time) ([]entities.Sample, error)
+	CompactSamples(ctx context.Context, now time.Time, retention entities.SampleRetention) error
 	Ping(ctx context.Context) error
 	Close(ctx context.Context) error
 }
@@ -56,15 +80,35 @@ func (s *Server) Run() bool {
 	defer storage.Close(ctx)
 
 	usecase := s.createMetricsUsecase(storage)
+	if usecase == nil {
+		return false
+	}
+
+	keys, decrypter, err := s.loadKeys()
+	if err != nil {
+		slog.Error("[main] load keys", "error", err.Error())
+		return false
+	}
 
-	server := s.createServer(usecase)
+	server := s.createServer(usecase, keys, decrypter)
+	if server == nil {
+		return false
+	}
+	grpcServer, err := s.createGRPCServer(usecase, keys) // nil if disabled
+	if err != nil {
+		slog.Error("[main] create grpc server", "error", err.Error())
+		return false
+	}
 
-	success := true // will be false if listener could not be started
-	s.startWorkers(ctx, cancel, &wg, server, &success)
+	// will be true if any listener could not be started; listeners run
+	// concurrently, so the flag is atomic
+	var failed atomic.Bool
+	s.startWorkers(ctx, cancel, &wg, storage, usecase, server, grpcServer, &failed)
+	s.startKeyReloader(ctx, &wg, keys, decrypter)
 
 	// Wait for all goroutines to finish
 	wg.Wait()
-	return success
+	return !failed.Load()
 }
 
 func (s *Server) setupSignalHandler() (context.Context, context.CancelFunc) {
@@ -92,10 +136,23 @@ func (s *Server) setupSignalHandler() (context.Context, context.CancelFunc) {
 }
 
 func (s *Server) startWorkers(ctx context.Context, cancel context.CancelFunc,
-	wg *sync.WaitGroup, server *http.Server, success *bool,
+	wg *sync.WaitGroup, storage usecaseStorage, usecase *usecases.MetricsUsecase,
+	server *http.Server, grpcServer *grpc.Server, failed *atomic.Bool,
 ) {
-	s.startListener(cancel, wg, server, success)
-	s.startWatchdog(ctx, wg, server)
+	s.startListener(cancel, wg, server, failed)
+	if grpcServer != nil {
+		s.startGRPCListener(cancel, wg, grpcServer, failed)
+	}
+	if s.config.CompactInterval > 0 {
+		s.startCompactor(ctx, wg, storage)
+	}
+	if len(s.config.AlertRules) > 0 && s.config.AlertInterval > 0 {
+		s.startAlerter(ctx, wg, usecase)
+	}
+	if s.config.IdempotencyTTL > 0 {
+		s.startIdempotencyExpirer(ctx, wg, usecase)
+	}
+	s.startWatchdog(ctx, wg, server, grpcServer)
 }
 
 func (s *Server) createStorage(ctx context.Context, wg *sync.WaitGroup,
@@ -125,27 +182,63 @@ func (s *Server) createStorage(ctx context.Context, wg *sync.WaitGroup,
 
 func (s *Server) createMetricsUsecase(storage usecaseStorage,
 ) *usecases.MetricsUsecase {
-	return usecases.NewMetricsUsecase(storage)
+	usecase := usecases.NewMetricsUsecase(storage).WithStaleAfter(
+		time.Duration(s.config.StaleFactor*s.config.ExpectedReportInterval) * time.Second).
+		WithIdempotencyTTL(time.Duration(s.config.IdempotencyTTL) * time.Second)
+	if len(s.config.AlertRules) == 0 {
+		return usecase
+	}
+	rules, err := loadAlertRules(s.config.AlertRules)
+	if err != nil {
+		slog.Error("[main] load alert rules", "error", err.Error())
+		return nil
+	}
+	if len(s.config.AlertWebhook) > 0 {
+		usecase.WithAlerts(rules, notifier.NewWebhook(s.config.AlertWebhook))
+	} else {
+		usecase.WithAlerts(rules, nil)
+	}
+	slog.Info("[main] alert rules loaded", "count", len(rules))
+	return usecase
 }
 
-func (s *Server) createServer(usecase *usecases.MetricsUsecase) *http.Server {
+// loadKeys loads signing keys and private keys for decryption; decrypter is
+// nil if no private keys are configured
+func (s *Server) loadKeys() (*keyring.Keyring, *rsamiddleware.Decrypter, error) {
+	keys, err := keyring.Load(s.config.Key, s.config.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cryptoKeys := s.config.CryptoKeys()
+	if len(cryptoKeys) == 0 {
+		return keys, nil, nil
+	}
+	decrypter, err := rsamiddleware.NewDecrypter(cryptoKeys...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rsa decrypter: %w", err)
+	}
+	return keys, decrypter, nil
+}
+
+func (s *Server) createServer(usecase *usecases.MetricsUsecase,
+	keys *keyring.Keyring, decrypter *rsamiddleware.Decrypter,
+) *http.Server {
+	trustedSubnet, err := middleware.TrustedSubnet(s.config.TrustedSubnet)
+	if err != nil {
+		slog.Error("[main] create server", "error", err.Error())
+		return nil
+	}
+	// signatures cover the body as transferred, so Integrity goes before
+	// decryption and decompression
 	middlewares := []func(http.Handler) http.Handler{
 		middleware.Summary,
+		trustedSubnet,
+		middleware.IntegrityKeyring(keys, s.config.PublicPathList()...),
 	}
-	if len(s.config.CryptoKey) > 0 {
-		var err error
-		decoder, err := rsamiddleware.Decoder(s.config.CryptoKey)
-		if err != nil {
-			slog.Error("[main] create server", "error", err.Error())
-			return nil
-		}
-		if decoder != nil {
-			middlewares = append(middlewares, decoder)
-		}
+	if decrypter != nil {
+		middlewares = append(middlewares, decrypter.Handler)
 	}
-	middlewares = append(middlewares,
-		middleware.Integrity(s.config.Key),
-		middleware.Encoding)
+	middlewares = append(middlewares, middleware.Encoding)
 	r := handlers.NewMetricsRouter(usecase).
 		WithMiddlewares(middlewares...).
 		WithAllHandlers()
@@ -153,11 +246,43 @@ func (s *Server) createServer(usecase *usecases.MetricsUsecase) *http.Server {
 		Addr: s.config.ServerAddress,
 	}
 	server.Handler = r
+	// let event streams finish, otherwise Shutdown would wait for them
+	server.RegisterOnShutdown(usecase.CloseSubscriptions)
 	return &server
 }
 
+func (s *Server) createGRPCServer(usecase *usecases.MetricsUsecase,
+	keys *keyring.Keyring,
+) (*grpc.Server, error) {
+	if len(s.config.GRPCAddress) == 0 {
+		return nil, nil
+	}
+	trustedSubnet, err := grpchandlers.TrustedSubnet(s.config.TrustedSubnet)
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		trustedSubnet,
+		grpchandlers.IntegrityKeyring(keys),
+	))
+	pb.RegisterMetricsServer(server, grpchandlers.NewMetricsServer(usecase))
+	return server, nil
+}
+
+// startKeyReloader reloads key files on SIGHUP, so that keys are rotated
+// without restart
+func (s *Server) startKeyReloader(ctx context.Context, wg *sync.WaitGroup,
+	keys *keyring.Keyring, decrypter *rsamiddleware.Decrypter,
+) {
+	reloaders := []reloader.Reloader{keys}
+	if decrypter != nil {
+		reloaders = append(reloaders, decrypter)
+	}
+	reloader.Start(ctx, wg, reloaders...)
+}
+
 func (s *Server) startListener(cancel context.CancelFunc, wg *sync.WaitGroup,
-	server *http.Server, success *bool,
+	server *http.Server, failed *atomic.Bool,
 ) {
 	wg.Add(1)
 	go func() {
@@ -166,7 +291,7 @@ func (s *Server) startListener(cancel context.CancelFunc, wg *sync.WaitGroup,
 
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("[listener] server.ListenAndServe() error", "error", err.Error())
-			*success = false
+			failed.Store(true)
 
 			// Cancel the context to notify all goroutines to stop
 			cancel()
@@ -175,7 +300,137 @@ func (s *Server) startListener(cancel context.CancelFunc, wg *sync.WaitGroup,
 	}()
 }
 
-func (s *Server) startWatchdog(ctx context.Context, wg *sync.WaitGroup, server *http.Server) {
+func (s *Server) startGRPCListener(cancel context.CancelFunc, wg *sync.WaitGroup,
+	server *grpc.Server, failed *atomic.Bool,
+) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slog.Info("[grpc listener] start")
+
+		listen, err := net.Listen("tcp", s.config.GRPCAddress)
+		if err != nil {
+			slog.Error("[grpc listener] net.Listen() error", "error", err.Error())
+			failed.Store(true)
+
+			// Cancel the context to notify all goroutines to stop
+			cancel()
+			return
+		}
+
+		if err := server.Serve(listen); err != nil {
+			slog.Error("[grpc listener] server.Serve() error", "error", err.Error())
+			failed.Store(true)
+
+			// Cancel the context to notify all goroutines to stop
+			cancel()
+		}
+		slog.Info("[grpc listener] Stopped serving new connections.")
+	}()
+}
+
+// startCompactor periodically aggregates metric history and deletes samples
+// older than configured retention
+func (s *Server) startCompactor(ctx context.Context, wg *sync.WaitGroup,
+	storage usecaseStorage,
+) {
+	retention := entities.SampleRetention{
+		Raw:    time.Duration(s.config.SampleRetention) * time.Second,
+		Minute: time.Duration(s.config.AggregateRetention) * time.Second,
+	}
+	compact := func() {
+		compactCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if err := storage.CompactSamples(compactCtx, time.Now(), retention); err != nil {
+			slog.Error("[compactor] storage.CompactSamples() error", "error", err.Error())
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slog.Info("[compactor] start")
+
+		ticker := time.NewTicker(time.Duration(s.config.CompactInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("[compactor] stopping", "error", ctx.Err())
+				return
+			case <-ticker.C:
+				compact()
+			}
+		}
+	}()
+}
+
+// startAlerter periodically evaluates alert rules
+func (s *Server) startAlerter(ctx context.Context, wg *sync.WaitGroup,
+	usecase *usecases.MetricsUsecase,
+) {
+	evaluate := func() {
+		evaluateCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if err := usecase.EvaluateAlerts(evaluateCtx, time.Now()); err != nil {
+			slog.Error("[alerter] usecase.EvaluateAlerts() error", "error", err.Error())
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slog.Info("[alerter] start")
+
+		ticker := time.NewTicker(time.Duration(s.config.AlertInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("[alerter] stopping", "error", ctx.Err())
+				return
+			case <-ticker.C:
+				evaluate()
+			}
+		}
+	}()
+}
+
+// startIdempotencyExpirer periodically forgets expired idempotency keys
+func (s *Server) startIdempotencyExpirer(ctx context.Context, wg *sync.WaitGroup,
+	usecase *usecases.MetricsUsecase,
+) {
+	expire := func() {
+		expireCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if err := usecase.ExpireIdempotencyKeys(expireCtx, time.Now()); err != nil {
+			slog.Error("[idempotency expirer] usecase.ExpireIdempotencyKeys() error",
+				"error", err.Error())
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		slog.Info("[idempotency expirer] start")
+
+		ticker := time.NewTicker(idempotencyExpireInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("[idempotency expirer] stopping", "error", ctx.Err())
+				return
+			case <-ticker.C:
+				expire()
+			}
+		}
+	}()
+}
+
+func (s *Server) startWatchdog(ctx context.Context, wg *sync.WaitGroup,
+	server *http.Server, grpcServer *grpc.Server,
+) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -191,5 +446,11 @@ func (s *Server) startWatchdog(ctx context.Context, wg *sync.WaitGroup, server *
 		} else {
 			slog.Info("[watchdog] server.Shutdown() completed")
 		}
+
+		if grpcServer != nil {
+			slog.Info("[watchdog] grpcServer.GracefulStop() initiated")
+			grpcServer.GracefulStop()
+			slog.Info("[watchdog] grpcServer.GracefulStop() completed")
+		}
 	}()
 }
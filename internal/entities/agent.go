@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+// Agent describes metrics reporter, identified by ID, generated by agent on
+// the first start
+type Agent struct {
+	ID       string
+	Hostname string
+	// LastSeen is the time of the last report received from the agent
+	LastSeen time.Time
+	// Metrics are metrics, reported by the agent; only Type, Name and Labels
+	// are filled
+	Metrics []Metric
+}
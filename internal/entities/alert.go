@@ -0,0 +1,170 @@
+package entities
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlertFunction is applied to the metric before comparison with threshold
+type AlertFunction int
+
+const (
+	// AlertValue compares current gauge value or counter delta
+	AlertValue AlertFunction = iota
+	// AlertRate compares per-second increase of counter between evaluations
+	AlertRate
+)
+
+// AlertCondition is a parsed alert expression, e.g.
+// `gauge HeapAlloc > 500MB` or `rate(counter PollCount{host="a"}) == 0`
+type AlertCondition struct {
+	Function  AlertFunction
+	Metric    Metric // type, name and labels
+	Operator  string
+	Threshold float64
+}
+
+// AlertRule is evaluated periodically; alert fires if its condition holds
+// continuously for the For duration
+type AlertRule struct {
+	Name      string
+	Expr      string
+	Condition AlertCondition
+	For       time.Duration
+}
+
+// AlertState enumerator
+type AlertState string
+
+const (
+	AlertInactive AlertState = "inactive" // condition doesn't hold
+	AlertPending  AlertState = "pending"  // condition holds less than For
+	AlertFiring   AlertState = "firing"
+	AlertResolved AlertState = "resolved" // was firing, condition doesn't hold
+)
+
+// Alert is the state of the alert rule
+type Alert struct {
+	Rule        AlertRule
+	State       AlertState
+	Value       float64 // the last evaluated value
+	HasValue    bool    // false if the metric is unknown or rate is not known yet
+	ActiveSince time.Time
+	FiredAt     time.Time
+	ResolvedAt  time.Time
+}
+
+var (
+	alertExprRegexp = regexp.MustCompile(
+		`^(.*\S)\s*(>=|<=|==|!=|>|<)\s*([-+]?[0-9.]+(?:[eE][-+]?[0-9]+)?)\s*([KMG]B)?$`)
+	alertForRegexp    = regexp.MustCompile(`^(.*\S)\s+for\s+(\S+)$`)
+	alertRateRegexp   = regexp.MustCompile(`^rate\(\s*(.*?)\s*\)$`)
+	alertMetricRegexp = regexp.MustCompile(`^(gauge|counter)\s+(\S.*)$`)
+)
+
+// alertUnits are multipliers of threshold units
+var alertUnits = map[string]float64{
+	"":   1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+}
+
+// NewAlertRule parses expression: `[rate(]<gauge|counter> <name>[{labels}][)]
+// <operator> <threshold>[KB|MB|GB] [for <duration>]`, where operator is one of
+// > >= < <= == !=; rate is applicable to counters only. The duration may be
+// passed either in expression or as for_, but not both.
+func NewAlertRule(name string, expr string, for_ time.Duration) (AlertRule, error) {
+	result := AlertRule{Name: name, Expr: expr, For: for_}
+	if len(name) == 0 {
+		return AlertRule{}, fmt.Errorf("%w: empty name", ErrInvalidAlertRule)
+	}
+
+	expr = strings.TrimSpace(expr)
+	if match := alertForRegexp.FindStringSubmatch(expr); match != nil {
+		if for_ != 0 {
+			return AlertRule{}, fmt.Errorf("%w: %s: duplicate for", ErrInvalidAlertRule, name)
+		}
+		var err error
+		expr = match[1]
+		result.For, err = time.ParseDuration(match[2])
+		if err != nil {
+			return AlertRule{}, fmt.Errorf("%w: %s: for: %w", ErrInvalidAlertRule, name, err)
+		}
+	}
+	if result.For < 0 {
+		return AlertRule{}, fmt.Errorf("%w: %s: negative for", ErrInvalidAlertRule, name)
+	}
+
+	match := alertExprRegexp.FindStringSubmatch(expr)
+	if match == nil {
+		return AlertRule{}, fmt.Errorf("%w: %s: malformed expression: %s",
+			ErrInvalidAlertRule, name, expr)
+	}
+	operand, threshold, unit := match[1], match[3], match[4]
+	result.Condition.Operator = match[2]
+	value, err := strconv.ParseFloat(threshold, 64)
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("%w: %s: threshold: %w", ErrInvalidAlertRule, name, err)
+	}
+	result.Condition.Threshold = value * alertUnits[unit]
+
+	if rate := alertRateRegexp.FindStringSubmatch(operand); rate != nil {
+		result.Condition.Function = AlertRate
+		operand = rate[1]
+	}
+	metric := alertMetricRegexp.FindStringSubmatch(operand)
+	if metric == nil {
+		return AlertRule{}, fmt.Errorf("%w: %s: expected gauge or counter metric: %s",
+			ErrInvalidAlertRule, name, operand)
+	}
+	if metric[1] == "gauge" {
+		result.Condition.Metric.Type = MetricTypeGauge
+	} else {
+		result.Condition.Metric.Type = MetricTypeCounter
+	}
+	if result.Condition.Function == AlertRate &&
+		result.Condition.Metric.Type != MetricTypeCounter {
+		return AlertRule{}, fmt.Errorf("%w: %s: rate is applicable to counters only",
+			ErrInvalidAlertRule, name)
+	}
+	key := ParseMetricKey(metric[2])
+	if strings.ContainsAny(string(key.Name), "{} ") {
+		return AlertRule{}, fmt.Errorf("%w: %s: %w: %s",
+			ErrInvalidAlertRule, name, ErrMalformedLabels, metric[2])
+	}
+	labels, err := ParseLabels(key.Labels)
+	if err != nil {
+		return AlertRule{}, fmt.Errorf("%w: %s: %w", ErrInvalidAlertRule, name, err)
+	}
+	if err := labels.Validate(); err != nil {
+		return AlertRule{}, fmt.Errorf("%w: %s: %w", ErrInvalidAlertRule, name, err)
+	}
+	result.Condition.Metric.Name = key.Name
+	if len(labels) > 0 {
+		result.Condition.Metric.Labels = labels
+	}
+	return result, nil
+}
+
+// Holds reports whether the condition holds for the value
+func (c AlertCondition) Holds(value float64) bool {
+	switch c.Operator {
+	case ">":
+		return value > c.Threshold
+	case ">=":
+		return value >= c.Threshold
+	case "<":
+		return value < c.Threshold
+	case "<=":
+		return value <= c.Threshold
+	case "==":
+		return value == c.Threshold
+	case "!=":
+		return value != c.Threshold
+	}
+	return false
+}
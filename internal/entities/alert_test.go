@@ -0,0 +1,81 @@
+package entities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAlertRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		for_    time.Duration
+		want    AlertCondition
+		wantFor time.Duration
+		wantErr bool
+	}{
+		{
+			name: "gauge with unit",
+			expr: "gauge HeapAlloc > 500MB",
+			for_: time.Minute,
+			want: AlertCondition{
+				Function:  AlertValue,
+				Metric:    Metric{Type: MetricTypeGauge, Name: "HeapAlloc"},
+				Operator:  ">",
+				Threshold: 500 << 20,
+			},
+			wantFor: time.Minute,
+		},
+		{
+			name: "counter rate with labels",
+			expr: `rate(counter PollCount{host="a"}) == 0 for 2m`,
+			want: AlertCondition{
+				Function:  AlertRate,
+				Metric:    Metric{Type: MetricTypeCounter, Name: "PollCount", Labels: Labels{"host": "a"}},
+				Operator:  "==",
+				Threshold: 0,
+			},
+			wantFor: 2 * time.Minute,
+		},
+		{
+			name: "negative threshold without spaces",
+			expr: "gauge Temperature<=-1.5",
+			want: AlertCondition{
+				Metric:    Metric{Type: MetricTypeGauge, Name: "Temperature"},
+				Operator:  "<=",
+				Threshold: -1.5,
+			},
+		},
+		{name: "rate of gauge", expr: "rate(gauge Alloc) > 1", wantErr: true},
+		{name: "histogram", expr: "histogram GCPause > 1", wantErr: true},
+		{name: "missing operator", expr: "gauge Alloc 1", wantErr: true},
+		{name: "unknown unit", expr: "gauge Alloc > 1TB", wantErr: true},
+		{name: "malformed labels", expr: "gauge Alloc{host=a} > 1", wantErr: true},
+		{name: "duplicate for", expr: "gauge Alloc > 1 for 1m", for_: time.Minute, wantErr: true},
+		{name: "malformed for", expr: "gauge Alloc > 1 for 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := NewAlertRule("rule", tt.expr, tt.for_)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAlertRule)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rule.Condition)
+			assert.Equal(t, tt.wantFor, rule.For)
+		})
+	}
+}
+
+func TestAlertCondition_Holds(t *testing.T) {
+	c := AlertCondition{Operator: ">=", Threshold: 2}
+	assert.True(t, c.Holds(2))
+	assert.False(t, c.Holds(1))
+	c.Operator = "!="
+	assert.True(t, c.Holds(1))
+	assert.False(t, c.Holds(2))
+}
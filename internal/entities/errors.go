@@ -8,9 +8,29 @@ import (
 // stateless errors
 var (
 	ErrEmptyMetricName     = errors.New("empty metric name")
+	ErrInvalidMetricName   = errors.New("metric name should not contain { or }")
 	ErrJSONRequestExpected = errors.New("expected Content-Type=application/json")
 	ErrMissingValue        = errors.New("missing value")
 	ErrMissingDelta        = errors.New("missing delta")
+	ErrMissingHistogram    = errors.New("missing histogram")
+	ErrHistogramFromURL    = errors.New("histogram can't be updated from url")
+
+	ErrHistogramBoundsNotIncreasing = errors.New("histogram bounds should be strictly increasing")
+	ErrHistogramCountsLength        = errors.New("histogram should have one count per bucket plus +Inf")
+	ErrHistogramCountMismatch       = errors.New("histogram count should be equal to sum of counts")
+	ErrHistogramBoundsMismatch      = errors.New("histogram bounds differ from stored ones")
+
+	ErrInvalidLabelName = errors.New("label name should match [a-zA-Z_][a-zA-Z0-9_]*")
+	ErrMalformedLabels  = errors.New("malformed labels")
+
+	ErrHistoryNotSupported = errors.New("history is not supported")
+	ErrInvalidHistoryQuery = errors.New("invalid history query")
+
+	ErrInvalidMetricFilter = errors.New("invalid metric filter")
+
+	ErrInvalidAlertRule = errors.New("invalid alert rule")
+
+	ErrIdempotencyKeyReused = errors.New("idempotency key is reused for another batch")
 )
 
 // stateful errors
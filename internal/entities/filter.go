@@ -0,0 +1,101 @@
+package entities
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MetricSortKey enumerator
+type MetricSortKey int
+
+const (
+	// SortByType sorts gauges first, then counters, then histograms, each group
+	// by name and labels
+	SortByType MetricSortKey = iota
+	// SortByName sorts by name, then labels, then type
+	SortByName
+	// SortByValue sorts by gauge value, counter delta or histogram count, then
+	// the same as SortByType
+	SortByValue
+)
+
+// MetricFilter selects, sorts and paginates metrics
+type MetricFilter struct {
+	Types      []MetricType   // all types if empty
+	NamePrefix string         // any name if empty
+	NameRegexp *regexp.Regexp // any name if nil
+	SortBy     MetricSortKey
+	Descending bool
+	Offset     int
+	Limit      int // no limit if zero
+}
+
+// Match reports whether metric satisfies the filter conditions
+func (f MetricFilter) Match(metric Metric) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, metric.Type) {
+		return false
+	}
+	if !strings.HasPrefix(string(metric.Name), f.NamePrefix) {
+		return false
+	}
+	if f.NameRegexp != nil && !f.NameRegexp.MatchString(string(metric.Name)) {
+		return false
+	}
+	return true
+}
+
+// Compare compares metrics according to the filter sorting
+func (f MetricFilter) Compare(a, b Metric) int {
+	var c int
+	switch f.SortBy {
+	case SortByName:
+		c = cmp.Or(
+			a.Key().Compare(b.Key()),
+			cmp.Compare(a.Type, b.Type))
+	case SortByValue:
+		c = cmp.Or(
+			cmp.Compare(a.sortValue(), b.sortValue()),
+			cmp.Compare(a.Type, b.Type),
+			a.Key().Compare(b.Key()))
+	default:
+		c = cmp.Or(
+			cmp.Compare(a.Type, b.Type),
+			a.Key().Compare(b.Key()))
+	}
+	if f.Descending {
+		return -c
+	}
+	return c
+}
+
+// Apply returns the page of matching metrics in the filter order
+func (f MetricFilter) Apply(metrics []Metric) []Metric {
+	result := make([]Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		if f.Match(metric) {
+			result = append(result, metric)
+		}
+	}
+	slices.SortFunc(result, f.Compare)
+
+	offset := min(f.Offset, len(result))
+	result = result[offset:]
+	if f.Limit > 0 && f.Limit < len(result) {
+		result = result[:f.Limit]
+	}
+	return result
+}
+
+func (m Metric) sortValue() float64 {
+	switch m.Type {
+	case MetricTypeGauge:
+		return float64(m.Value)
+	case MetricTypeCounter:
+		return float64(m.Delta)
+	case MetricTypeHistogram:
+		return float64(m.Histogram.Count)
+	}
+	return 0
+}
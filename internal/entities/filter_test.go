@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricFilter_Apply(t *testing.T) {
+	gauge := Metric{Type: MetricTypeGauge, Name: "Alloc", Value: 3}
+	gaugeLabeled := Metric{Type: MetricTypeGauge, Name: "Alloc",
+		Labels: Labels{"host": "a"}, Value: 1}
+	counter := Metric{Type: MetricTypeCounter, Name: "PollCount", Delta: 2}
+	histogram := Metric{Type: MetricTypeHistogram, Name: "GCPause",
+		Histogram: Histogram{Count: 5}}
+	metrics := []Metric{histogram, counter, gaugeLabeled, gauge}
+
+	tests := []struct {
+		name   string
+		filter MetricFilter
+		want   []Metric
+	}{
+		{
+			name:   "default: by type",
+			filter: MetricFilter{},
+			want:   []Metric{gauge, gaugeLabeled, counter, histogram},
+		},
+		{
+			name:   "by name descending",
+			filter: MetricFilter{SortBy: SortByName, Descending: true},
+			want:   []Metric{counter, histogram, gaugeLabeled, gauge},
+		},
+		{
+			name:   "by value",
+			filter: MetricFilter{SortBy: SortByValue},
+			want:   []Metric{gaugeLabeled, counter, gauge, histogram},
+		},
+		{
+			name:   "types",
+			filter: MetricFilter{Types: []MetricType{MetricTypeCounter, MetricTypeHistogram}},
+			want:   []Metric{counter, histogram},
+		},
+		{
+			name:   "prefix",
+			filter: MetricFilter{NamePrefix: "All"},
+			want:   []Metric{gauge, gaugeLabeled},
+		},
+		{
+			name:   "regexp",
+			filter: MetricFilter{NameRegexp: regexp.MustCompile("Count$|^GC")},
+			want:   []Metric{counter, histogram},
+		},
+		{
+			name:   "pagination",
+			filter: MetricFilter{Offset: 1, Limit: 2},
+			want:   []Metric{gaugeLabeled, counter},
+		},
+		{
+			name:   "offset out of range",
+			filter: MetricFilter{Offset: 10},
+			want:   []Metric{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Apply(metrics))
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Idempotency identifies a batch of updates, which must be applied at most
+// once, even if the client sends it again, e.g. after a lost response
+type Idempotency struct {
+	// Key is generated by the client and is the same for all retries of the
+	// batch
+	Key string
+	// Fingerprint is the hash of the batch content; it detects reuse of the key
+	// for another batch
+	Fingerprint string
+}
+
+// NewIdempotency returns Idempotency of the batch of metrics sent with the key
+func NewIdempotency(key string, metrics []Metric) (Idempotency, error) {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return Idempotency{}, NewInternalError("batch fingerprint", err)
+	}
+	hash := sha256.Sum256(data)
+	return Idempotency{Key: key, Fingerprint: hex.EncodeToString(hash[:])}, nil
+}
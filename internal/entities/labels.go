@@ -0,0 +1,171 @@
+package entities
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Labels is optional set of metric labels, e.g. host or service
+type Labels map[string]string
+
+// Validate checks label names, they should be valid Prometheus label names
+func (l Labels) Validate() error {
+	for name := range l {
+		if !isValidLabelName(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidLabelName, name)
+		}
+	}
+	return nil
+}
+
+// String returns canonical representation of labels sorted by name, e.g.
+// `host="a",service="b"`; values are escaped the same way as in Prometheus
+// text exposition format. Empty labels are represented by empty string
+func (l Labels) String() string {
+	var sb strings.Builder
+	for i, name := range slices.Sorted(maps.Keys(l)) {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelValueReplacer.Replace(l[name]))
+		sb.WriteString(`"`)
+	}
+	return sb.String()
+}
+
+// ParseLabels parses labels in canonical representation, see Labels.String;
+// returns nil for empty string
+func ParseLabels(s string) (Labels, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	result := make(Labels)
+	for rest := s; ; {
+		name, value, ok := strings.Cut(rest, `="`)
+		if !ok || !isValidLabelName(name) {
+			return nil, fmt.Errorf("%w: %q", ErrMalformedLabels, s)
+		}
+		var sb strings.Builder
+		i := 0
+		for ; i < len(value) && value[i] != '"'; i++ {
+			if value[i] != '\\' {
+				sb.WriteByte(value[i])
+				continue
+			}
+			i++
+			if i == len(value) {
+				return nil, fmt.Errorf("%w: %q", ErrMalformedLabels, s)
+			}
+			switch value[i] {
+			case '\\', '"':
+				sb.WriteByte(value[i])
+			case 'n':
+				sb.WriteByte('\n')
+			default:
+				return nil, fmt.Errorf("%w: %q", ErrMalformedLabels, s)
+			}
+		}
+		if i == len(value) {
+			return nil, fmt.Errorf("%w: %q", ErrMalformedLabels, s)
+		}
+		result[name] = sb.String()
+		rest = value[i+1:]
+		if len(rest) == 0 {
+			return result, nil
+		}
+		if rest[0] != ',' {
+			return nil, fmt.Errorf("%w: %q", ErrMalformedLabels, s)
+		}
+		rest = rest[1:]
+	}
+}
+
+// Validate checks that the name is not empty and has no braces, otherwise
+// name{labels} form of MetricKey would be ambiguous, e.g. metric `a{b="c"}`
+// and metric `a` with label b=c
+func (n MetricName) Validate() error {
+	if len(n) == 0 {
+		return ErrEmptyMetricName
+	}
+	if strings.ContainsAny(string(n), "{}") {
+		return fmt.Errorf("%w: %q", ErrInvalidMetricName, n)
+	}
+	return nil
+}
+
+// MetricKey identifies metric of some type in storages: metric name and
+// labels in canonical representation
+type MetricKey struct {
+	Name   MetricName
+	Labels string
+}
+
+// ParseMetricKey parses key in the form returned by MetricKey.String; the
+// whole string is treated as a name if it has no valid labels suffix
+func ParseMetricKey(s string) MetricKey {
+	name, rest, ok := strings.Cut(s, "{")
+	if ok && strings.HasSuffix(rest, "}") {
+		labels := strings.TrimSuffix(rest, "}")
+		if _, err := ParseLabels(labels); err == nil && len(labels) > 0 {
+			return MetricKey{Name: MetricName(name), Labels: labels}
+		}
+	}
+	return MetricKey{Name: MetricName(s)}
+}
+
+// LabelSet returns parsed labels of the key; labels produced by
+// Labels.String are always parsed successfully
+func (k MetricKey) LabelSet() Labels {
+	labels, _ := ParseLabels(k.Labels)
+	return labels
+}
+
+// String returns key in the form `name{labels}`, or just `name` if metric has
+// no labels
+func (k MetricKey) String() string {
+	if len(k.Labels) == 0 {
+		return string(k.Name)
+	}
+	return string(k.Name) + "{" + k.Labels + "}"
+}
+
+// MarshalText allows using MetricKey as json object key
+func (k MetricKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText allows using MetricKey as json object key; plain metric
+// names, stored before labels were introduced, are also accepted
+func (k *MetricKey) UnmarshalText(text []byte) error {
+	*k = ParseMetricKey(string(text))
+	return nil
+}
+
+// Compare compares keys by name and then by labels, see strings.Compare
+func (k MetricKey) Compare(other MetricKey) int {
+	if c := strings.Compare(string(k.Name), string(other.Name)); c != 0 {
+		return c
+	}
+	return strings.Compare(k.Labels, other.Labels)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func isValidLabelName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
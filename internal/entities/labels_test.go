@@ -0,0 +1,63 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabels_String(t *testing.T) {
+	tests := []struct {
+		name  string
+		given Labels
+		want  string
+	}{
+		{name: "empty", given: nil, want: ""},
+		{name: "sorted", given: Labels{"service": "b", "host": "a"}, want: `host="a",service="b"`},
+		{name: "escaped", given: Labels{"x": "a\"b\\c\nd,e=\"f"}, want: `x="a\"b\\c\nd,e=\"f"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.given.String())
+			parsed, err := ParseLabels(tt.want)
+			require.NoError(t, err)
+			assert.Equal(t, tt.given, parsed)
+		})
+	}
+}
+
+func TestParseLabels_Malformed(t *testing.T) {
+	for _, given := range []string{`host`, `host="a`, `host="a"b`, `1x="a"`, `x="a\t"`, `x="a",`} {
+		t.Run(given, func(t *testing.T) {
+			_, err := ParseLabels(given)
+			assert.ErrorIs(t, err, ErrMalformedLabels)
+		})
+	}
+}
+
+func TestMetricName_Validate(t *testing.T) {
+	assert.NoError(t, MetricName("Alloc").Validate())
+	assert.ErrorIs(t, MetricName("").Validate(), ErrEmptyMetricName)
+	for _, given := range []MetricName{`a{b="c"}`, `a{`, `a}`} {
+		assert.ErrorIs(t, given.Validate(), ErrInvalidMetricName, given)
+	}
+}
+
+func TestParseMetricKey(t *testing.T) {
+	tests := []struct {
+		given string
+		want  MetricKey
+	}{
+		{given: "Alloc", want: MetricKey{Name: "Alloc"}},
+		{given: `Alloc{host="a"}`, want: MetricKey{Name: "Alloc", Labels: `host="a"`}},
+		{given: "Alloc{}", want: MetricKey{Name: "Alloc{}"}},
+		{given: "Alloc{host}", want: MetricKey{Name: "Alloc{host}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.given, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseMetricKey(tt.given))
+			assert.Equal(t, tt.given, tt.want.String())
+		})
+	}
+}
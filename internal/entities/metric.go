@@ -2,7 +2,10 @@ package entities
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type (
@@ -17,6 +20,7 @@ const (
 	MetricTypeUndefined MetricType = iota
 	MetricTypeGauge
 	MetricTypeCounter
+	MetricTypeHistogram
 )
 
 func (t MetricType) String() string {
@@ -28,6 +32,8 @@ func (t MetricType) String() string {
 		asStr = "gauge"
 	case MetricTypeCounter:
 		asStr = "counter"
+	case MetricTypeHistogram:
+		asStr = "histogram"
 	default:
 		asStr = "unexpected"
 	}
@@ -38,8 +44,113 @@ func (t MetricType) String() string {
 type Metric struct {
 	Type MetricType
 	Name MetricName
+	// Labels are optional, metrics with the same name and different labels
+	// are different metrics
+	Labels Labels
 	// .. данные MetricTypeGauge
 	Value Gauge
 	// .. данные MetricTypeCounter
 	Delta Counter
+	// .. данные MetricTypeHistogram
+	Histogram Histogram
+	// UpdatedAt is the time of the last update of the stored metric, filled by
+	// storage FindMetrics; zero if unknown
+	UpdatedAt time.Time
+}
+
+// Key returns metric identity
+func (m Metric) Key() MetricKey {
+	return MetricKey{
+		Name:   m.Name,
+		Labels: m.Labels.String(),
+	}
+}
+
+// Histogram contains distribution of observed values. Like counter, new
+// observations are added to the previous ones, so histograms with the same
+// name should have the same bucket bounds
+type Histogram struct {
+	// Bounds are upper inclusive bounds of buckets, strictly increasing
+	Bounds []float64
+	// Counts are numbers of observations per bucket (not cumulative), the last
+	// one is +Inf bucket, i.e. len(Counts) == len(Bounds)+1
+	Counts []uint64
+	// Count is total number of observations, i.e. sum of Counts
+	Count uint64
+	// Sum is sum of all observed values
+	Sum float64
+}
+
+// Validate checks histogram consistency
+func (h Histogram) Validate() error {
+	for i := 1; i < len(h.Bounds); i++ {
+		if h.Bounds[i] <= h.Bounds[i-1] {
+			return ErrHistogramBoundsNotIncreasing
+		}
+	}
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return ErrHistogramCountsLength
+	}
+	var count uint64
+	for _, c := range h.Counts {
+		count += c
+	}
+	if count != h.Count {
+		return ErrHistogramCountMismatch
+	}
+	return nil
+}
+
+// Clone returns deep copy of histogram
+func (h Histogram) Clone() Histogram {
+	return Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Count:  h.Count,
+		Sum:    h.Sum,
+	}
+}
+
+// Merge returns histogram, containing observations from both histograms
+func (h Histogram) Merge(other Histogram) (Histogram, error) {
+	if len(h.Bounds) != len(other.Bounds) {
+		return Histogram{}, ErrHistogramBoundsMismatch
+	}
+	for i := range h.Bounds {
+		if h.Bounds[i] != other.Bounds[i] {
+			return Histogram{}, ErrHistogramBoundsMismatch
+		}
+	}
+	if len(h.Counts) != len(other.Counts) {
+		return Histogram{}, ErrHistogramCountsLength
+	}
+	result := Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: make([]uint64, len(h.Counts)),
+		Count:  h.Count + other.Count,
+		Sum:    h.Sum + other.Sum,
+	}
+	for i := range h.Counts {
+		result.Counts[i] = h.Counts[i] + other.Counts[i]
+	}
+	return result, nil
+}
+
+// String returns human readable representation, e.g.
+// "count=3 sum=1.5 buckets=[0.5:1 1:1 +Inf:1]"
+func (h Histogram) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "count=%v sum=%v buckets=[", h.Count, h.Sum)
+	for i, c := range h.Counts {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		if i < len(h.Bounds) {
+			fmt.Fprintf(&sb, "%v:%v", h.Bounds[i], c)
+		} else {
+			fmt.Fprintf(&sb, "+Inf:%v", c)
+		}
+	}
+	sb.WriteString("]")
+	return sb.String()
 }
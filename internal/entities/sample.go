@@ -0,0 +1,17 @@
+package entities
+
+import "time"
+
+// Sample is metric value at some moment; for counters it's accumulated value
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// SampleRetention defines how long samples are kept: raw samples are kept for
+// Raw, then only their 1 minute aggregates are kept for Minute, after that only
+// 1 hour aggregates are kept
+type SampleRetention struct {
+	Raw    time.Duration
+	Minute time.Duration
+}
@@ -0,0 +1,12 @@
+package entities
+
+import "time"
+
+// StaleMetric is a metric, which was not updated for too long, e.g. because
+// the agent reporting it is dead
+type StaleMetric struct {
+	Metric Metric
+	// LastUpdate is the time of the last update; zero if unknown, e.g. for
+	// metrics stored by the previous server version
+	LastUpdate time.Time
+}
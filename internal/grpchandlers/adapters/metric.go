@@ -0,0 +1,160 @@
+package adapters
+
+import (
+	"fmt"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	pb "github.com/PiskarevSA/go-advanced/internal/proto"
+)
+
+func ConvertMetricFromGetMetricRequest(req *pb.GetMetricRequest) (*entities.Metric, error) {
+	var result entities.Metric
+	var err error
+	result.Type, err = convertMetricType(req.GetType())
+	if err != nil {
+		return nil, err
+	}
+	result.Name, err = convertMetricName(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	result.Labels, err = convertLabels(req.GetLabels())
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func ConvertMetricFromUpdateMetricRequest(req *pb.UpdateMetricRequest) (*entities.Metric, error) {
+	return ConvertProtoMetric(req.GetMetric())
+}
+
+func ConvertBatchMetricFromUpdateMetricsRequest(req *pb.UpdateMetricsRequest) ([]entities.Metric, error) {
+	result := make([]entities.Metric, 0, len(req.GetMetrics()))
+	for i, metric := range req.GetMetrics() {
+		entityMetric, err := ConvertProtoMetric(metric)
+		if err != nil {
+			return nil, fmt.Errorf("metric[%v]: %w", i, err)
+		}
+		result = append(result, *entityMetric)
+	}
+	return result, nil
+}
+
+func ConvertProtoMetric(metric *pb.Metric) (*entities.Metric, error) {
+	var result entities.Metric
+	var err error
+	result.Type, err = convertMetricType(metric.GetType())
+	if err != nil {
+		return nil, err
+	}
+	result.Name, err = convertMetricName(metric.GetId())
+	if err != nil {
+		return nil, err
+	}
+	result.Labels, err = convertLabels(metric.GetLabels())
+	if err != nil {
+		return nil, err
+	}
+	switch result.Type {
+	case entities.MetricTypeGauge:
+		result.Value = entities.Gauge(metric.GetValue())
+	case entities.MetricTypeCounter:
+		result.Delta = entities.Counter(metric.GetDelta())
+	case entities.MetricTypeHistogram:
+		if len(metric.GetCounts()) == 0 {
+			return nil, entities.ErrMissingHistogram
+		}
+		result.Histogram = entities.Histogram{
+			Bounds: metric.GetBuckets(),
+			Counts: metric.GetCounts(),
+			Count:  metric.GetCount(),
+			Sum:    metric.GetSum(),
+		}
+		if err := result.Histogram.Validate(); err != nil {
+			return nil, entities.NewMetricValueIsNotValidError(err)
+		}
+	default:
+		return nil, entities.NewInternalError(
+			"unexpected internal metric type: "+result.Type.String(), nil)
+	}
+	return &result, nil
+}
+
+func ConvertEntityMetric(metric entities.Metric) (*pb.Metric, error) {
+	result := pb.Metric{
+		Id: string(metric.Name),
+	}
+	if len(metric.Labels) > 0 {
+		result.Labels = metric.Labels
+	}
+	switch metric.Type {
+	case entities.MetricTypeGauge:
+		result.Type = pb.Metric_GAUGE
+		result.Value = float64(metric.Value)
+	case entities.MetricTypeCounter:
+		result.Type = pb.Metric_COUNTER
+		result.Delta = int64(metric.Delta)
+	case entities.MetricTypeHistogram:
+		result.Type = pb.Metric_HISTOGRAM
+		result.Buckets = metric.Histogram.Bounds
+		result.Counts = metric.Histogram.Counts
+		result.Count = metric.Histogram.Count
+		result.Sum = metric.Histogram.Sum
+	default:
+		return nil, entities.NewInternalError(
+			"unexpected internal metric type: "+metric.Type.String(), nil)
+	}
+	return &result, nil
+}
+
+func ConvertEntityMetrics(metrics []entities.Metric) ([]*pb.Metric, error) {
+	result := make([]*pb.Metric, 0, len(metrics))
+	for i, entityMetric := range metrics {
+		metric, err := ConvertEntityMetric(entityMetric)
+		if err != nil {
+			return nil, fmt.Errorf("metric[%v]: %w", i, err)
+		}
+		result = append(result, metric)
+	}
+	return result, nil
+}
+
+func convertMetricType(metricType pb.Metric_MType) (entities.MetricType, error) {
+	switch metricType {
+	case pb.Metric_GAUGE:
+		return entities.MetricTypeGauge, nil
+	case pb.Metric_COUNTER:
+		return entities.MetricTypeCounter, nil
+	case pb.Metric_HISTOGRAM:
+		return entities.MetricTypeHistogram, nil
+	}
+	return entities.MetricTypeUndefined, entities.NewInvalidMetricTypeError(metricType.String())
+}
+
+func convertMetricName(metricName string) (entities.MetricName, error) {
+	result := entities.MetricName(metricName)
+	if err := result.Validate(); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// convertLabels returns nil if there are no labels; labels with empty values
+// are the same as absent ones
+func convertLabels(labels map[string]string) (entities.Labels, error) {
+	var result entities.Labels
+	for name, value := range labels {
+		if len(value) == 0 {
+			continue
+		}
+		if result == nil {
+			result = make(entities.Labels, len(labels))
+		}
+		result[name] = value
+	}
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
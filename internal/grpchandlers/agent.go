@@ -0,0 +1,30 @@
+package grpchandlers
+
+import (
+	"context"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys, which agent uses to identify itself (gRPC analogues of
+// X-Agent-ID and X-Agent-Hostname headers)
+const (
+	AgentIDKey       = "x-agent-id"
+	AgentHostnameKey = "x-agent-hostname"
+)
+
+// agentFromContext returns agent, which made the call, or nil if the call
+// is made not by agent, i.e. has no x-agent-id metadata
+func agentFromContext(ctx context.Context) *entities.Agent {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ids := md.Get(AgentIDKey)
+	if len(ids) == 0 || len(ids[0]) == 0 {
+		return nil
+	}
+	result := entities.Agent{ID: ids[0]}
+	if hostnames := md.Get(AgentHostnameKey); len(hostnames) > 0 {
+		result.Hostname = hostnames[0]
+	}
+	return &result
+}
@@ -0,0 +1,24 @@
+package grpchandlers
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys of idempotent UpdateMetrics calls (gRPC analogues of
+// Idempotency-Key and Idempotent-Replayed headers)
+const (
+	IdempotencyKeyKey     = "idempotency-key"
+	IdempotentReplayedKey = "idempotent-replayed"
+)
+
+// idempotencyKeyFromContext returns idempotency key of the call; empty if the
+// call has no idempotency-key metadata
+func idempotencyKeyFromContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get(IdempotencyKeyKey); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
@@ -0,0 +1,150 @@
+package grpchandlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// IntegrityKey is the metadata key holding HMAC-SHA256 signature of the
+// deterministically marshaled message (gRPC analogue of HashSHA256 header)
+const IntegrityKey = "hashsha256"
+
+// Sign returns hex encoded HMAC-SHA256 signature of the message
+func Sign(message proto.Message, key string) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	sign := h.Sum(nil)
+	return hex.EncodeToString(sign[:]), nil
+}
+
+// KeyIDKey is the metadata key holding id of the key, which IntegrityKey is
+// calculated with (gRPC analogue of KeyID header); missing key id means the
+// key with empty id
+const KeyIDKey = "keyid"
+
+// SignedMetadata returns metadata pairs holding signature of the message
+func SignedMetadata(message proto.Message, key keyring.Key) ([]string, error) {
+	hexSum, err := Sign(message, key.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(key.ID) == 0 {
+		return []string{IntegrityKey, hexSum}, nil
+	}
+	return []string{IntegrityKey, hexSum, KeyIDKey, key.ID}, nil
+}
+
+// Integrity returns unary interceptor, which verifies request signature and
+// signs response using provided key
+func Integrity(key string) grpc.UnaryServerInterceptor {
+	return IntegrityKeyring(keyring.New(keyring.Key{Secret: key}))
+}
+
+// IntegrityKeyring returns unary interceptor, which verifies request signed
+// with any of the keys and signs response with the key of the request
+func IntegrityKeyring(keys *keyring.Keyring) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		// keys may appear or disappear on reload
+		if keys.Empty() {
+			return handler(ctx, req)
+		}
+		key, err := verifyRequest(ctx, req, keys)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := signResponse(ctx, res, key); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+// VerifyResponse checks signature of the response in the header received
+// by the client, see grpc.Header
+func VerifyResponse(res proto.Message, header metadata.MD, keys *keyring.Keyring) error {
+	expectedHexSum := header.Get(IntegrityKey)
+	if len(expectedHexSum) == 0 {
+		return errors.New("missing signature")
+	}
+	var keyID string
+	if ids := header.Get(KeyIDKey); len(ids) > 0 {
+		keyID = ids[0]
+	}
+	key, err := keys.Lookup(keyID)
+	if err != nil {
+		return err
+	}
+	actualHexSum, err := Sign(res, key.Secret)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(actualHexSum), []byte(expectedHexSum[0])) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// verifyRequest rejects unsigned requests and returns the key of the request
+func verifyRequest(ctx context.Context, req any, keys *keyring.Keyring,
+) (keyring.Key, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	expectedHexSum := md.Get(IntegrityKey)
+	if len(expectedHexSum) == 0 {
+		return keyring.Key{}, status.Error(codes.InvalidArgument, "missing signature")
+	}
+	var keyID string
+	if ids := md.Get(KeyIDKey); len(ids) > 0 {
+		keyID = ids[0]
+	}
+	key, err := keys.Lookup(keyID)
+	if err != nil {
+		return keyring.Key{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	message, ok := req.(proto.Message)
+	if !ok {
+		return keyring.Key{}, status.Error(codes.Internal, "unexpected request type")
+	}
+	actualHexSum, err := Sign(message, key.Secret)
+	if err != nil {
+		return keyring.Key{}, status.Error(codes.Internal, err.Error())
+	}
+	if !hmac.Equal([]byte(actualHexSum), []byte(expectedHexSum[0])) {
+		return keyring.Key{}, status.Error(codes.InvalidArgument, "invalid signature")
+	}
+	return key, nil
+}
+
+func signResponse(ctx context.Context, res any, key keyring.Key) error {
+	message, ok := res.(proto.Message)
+	if !ok {
+		return status.Error(codes.Internal, "unexpected response type")
+	}
+	pairs, err := SignedMetadata(message, key)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return grpc.SetHeader(ctx, metadata.Pairs(pairs...))
+}
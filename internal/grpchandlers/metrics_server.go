@@ -0,0 +1,210 @@
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/grpchandlers/adapters"
+	pb "github.com/PiskarevSA/go-advanced/internal/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const timeout = 15 * time.Second
+
+type metricsUsecase interface {
+	GetMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	UpdateMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	UpdateMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+	UpdateAgentMetrics(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error)
+	UpdateMetricsOnce(ctx context.Context, key string, agent *entities.Agent,
+		metrics []entities.Metric) ([]entities.Metric, bool, error)
+	ListMetrics(ctx context.Context) ([]entities.Metric, error)
+	Ping(ctx context.Context) error
+}
+
+// MetricsServer implements gRPC service Metrics on top of the same usecase as
+// handlers.MetricsRouter
+type MetricsServer struct {
+	pb.UnimplementedMetricsServer
+	metricsUsecase metricsUsecase
+}
+
+func NewMetricsServer(usecase metricsUsecase) *MetricsServer {
+	return &MetricsServer{
+		metricsUsecase: usecase,
+	}
+}
+
+// UpdateMetric handles rpc Metrics.UpdateMetric, the same as POST /update/
+func (s *MetricsServer) UpdateMetric(ctx context.Context, req *pb.UpdateMetricRequest,
+) (*pb.UpdateMetricResponse, error) {
+	validMetric, err := adapters.ConvertMetricFromUpdateMetricRequest(req)
+	if err != nil {
+		return nil, handleUpdateError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	updatedMetric, err := s.metricsUsecase.UpdateMetric(ctx, *validMetric)
+	if err != nil {
+		return nil, handleUpdateError(err)
+	}
+	// success
+	response, err := adapters.ConvertEntityMetric(*updatedMetric)
+	if err != nil {
+		return nil, handleUpdateError(err)
+	}
+	return &pb.UpdateMetricResponse{Metric: response}, nil
+}
+
+// UpdateMetrics handles rpc Metrics.UpdateMetrics, the same as POST /updates/
+func (s *MetricsServer) UpdateMetrics(ctx context.Context, req *pb.UpdateMetricsRequest,
+) (*pb.UpdateMetricsResponse, error) {
+	validMetrics, err := adapters.ConvertBatchMetricFromUpdateMetricsRequest(req)
+	if err != nil {
+		return nil, handleUpdateError(err)
+	}
+	agent := agentFromContext(ctx)
+	idempotencyKey := idempotencyKeyFromContext(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var updatedMetrics []entities.Metric
+	var replayed bool
+	switch {
+	case len(idempotencyKey) > 0:
+		updatedMetrics, replayed, err = s.metricsUsecase.UpdateMetricsOnce(ctx,
+			idempotencyKey, agent, validMetrics)
+	case agent != nil:
+		updatedMetrics, err = s.metricsUsecase.UpdateAgentMetrics(ctx, *agent, validMetrics)
+	default:
+		updatedMetrics, err = s.metricsUsecase.UpdateMetrics(ctx, validMetrics)
+	}
+	if err != nil {
+		return nil, handleUpdateError(err)
+	}
+	if replayed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(IdempotentReplayedKey, "true"))
+	}
+	// success
+	response, err := adapters.ConvertEntityMetrics(updatedMetrics)
+	if err != nil {
+		return nil, handleUpdateError(err)
+	}
+	return &pb.UpdateMetricsResponse{Metrics: response}, nil
+}
+
+// GetMetric handles rpc Metrics.GetMetric, the same as POST /value/
+func (s *MetricsServer) GetMetric(ctx context.Context, req *pb.GetMetricRequest,
+) (*pb.GetMetricResponse, error) {
+	validMetric, err := adapters.ConvertMetricFromGetMetricRequest(req)
+	if err != nil {
+		return nil, handleGetterError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	responseMetric, err := s.metricsUsecase.GetMetric(ctx, *validMetric)
+	if err != nil {
+		return nil, handleGetterError(err)
+	}
+	// success
+	response, err := adapters.ConvertEntityMetric(*responseMetric)
+	if err != nil {
+		return nil, handleGetterError(err)
+	}
+	return &pb.GetMetricResponse{Metric: response}, nil
+}
+
+// ListMetrics handles rpc Metrics.ListMetrics, i.e. returns all known metrics
+func (s *MetricsServer) ListMetrics(ctx context.Context, req *pb.ListMetricsRequest,
+) (*pb.ListMetricsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	metrics, err := s.metricsUsecase.ListMetrics(ctx)
+	if err != nil {
+		return nil, handleAsInternalError(err)
+	}
+	// success
+	response, err := adapters.ConvertEntityMetrics(metrics)
+	if err != nil {
+		return nil, handleAsInternalError(err)
+	}
+	return &pb.ListMetricsResponse{Metrics: response}, nil
+}
+
+// Ping handles rpc Metrics.Ping, the same as GET /ping
+func (s *MetricsServer) Ping(ctx context.Context, req *pb.PingRequest,
+) (*pb.PingResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := s.metricsUsecase.Ping(ctx); err != nil {
+		return nil, handleAsInternalError(err)
+	}
+	return &pb.PingResponse{}, nil
+}
+
+func handleGetterError(err error) error {
+	var (
+		invalidMetricTypeError  *entities.InvalidMetricTypeError
+		metricNameNotFoundError *entities.MetricNameNotFoundError
+	)
+	var code codes.Code
+	switch {
+	case errors.Is(err, entities.ErrEmptyMetricName):
+		code = codes.InvalidArgument
+	case errors.Is(err, entities.ErrInvalidLabelName):
+		code = codes.InvalidArgument
+	case errors.Is(err, entities.ErrInvalidMetricName):
+		code = codes.InvalidArgument
+	case errors.As(err, &invalidMetricTypeError):
+		code = codes.InvalidArgument
+	case errors.As(err, &metricNameNotFoundError):
+		code = codes.NotFound
+	default:
+		// internal or unexpected error
+		code = codes.Internal
+	}
+	slog.Error("[grpc] getter error handled", "error", err)
+	return status.Error(code, err.Error())
+}
+
+func handleUpdateError(err error) error {
+	var (
+		invalidMetricTypeError     *entities.InvalidMetricTypeError
+		metricValueIsNotValidError *entities.MetricValueIsNotValidError
+	)
+	var code codes.Code
+	switch {
+	case errors.As(err, &invalidMetricTypeError):
+		code = codes.InvalidArgument
+	case errors.Is(err, entities.ErrEmptyMetricName):
+		code = codes.InvalidArgument
+	case errors.As(err, &metricValueIsNotValidError):
+		code = codes.InvalidArgument
+	case errors.Is(err, entities.ErrMissingHistogram):
+		code = codes.InvalidArgument
+	case errors.Is(err, entities.ErrInvalidLabelName):
+		code = codes.InvalidArgument
+	case errors.Is(err, entities.ErrInvalidMetricName):
+		code = codes.InvalidArgument
+	case errors.Is(err, entities.ErrIdempotencyKeyReused):
+		code = codes.FailedPrecondition
+	default:
+		// internal or unexpected error
+		code = codes.Internal
+	}
+	slog.Error("[grpc] update error handled", "error", err)
+	return status.Error(code, err.Error())
+}
+
+func handleAsInternalError(err error) error {
+	slog.Error("[grpc] internal error handled", "error", err)
+	return status.Error(codes.Internal, err.Error())
+}
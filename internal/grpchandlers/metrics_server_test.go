@@ -0,0 +1,345 @@
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	pb "github.com/PiskarevSA/go-advanced/internal/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func testClient(t *testing.T, usecase metricsUsecase, key string) pb.MetricsClient {
+	return testClientWithIntegrity(t, usecase, Integrity(key))
+}
+
+func testClientWithIntegrity(t *testing.T, usecase metricsUsecase,
+	integrity grpc.UnaryServerInterceptor,
+) pb.MetricsClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(integrity))
+	pb.RegisterMetricsServer(server, NewMetricsServer(usecase))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsClient(conn)
+}
+
+func TestUpdateMetrics(t *testing.T) {
+	type given struct {
+		request     *pb.UpdateMetricsRequest
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code      codes.Code
+		response  *pb.UpdateMetricsResponse
+		callCount int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "update batch: positive",
+			given: given{
+				request: &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+					{Id: "foo", Type: pb.Metric_GAUGE, Value: 1.23},
+					{Id: "bar", Type: pb.Metric_COUNTER, Delta: 456},
+				}},
+				mockUsecase: &mockMetricsUsecase{
+					UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric,
+					) ([]entities.Metric, error) {
+						expectedMetrics := []entities.Metric{
+							{
+								Type:  entities.MetricTypeGauge,
+								Name:  "foo",
+								Value: 1.23,
+							},
+							{
+								Type:  entities.MetricTypeCounter,
+								Name:  "bar",
+								Delta: 456,
+							},
+						}
+						require.Equal(t, expectedMetrics, metrics)
+						return expectedMetrics, nil
+					},
+				},
+			},
+			want: want{
+				code: codes.OK,
+				response: &pb.UpdateMetricsResponse{Metrics: []*pb.Metric{
+					{Id: "foo", Type: pb.Metric_GAUGE, Value: 1.23},
+					{Id: "bar", Type: pb.Metric_COUNTER, Delta: 456},
+				}},
+				callCount: 1,
+			},
+		},
+		{
+			name: "update batch: empty metric type",
+			given: given{
+				request: &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+					{Id: "foo"},
+				}},
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:      codes.InvalidArgument,
+				callCount: 0,
+			},
+		},
+		{
+			name: "update batch: empty metric name",
+			given: given{
+				request: &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+					{Type: pb.Metric_GAUGE},
+				}},
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:      codes.InvalidArgument,
+				callCount: 0,
+			},
+		},
+		{
+			name: "update batch: storage error",
+			given: given{
+				request: &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+					{Id: "foo", Type: pb.Metric_GAUGE, Value: 1.23},
+				}},
+				mockUsecase: &mockMetricsUsecase{
+					UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric,
+					) ([]entities.Metric, error) {
+						return nil, entities.NewInternalError("sql query error", nil)
+					},
+				},
+			},
+			want: want{
+				code:      codes.Internal,
+				callCount: 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testClient(t, tt.given.mockUsecase, "")
+			response, err := client.UpdateMetrics(context.Background(), tt.given.request)
+			assert.Equal(t, tt.want.code, status.Code(err))
+			if tt.want.response != nil {
+				assert.True(t, proto.Equal(tt.want.response, response))
+			}
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.UpdateMetrics))
+		})
+	}
+}
+
+func TestGetMetric(t *testing.T) {
+	type given struct {
+		request     *pb.GetMetricRequest
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code      codes.Code
+		response  *pb.GetMetricResponse
+		callCount int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "value: counter positive",
+			given: given{
+				request: &pb.GetMetricRequest{Id: "bar", Type: pb.Metric_COUNTER},
+				mockUsecase: &mockMetricsUsecase{
+					GetMetricFunc: func(ctx context.Context, metric entities.Metric,
+					) (*entities.Metric, error) {
+						require.Equal(t, entities.MetricTypeCounter, metric.Type)
+						require.Equal(t, entities.MetricName("bar"), metric.Name)
+						return &entities.Metric{
+							Type:  entities.MetricTypeCounter,
+							Name:  "bar",
+							Delta: 456,
+						}, nil
+					},
+				},
+			},
+			want: want{
+				code: codes.OK,
+				response: &pb.GetMetricResponse{
+					Metric: &pb.Metric{Id: "bar", Type: pb.Metric_COUNTER, Delta: 456},
+				},
+				callCount: 1,
+			},
+		},
+		{
+			name: "value: unknown metric type",
+			given: given{
+				request:     &pb.GetMetricRequest{Id: "bar", Type: pb.Metric_MType(42)},
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:      codes.InvalidArgument,
+				callCount: 0,
+			},
+		},
+		{
+			name: "value: unknown metric name",
+			given: given{
+				request: &pb.GetMetricRequest{Id: "foo", Type: pb.Metric_GAUGE},
+				mockUsecase: &mockMetricsUsecase{
+					GetMetricFunc: func(ctx context.Context, metric entities.Metric,
+					) (*entities.Metric, error) {
+						return nil, entities.NewMetricNameNotFoundError("foo")
+					},
+				},
+			},
+			want: want{
+				code:      codes.NotFound,
+				callCount: 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := testClient(t, tt.given.mockUsecase, "")
+			response, err := client.GetMetric(context.Background(), tt.given.request)
+			assert.Equal(t, tt.want.code, status.Code(err))
+			if tt.want.response != nil {
+				assert.True(t, proto.Equal(tt.want.response, response))
+			}
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.GetMetric))
+		})
+	}
+}
+
+func TestListMetrics(t *testing.T) {
+	mockUsecase := &mockMetricsUsecase{
+		ListMetricsFunc: func(ctx context.Context) ([]entities.Metric, error) {
+			return []entities.Metric{
+				{Type: entities.MetricTypeGauge, Name: "foo", Value: 1.23},
+				{Type: entities.MetricTypeCounter, Name: "bar", Delta: 456},
+			}, nil
+		},
+	}
+	client := testClient(t, mockUsecase, "")
+	response, err := client.ListMetrics(context.Background(), &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+	expected := &pb.ListMetricsResponse{Metrics: []*pb.Metric{
+		{Id: "foo", Type: pb.Metric_GAUGE, Value: 1.23},
+		{Id: "bar", Type: pb.Metric_COUNTER, Delta: 456},
+	}}
+	assert.True(t, proto.Equal(expected, response))
+}
+
+func TestPing(t *testing.T) {
+	mockUsecase := &mockMetricsUsecase{
+		PingFunc: func(ctx context.Context) error {
+			return errors.New("some error")
+		},
+	}
+	client := testClient(t, mockUsecase, "")
+	_, err := client.Ping(context.Background(), &pb.PingRequest{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, 1, len(mockUsecase.calls.Ping))
+}
+
+func TestIntegrity(t *testing.T) {
+	const key = "secret"
+	mockUsecase := &mockMetricsUsecase{
+		PingFunc: func(ctx context.Context) error { return nil },
+	}
+	client := testClient(t, mockUsecase, key)
+	req := &pb.PingRequest{}
+
+	// missing signature
+	_, err := client.Ping(context.Background(), req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 0, len(mockUsecase.calls.Ping))
+
+	// invalid signature
+	ctx := metadata.AppendToOutgoingContext(context.Background(), IntegrityKey, "invalid")
+	_, err = client.Ping(ctx, req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 0, len(mockUsecase.calls.Ping))
+
+	// valid signature, response is signed too
+	hexSum, err := Sign(req, key)
+	require.NoError(t, err)
+	ctx = metadata.AppendToOutgoingContext(context.Background(), IntegrityKey, hexSum)
+	var header metadata.MD
+	res, err := client.Ping(ctx, req, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(mockUsecase.calls.Ping))
+	expectedHexSum, err := Sign(res, key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{expectedHexSum}, header.Get(IntegrityKey))
+	keys := keyring.New(keyring.Key{Secret: key})
+	assert.NoError(t, VerifyResponse(res, header, keys))
+	assert.Error(t, VerifyResponse(res, header, keyring.New(keyring.Key{Secret: "wrong"})))
+	assert.Error(t, VerifyResponse(res, metadata.MD{}, keys))
+}
+
+func TestIntegrityKeyring(t *testing.T) {
+	mockUsecase := &mockMetricsUsecase{
+		PingFunc: func(ctx context.Context) error { return nil },
+	}
+	serverKeys := keyring.New(
+		keyring.Key{ID: "new", Secret: "new secret"},
+		keyring.Key{ID: "old", Secret: "old secret"},
+	)
+	client := testClientWithIntegrity(t, mockUsecase, IntegrityKeyring(serverKeys))
+	req := &pb.PingRequest{}
+
+	tests := []struct {
+		name string
+		key  keyring.Key
+		code codes.Code
+	}{
+		{name: "primary key", key: keyring.Key{ID: "new", Secret: "new secret"}, code: codes.OK},
+		{name: "accepted key", key: keyring.Key{ID: "old", Secret: "old secret"}, code: codes.OK},
+		{name: "unknown key id", key: keyring.Key{ID: "older", Secret: "old secret"}, code: codes.InvalidArgument},
+		{name: "key without id", key: keyring.Key{Secret: "new secret"}, code: codes.InvalidArgument},
+		{name: "wrong key", key: keyring.Key{ID: "old", Secret: "new secret"}, code: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pairs, err := SignedMetadata(req, tt.key)
+			require.NoError(t, err)
+			ctx := metadata.AppendToOutgoingContext(context.Background(), pairs...)
+			var header metadata.MD
+			res, err := client.Ping(ctx, req, grpc.Header(&header))
+			require.Equal(t, tt.code, status.Code(err))
+			if tt.code != codes.OK {
+				return
+			}
+			// response is signed with the key of the request
+			assert.Equal(t, []string{tt.key.ID}, header.Get(KeyIDKey))
+			assert.NoError(t, VerifyResponse(res, header, keyring.New(tt.key)))
+		})
+	}
+}
@@ -0,0 +1,390 @@
+// Code generated by mockery; DO NOT EDIT.
+// github.com/vektra/mockery
+// template: matryer
+
+package grpchandlers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// Ensure that mockMetricsUsecase does implement metricsUsecase.
+// If this is not the case, regenerate this file with mockery.
+var _ metricsUsecase = &mockMetricsUsecase{}
+
+// mockMetricsUsecase is a mock implementation of metricsUsecase.
+//
+//	func TestSomethingThatUsesmetricsUsecase(t *testing.T) {
+//
+//		// make and configure a mocked metricsUsecase
+//		mockedmetricsUsecase := &mockMetricsUsecase{
+//			GetMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+//				panic("mock out the GetMetric method")
+//			},
+//			ListMetricsFunc: func(ctx context.Context) ([]entities.Metric, error) {
+//				panic("mock out the ListMetrics method")
+//			},
+//			PingFunc: func(ctx context.Context) error {
+//				panic("mock out the Ping method")
+//			},
+//			UpdateAgentMetricsFunc: func(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error) {
+//				panic("mock out the UpdateAgentMetrics method")
+//			},
+//			UpdateMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+//				panic("mock out the UpdateMetric method")
+//			},
+//			UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
+//				panic("mock out the UpdateMetrics method")
+//			},
+//			UpdateMetricsOnceFunc: func(ctx context.Context, key string, agent *entities.Agent, metrics []entities.Metric) ([]entities.Metric, bool, error) {
+//				panic("mock out the UpdateMetricsOnce method")
+//			},
+//		}
+//
+//		// use mockedmetricsUsecase in code that requires metricsUsecase
+//		// and then make assertions.
+//
+//	}
+type mockMetricsUsecase struct {
+	// GetMetricFunc mocks the GetMetric method.
+	GetMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+
+	// ListMetricsFunc mocks the ListMetrics method.
+	ListMetricsFunc func(ctx context.Context) ([]entities.Metric, error)
+
+	// PingFunc mocks the Ping method.
+	PingFunc func(ctx context.Context) error
+
+	// UpdateAgentMetricsFunc mocks the UpdateAgentMetrics method.
+	UpdateAgentMetricsFunc func(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error)
+
+	// UpdateMetricFunc mocks the UpdateMetric method.
+	UpdateMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+
+	// UpdateMetricsFunc mocks the UpdateMetrics method.
+	UpdateMetricsFunc func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+
+	// UpdateMetricsOnceFunc mocks the UpdateMetricsOnce method.
+	UpdateMetricsOnceFunc func(ctx context.Context, key string, agent *entities.Agent, metrics []entities.Metric) ([]entities.Metric, bool, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// GetMetric holds details about calls to the GetMetric method.
+		GetMetric []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+		}
+		// ListMetrics holds details about calls to the ListMetrics method.
+		ListMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// Ping holds details about calls to the Ping method.
+		Ping []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// UpdateAgentMetrics holds details about calls to the UpdateAgentMetrics method.
+		UpdateAgentMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Agent is the agent argument value.
+			Agent entities.Agent
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
+		// UpdateMetric holds details about calls to the UpdateMetric method.
+		UpdateMetric []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+		}
+		// UpdateMetrics holds details about calls to the UpdateMetrics method.
+		UpdateMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
+		// UpdateMetricsOnce holds details about calls to the UpdateMetricsOnce method.
+		UpdateMetricsOnce []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Agent is the agent argument value.
+			Agent *entities.Agent
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
+	}
+	lockGetMetric          sync.RWMutex
+	lockListMetrics        sync.RWMutex
+	lockPing               sync.RWMutex
+	lockUpdateAgentMetrics sync.RWMutex
+	lockUpdateMetric       sync.RWMutex
+	lockUpdateMetrics      sync.RWMutex
+	lockUpdateMetricsOnce  sync.RWMutex
+}
+
+// GetMetric calls GetMetricFunc.
+func (mock *mockMetricsUsecase) GetMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+	if mock.GetMetricFunc == nil {
+		panic("mockMetricsUsecase.GetMetricFunc: method is nil but metricsUsecase.GetMetric was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+	}
+	mock.lockGetMetric.Lock()
+	mock.calls.GetMetric = append(mock.calls.GetMetric, callInfo)
+	mock.lockGetMetric.Unlock()
+	return mock.GetMetricFunc(ctx, metric)
+}
+
+// GetMetricCalls gets all the calls that were made to GetMetric.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.GetMetricCalls())
+func (mock *mockMetricsUsecase) GetMetricCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}
+	mock.lockGetMetric.RLock()
+	calls = mock.calls.GetMetric
+	mock.lockGetMetric.RUnlock()
+	return calls
+}
+
+// ListMetrics calls ListMetricsFunc.
+func (mock *mockMetricsUsecase) ListMetrics(ctx context.Context) ([]entities.Metric, error) {
+	if mock.ListMetricsFunc == nil {
+		panic("mockMetricsUsecase.ListMetricsFunc: method is nil but metricsUsecase.ListMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListMetrics.Lock()
+	mock.calls.ListMetrics = append(mock.calls.ListMetrics, callInfo)
+	mock.lockListMetrics.Unlock()
+	return mock.ListMetricsFunc(ctx)
+}
+
+// ListMetricsCalls gets all the calls that were made to ListMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.ListMetricsCalls())
+func (mock *mockMetricsUsecase) ListMetricsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListMetrics.RLock()
+	calls = mock.calls.ListMetrics
+	mock.lockListMetrics.RUnlock()
+	return calls
+}
+
+// Ping calls PingFunc.
+func (mock *mockMetricsUsecase) Ping(ctx context.Context) error {
+	if mock.PingFunc == nil {
+		panic("mockMetricsUsecase.PingFunc: method is nil but metricsUsecase.Ping was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockPing.Lock()
+	mock.calls.Ping = append(mock.calls.Ping, callInfo)
+	mock.lockPing.Unlock()
+	return mock.PingFunc(ctx)
+}
+
+// PingCalls gets all the calls that were made to Ping.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.PingCalls())
+func (mock *mockMetricsUsecase) PingCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockPing.RLock()
+	calls = mock.calls.Ping
+	mock.lockPing.RUnlock()
+	return calls
+}
+
+// UpdateAgentMetrics calls UpdateAgentMetricsFunc.
+func (mock *mockMetricsUsecase) UpdateAgentMetrics(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error) {
+	if mock.UpdateAgentMetricsFunc == nil {
+		panic("mockMetricsUsecase.UpdateAgentMetricsFunc: method is nil but metricsUsecase.UpdateAgentMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Agent   entities.Agent
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Agent:   agent,
+		Metrics: metrics,
+	}
+	mock.lockUpdateAgentMetrics.Lock()
+	mock.calls.UpdateAgentMetrics = append(mock.calls.UpdateAgentMetrics, callInfo)
+	mock.lockUpdateAgentMetrics.Unlock()
+	return mock.UpdateAgentMetricsFunc(ctx, agent, metrics)
+}
+
+// UpdateAgentMetricsCalls gets all the calls that were made to UpdateAgentMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.UpdateAgentMetricsCalls())
+func (mock *mockMetricsUsecase) UpdateAgentMetricsCalls() []struct {
+	Ctx     context.Context
+	Agent   entities.Agent
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Agent   entities.Agent
+		Metrics []entities.Metric
+	}
+	mock.lockUpdateAgentMetrics.RLock()
+	calls = mock.calls.UpdateAgentMetrics
+	mock.lockUpdateAgentMetrics.RUnlock()
+	return calls
+}
+
+// UpdateMetric calls UpdateMetricFunc.
+func (mock *mockMetricsUsecase) UpdateMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+	if mock.UpdateMetricFunc == nil {
+		panic("mockMetricsUsecase.UpdateMetricFunc: method is nil but metricsUsecase.UpdateMetric was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+	}
+	mock.lockUpdateMetric.Lock()
+	mock.calls.UpdateMetric = append(mock.calls.UpdateMetric, callInfo)
+	mock.lockUpdateMetric.Unlock()
+	return mock.UpdateMetricFunc(ctx, metric)
+}
+
+// UpdateMetricCalls gets all the calls that were made to UpdateMetric.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.UpdateMetricCalls())
+func (mock *mockMetricsUsecase) UpdateMetricCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}
+	mock.lockUpdateMetric.RLock()
+	calls = mock.calls.UpdateMetric
+	mock.lockUpdateMetric.RUnlock()
+	return calls
+}
+
+// UpdateMetrics calls UpdateMetricsFunc.
+func (mock *mockMetricsUsecase) UpdateMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
+	if mock.UpdateMetricsFunc == nil {
+		panic("mockMetricsUsecase.UpdateMetricsFunc: method is nil but metricsUsecase.UpdateMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Metrics: metrics,
+	}
+	mock.lockUpdateMetrics.Lock()
+	mock.calls.UpdateMetrics = append(mock.calls.UpdateMetrics, callInfo)
+	mock.lockUpdateMetrics.Unlock()
+	return mock.UpdateMetricsFunc(ctx, metrics)
+}
+
+// UpdateMetricsCalls gets all the calls that were made to UpdateMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.UpdateMetricsCalls())
+func (mock *mockMetricsUsecase) UpdateMetricsCalls() []struct {
+	Ctx     context.Context
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Metrics []entities.Metric
+	}
+	mock.lockUpdateMetrics.RLock()
+	calls = mock.calls.UpdateMetrics
+	mock.lockUpdateMetrics.RUnlock()
+	return calls
+}
+
+// UpdateMetricsOnce calls UpdateMetricsOnceFunc.
+func (mock *mockMetricsUsecase) UpdateMetricsOnce(ctx context.Context, key string, agent *entities.Agent, metrics []entities.Metric) ([]entities.Metric, bool, error) {
+	if mock.UpdateMetricsOnceFunc == nil {
+		panic("mockMetricsUsecase.UpdateMetricsOnceFunc: method is nil but metricsUsecase.UpdateMetricsOnce was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Key     string
+		Agent   *entities.Agent
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Key:     key,
+		Agent:   agent,
+		Metrics: metrics,
+	}
+	mock.lockUpdateMetricsOnce.Lock()
+	mock.calls.UpdateMetricsOnce = append(mock.calls.UpdateMetricsOnce, callInfo)
+	mock.lockUpdateMetricsOnce.Unlock()
+	return mock.UpdateMetricsOnceFunc(ctx, key, agent, metrics)
+}
+
+// UpdateMetricsOnceCalls gets all the calls that were made to UpdateMetricsOnce.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.UpdateMetricsOnceCalls())
+func (mock *mockMetricsUsecase) UpdateMetricsOnceCalls() []struct {
+	Ctx     context.Context
+	Key     string
+	Agent   *entities.Agent
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Key     string
+		Agent   *entities.Agent
+		Metrics []entities.Metric
+	}
+	mock.lockUpdateMetricsOnce.RLock()
+	calls = mock.calls.UpdateMetricsOnce
+	mock.lockUpdateMetricsOnce.RUnlock()
+	return calls
+}
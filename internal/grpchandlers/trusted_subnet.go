@@ -0,0 +1,47 @@
+package grpchandlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RealIPKey is the metadata key holding agent address (gRPC analogue of
+// X-Real-IP header)
+const RealIPKey = "x-real-ip"
+
+// TrustedSubnet returns unary interceptor, which rejects calls with
+// codes.PermissionDenied if the address from x-real-ip metadata is missing or
+// doesn't belong to the subnet given in CIDR notation; all calls are accepted
+// if cidr is empty
+func TrustedSubnet(cidr string) (grpc.UnaryServerInterceptor, error) {
+	if len(cidr) == 0 {
+		return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (any, error) {
+			return handler(ctx, req)
+		}, nil
+	}
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("trusted subnet: %w", err)
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var ip net.IP
+		if values := md.Get(RealIPKey); len(values) > 0 {
+			ip = net.ParseIP(values[0])
+		}
+		if ip == nil || !subnet.Contains(ip) {
+			return nil, status.Error(codes.PermissionDenied, "untrusted client address")
+		}
+		return handler(ctx, req)
+	}, nil
+}
@@ -0,0 +1,50 @@
+package adapters
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+// ConvertAgentFromRequest returns agent, which sent the request, or nil if the
+// request is sent not by agent, i.e. has no models.AgentIDHeader
+func ConvertAgentFromRequest(req *http.Request) *entities.Agent {
+	id := req.Header.Get(models.AgentIDHeader)
+	if len(id) == 0 {
+		return nil
+	}
+	return &entities.Agent{
+		ID:       id,
+		Hostname: req.Header.Get(models.AgentHostnameHeader),
+	}
+}
+
+func ConvertEntityAgents(agents []entities.Agent) ([]models.Agent, error) {
+	result := make([]models.Agent, 0, len(agents))
+	for i, agent := range agents {
+		metrics := make([]models.Metric, 0, len(agent.Metrics))
+		for j, metric := range agent.Metrics {
+			metricType, err := convertEntityMetricType(metric.Type)
+			if err != nil {
+				return nil, fmt.Errorf("agent[%v]: metric[%v]: %w", i, j, err)
+			}
+			modelsMetric := models.Metric{
+				ID:    string(metric.Name),
+				MType: string(metricType),
+			}
+			if len(metric.Labels) > 0 {
+				modelsMetric.Labels = metric.Labels
+			}
+			metrics = append(metrics, modelsMetric)
+		}
+		result = append(result, models.Agent{
+			ID:       agent.ID,
+			Hostname: agent.Hostname,
+			LastSeen: agent.LastSeen,
+			Metrics:  metrics,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,35 @@
+package adapters
+
+import (
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+func ConvertEntityAlerts(alerts []entities.Alert) []models.Alert {
+	optionalTime := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		t = t.UTC()
+		return &t
+	}
+	result := make([]models.Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		modelsAlert := models.Alert{
+			Name:        alert.Rule.Name,
+			Expr:        alert.Rule.Expr,
+			State:       string(alert.State),
+			ActiveSince: optionalTime(alert.ActiveSince),
+			FiredAt:     optionalTime(alert.FiredAt),
+			ResolvedAt:  optionalTime(alert.ResolvedAt),
+		}
+		if alert.HasValue {
+			value := alert.Value
+			modelsAlert.Value = &value
+		}
+		result = append(result, modelsAlert)
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package adapters
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+var sortKeys = map[string]entities.MetricSortKey{
+	"":      entities.SortByType,
+	"type":  entities.SortByType,
+	"name":  entities.SortByName,
+	"value": entities.SortByValue,
+}
+
+// ConvertMetricFilterFromRequest parses url query parameters of GET /values
+func ConvertMetricFilterFromRequest(req *http.Request) (*entities.MetricFilter, error) {
+	query := req.URL.Query()
+	var result entities.MetricFilter
+	for _, value := range query["type"] {
+		for _, metricType := range strings.Split(value, ",") {
+			t, err := convertMetricType(metricType)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", entities.ErrInvalidMetricFilter, err)
+			}
+			result.Types = append(result.Types, t)
+		}
+	}
+
+	result.NamePrefix = query.Get("prefix")
+	if regex := query.Get("regex"); len(regex) > 0 {
+		var err error
+		if result.NameRegexp, err = regexp.Compile(regex); err != nil {
+			return nil, fmt.Errorf("%w: regex: %w", entities.ErrInvalidMetricFilter, err)
+		}
+	}
+
+	sortBy, ok := sortKeys[query.Get("sort")]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort: %s",
+			entities.ErrInvalidMetricFilter, query.Get("sort"))
+	}
+	result.SortBy = sortBy
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		result.Descending = true
+	default:
+		return nil, fmt.Errorf("%w: unknown order: %s", entities.ErrInvalidMetricFilter, order)
+	}
+
+	var err error
+	if result.Limit, err = convertNonNegative(query.Get("limit")); err != nil {
+		return nil, fmt.Errorf("%w: limit: %w", entities.ErrInvalidMetricFilter, err)
+	}
+	if result.Offset, err = convertNonNegative(query.Get("offset")); err != nil {
+		return nil, fmt.Errorf("%w: offset: %w", entities.ErrInvalidMetricFilter, err)
+	}
+	return &result, nil
+}
+
+// convertNonNegative returns 0 for empty value
+func convertNonNegative(value string) (int, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if result < 0 {
+		return 0, fmt.Errorf("negative value: %v", result)
+	}
+	return result, nil
+}
+
+// ConvertMainPageRequest parses url query parameters of GET /: the same as
+// GET /values and refresh interval in seconds, no auto-refresh if zero
+func ConvertMainPageRequest(req *http.Request) (filter *entities.MetricFilter,
+	refresh int, err error,
+) {
+	filter, err = ConvertMetricFilterFromRequest(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if refresh, err = convertNonNegative(req.URL.Query().Get("refresh")); err != nil {
+		return nil, 0, fmt.Errorf("%w: refresh: %w", entities.ErrInvalidMetricFilter, err)
+	}
+	return filter, refresh, nil
+}
@@ -0,0 +1,82 @@
+package adapters
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHistoryRange = time.Hour
+	defaultHistoryStep  = time.Minute
+)
+
+// HistoryQuery is the parsed request GET /history/{type}/{name}
+type HistoryQuery struct {
+	Metric entities.Metric
+	From   time.Time
+	To     time.Time
+	Step   time.Duration
+}
+
+// ConvertHistoryRequest parses url query parameters: from, to (RFC 3339,
+// default to now and an hour before to), step (Go duration, default 1m); other
+// parameters are metric labels
+func ConvertHistoryRequest(req *http.Request) (*HistoryQuery, error) {
+	metricType := chi.URLParam(req, "type")
+	metricName := chi.URLParam(req, "name")
+
+	var result HistoryQuery
+	var err error
+	result.Metric.Type, err = convertMetricType(metricType)
+	if err != nil {
+		return nil, err
+	}
+	result.Metric.Name, err = convertMetricName(metricName)
+	if err != nil {
+		return nil, err
+	}
+
+	query := req.URL.Query()
+	result.To = time.Now().UTC()
+	if to := query.Get("to"); len(to) > 0 {
+		if result.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, fmt.Errorf("%w: to: %w", entities.ErrInvalidHistoryQuery, err)
+		}
+	}
+	result.From = result.To.Add(-defaultHistoryRange)
+	if from := query.Get("from"); len(from) > 0 {
+		if result.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, fmt.Errorf("%w: from: %w", entities.ErrInvalidHistoryQuery, err)
+		}
+	}
+	result.Step = defaultHistoryStep
+	if step := query.Get("step"); len(step) > 0 {
+		if result.Step, err = time.ParseDuration(step); err != nil {
+			return nil, fmt.Errorf("%w: step: %w", entities.ErrInvalidHistoryQuery, err)
+		}
+	}
+	query.Del("from")
+	query.Del("to")
+	query.Del("step")
+	result.Metric.Labels, err = convertLabelsFromValues(query)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func ConvertEntitySamples(samples []entities.Sample) []models.Sample {
+	result := make([]models.Sample, 0, len(samples))
+	for _, sample := range samples {
+		result = append(result, models.Sample{
+			Time:  sample.Time.UTC(),
+			Value: sample.Value,
+		})
+	}
+	return result
+}
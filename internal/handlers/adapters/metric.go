@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/PiskarevSA/go-advanced/internal/entities"
@@ -16,8 +17,9 @@ type (
 )
 
 const (
-	MetricTypeGauge   MetricType = "gauge"
-	MetricTypeCounter MetricType = "counter"
+	MetricTypeGauge     MetricType = "gauge"
+	MetricTypeCounter   MetricType = "counter"
+	MetricTypeHistogram MetricType = "histogram"
 )
 
 func ConvertMetricFromGetAsJSONRequest(req *http.Request) (*entities.Metric, error) {
@@ -38,6 +40,10 @@ func ConvertMetricFromGetAsJSONRequest(req *http.Request) (*entities.Metric, err
 	if err != nil {
 		return nil, err
 	}
+	result.Labels, err = convertLabels(metric.Labels)
+	if err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
@@ -59,6 +65,10 @@ func ConvertMetricFromUpdateFromJSONRequest(req *http.Request) (*entities.Metric
 	if err != nil {
 		return nil, err
 	}
+	result.Labels, err = convertLabels(metric.Labels)
+	if err != nil {
+		return nil, err
+	}
 	switch result.Type {
 	case entities.MetricTypeGauge:
 		if metric.Value == nil {
@@ -70,6 +80,11 @@ func ConvertMetricFromUpdateFromJSONRequest(req *http.Request) (*entities.Metric
 			return nil, entities.ErrMissingDelta
 		}
 		result.Delta = entities.Counter(*metric.Delta)
+	case entities.MetricTypeHistogram:
+		result.Histogram, err = convertHistogram(metric)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, entities.NewInternalError(
 			"unexpected internal metric type: "+result.Type.String(), nil)
@@ -97,6 +112,10 @@ func ConvertBatchMetricFromUpdateFromJSONRequest(req *http.Request) ([]entities.
 		if err != nil {
 			return nil, fmt.Errorf("metric[%v]: %w", i, err)
 		}
+		entityMetric.Labels, err = convertLabels(metric.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("metric[%v]: %w", i, err)
+		}
 		switch entityMetric.Type {
 		case entities.MetricTypeGauge:
 			if metric.Value == nil {
@@ -108,6 +127,11 @@ func ConvertBatchMetricFromUpdateFromJSONRequest(req *http.Request) ([]entities.
 				return nil, fmt.Errorf("metric[%v]: %w", i, entities.ErrMissingDelta)
 			}
 			entityMetric.Delta = entities.Counter(*metric.Delta)
+		case entities.MetricTypeHistogram:
+			entityMetric.Histogram, err = convertHistogram(metric)
+			if err != nil {
+				return nil, fmt.Errorf("metric[%v]: %w", i, err)
+			}
 		default:
 			return nil, entities.NewInternalError(
 				fmt.Sprintf(
@@ -133,9 +157,60 @@ func ConvertMetricFromGetGetAsTextRequest(req *http.Request) (*entities.Metric,
 	if err != nil {
 		return nil, err
 	}
+	result.Labels, err = convertLabelsFromQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func ConvertMetricFromResetCounterRequest(req *http.Request) (*entities.Metric, error) {
+	metricName := chi.URLParam(req, "name")
+
+	result := entities.Metric{Type: entities.MetricTypeCounter}
+	var err error
+	result.Name, err = convertMetricName(metricName)
+	if err != nil {
+		return nil, err
+	}
+	result.Labels, err = convertLabelsFromQuery(req)
+	if err != nil {
+		return nil, err
+	}
 	return &result, nil
 }
 
+// ConvertBatchMetricFromDeleteRequest reads metrics to delete; only id, type
+// and labels are used
+func ConvertBatchMetricFromDeleteRequest(req *http.Request) ([]entities.Metric, error) {
+	if req.Header.Get("Content-Type") != "application/json" {
+		return nil, entities.ErrJSONRequestExpected
+	}
+	var metrics []models.Metric
+	if err := json.NewDecoder(req.Body).Decode(&metrics); err != nil {
+		return nil, entities.NewJSONRequestDecodeError(err)
+	}
+	result := make([]entities.Metric, 0, len(metrics))
+	for i, metric := range metrics {
+		var entityMetric entities.Metric
+		var err error
+		entityMetric.Type, err = convertMetricType(metric.MType)
+		if err != nil {
+			return nil, fmt.Errorf("metric[%v]: %w", i, err)
+		}
+		entityMetric.Name, err = convertMetricName(metric.ID)
+		if err != nil {
+			return nil, fmt.Errorf("metric[%v]: %w", i, err)
+		}
+		entityMetric.Labels, err = convertLabels(metric.Labels)
+		if err != nil {
+			return nil, fmt.Errorf("metric[%v]: %w", i, err)
+		}
+		result = append(result, entityMetric)
+	}
+	return result, nil
+}
+
 func ConvertMetricFromUpdateFromURLRequest(req *http.Request) (*entities.Metric, error) {
 	metricType := chi.URLParam(req, "type")
 	metricName := chi.URLParam(req, "name")
@@ -151,6 +226,10 @@ func ConvertMetricFromUpdateFromURLRequest(req *http.Request) (*entities.Metric,
 	if err != nil {
 		return nil, err
 	}
+	result.Labels, err = convertLabelsFromQuery(req)
+	if err != nil {
+		return nil, err
+	}
 	if len(metricValue) == 0 {
 		return nil, entities.ErrMissingValue
 	}
@@ -168,6 +247,8 @@ func ConvertMetricFromUpdateFromURLRequest(req *http.Request) (*entities.Metric,
 			return nil, entities.NewMetricValueIsNotValidError(err)
 		}
 		result.Delta = entities.Counter(asInt64)
+	case entities.MetricTypeHistogram:
+		return nil, entities.ErrHistogramFromURL
 	default:
 		return nil, entities.NewInternalError(
 			"unexpected internal metric type: "+result.Type.String(), nil)
@@ -184,11 +265,20 @@ func ConvertEntityMetric(metric entities.Metric) (*models.Metric, error) {
 	case entities.MetricTypeCounter:
 		result.MType = string(MetricTypeCounter)
 		result.Delta = (*int64)(&metric.Delta)
+	case entities.MetricTypeHistogram:
+		result.MType = string(MetricTypeHistogram)
+		result.Buckets = metric.Histogram.Bounds
+		result.Counts = metric.Histogram.Counts
+		result.Count = &metric.Histogram.Count
+		result.Sum = &metric.Histogram.Sum
 	default:
 		return nil, entities.NewInternalError(
 			"unexpected internal metric type: "+metric.Type.String(), nil)
 	}
 	result.ID = string(metric.Name)
+	if len(metric.Labels) > 0 {
+		result.Labels = metric.Labels
+	}
 	return &result, nil
 }
 
@@ -210,13 +300,78 @@ func convertMetricType(metricType string) (entities.MetricType, error) {
 		return entities.MetricTypeGauge, nil
 	case MetricTypeCounter:
 		return entities.MetricTypeCounter, nil
+	case MetricTypeHistogram:
+		return entities.MetricTypeHistogram, nil
 	}
 	return entities.MetricTypeUndefined, entities.NewInvalidMetricTypeError(metricType)
 }
 
+func convertEntityMetricType(metricType entities.MetricType) (MetricType, error) {
+	switch metricType {
+	case entities.MetricTypeGauge:
+		return MetricTypeGauge, nil
+	case entities.MetricTypeCounter:
+		return MetricTypeCounter, nil
+	case entities.MetricTypeHistogram:
+		return MetricTypeHistogram, nil
+	}
+	return "", entities.NewInternalError(
+		"unexpected internal metric type: "+metricType.String(), nil)
+}
+
+func convertHistogram(metric models.Metric) (entities.Histogram, error) {
+	if len(metric.Counts) == 0 || metric.Count == nil || metric.Sum == nil {
+		return entities.Histogram{}, entities.ErrMissingHistogram
+	}
+	result := entities.Histogram{
+		Bounds: metric.Buckets,
+		Counts: metric.Counts,
+		Count:  *metric.Count,
+		Sum:    *metric.Sum,
+	}
+	if err := result.Validate(); err != nil {
+		return entities.Histogram{}, entities.NewMetricValueIsNotValidError(err)
+	}
+	return result, nil
+}
+
+// convertLabels returns nil if there are no labels; labels with empty values
+// are the same as absent ones
+func convertLabels(labels map[string]string) (entities.Labels, error) {
+	var result entities.Labels
+	for name, value := range labels {
+		if len(value) == 0 {
+			continue
+		}
+		if result == nil {
+			result = make(entities.Labels, len(labels))
+		}
+		result[name] = value
+	}
+	if err := result.Validate(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// convertLabelsFromQuery treats url query parameters as labels, e.g.
+// /value/gauge/Alloc?host=a&service=b
+func convertLabelsFromQuery(req *http.Request) (entities.Labels, error) {
+	return convertLabelsFromValues(req.URL.Query())
+}
+
+func convertLabelsFromValues(query url.Values) (entities.Labels, error) {
+	labels := make(map[string]string, len(query))
+	for name := range query {
+		labels[name] = query.Get(name)
+	}
+	return convertLabels(labels)
+}
+
 func convertMetricName(metricName string) (entities.MetricName, error) {
-	if len(metricName) == 0 {
-		return "", entities.ErrEmptyMetricName
+	result := entities.MetricName(metricName)
+	if err := result.Validate(); err != nil {
+		return "", err
 	}
-	return entities.MetricName(metricName), nil
+	return result, nil
 }
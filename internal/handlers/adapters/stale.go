@@ -0,0 +1,25 @@
+package adapters
+
+import (
+	"fmt"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+func ConvertEntityStaleMetrics(metrics []entities.StaleMetric) ([]models.StaleMetric, error) {
+	result := make([]models.StaleMetric, 0, len(metrics))
+	for i, metric := range metrics {
+		modelsMetric, err := ConvertEntityMetric(metric.Metric)
+		if err != nil {
+			return nil, fmt.Errorf("metric[%v]: %w", i, err)
+		}
+		staleMetric := models.StaleMetric{Metric: *modelsMetric}
+		if !metric.LastUpdate.IsZero() {
+			lastUpdate := metric.LastUpdate.UTC()
+			staleMetric.LastUpdate = &lastUpdate
+		}
+		result = append(result, staleMetric)
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgents(t *testing.T) {
+	type given struct {
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code        int
+		response    string
+		contentType string
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "agents: positive",
+			given: given{
+				mockUsecase: &mockMetricsUsecase{
+					ListAgentsFunc: func(ctx context.Context) ([]entities.Agent, error) {
+						return []entities.Agent{
+							{
+								ID:       "a1",
+								Hostname: "host1",
+								LastSeen: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+								Metrics: []entities.Metric{
+									{
+										Type:   entities.MetricTypeGauge,
+										Name:   "Alloc",
+										Labels: entities.Labels{"hostname": "host1"},
+									},
+									{Type: entities.MetricTypeCounter, Name: "PollCount"},
+								},
+							},
+						}, nil
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusOK,
+				response:    `[{"id":"a1","hostname":"host1","last_seen":"2025-01-02T03:04:05Z","metrics":[{"id":"Alloc","type":"gauge","labels":{"hostname":"host1"}},{"id":"PollCount","type":"counter"}]}]`,
+				contentType: "application/json",
+			},
+		},
+		{
+			name: "agents: some error",
+			given: given{
+				mockUsecase: &mockMetricsUsecase{
+					ListAgentsFunc: func(ctx context.Context) ([]entities.Agent, error) {
+						return nil, errors.New("some error")
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusInternalServerError,
+				response:    "some error",
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, respContentType, respBody := testRequest(
+				t, ts, http.MethodGet, "/agents")
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.contentType, respContentType)
+			assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			assert.Equal(t, 1, len(tt.given.mockUsecase.calls.ListAgents))
+		})
+	}
+}
+
+func TestUpdateBatchFromAgent(t *testing.T) {
+	mockUsecase := &mockMetricsUsecase{
+		UpdateAgentMetricsFunc: func(ctx context.Context, agent entities.Agent,
+			metrics []entities.Metric,
+		) ([]entities.Metric, error) {
+			require.Equal(t, entities.Agent{ID: "a1", Hostname: "host1"}, agent)
+			return metrics, nil
+		},
+	}
+	r := NewMetricsRouter(mockUsecase).WithAllHandlers()
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/updates/",
+		strings.NewReader(`[{"id":"foo","type":"gauge","value":1.23}]`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(models.AgentIDHeader, "a1")
+	req.Header.Set(models.AgentHostnameHeader, "host1")
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, len(mockUsecase.calls.UpdateAgentMetrics))
+	assert.Equal(t, 0, len(mockUsecase.calls.UpdateMetrics))
+}
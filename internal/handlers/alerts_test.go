@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlerts(t *testing.T) {
+	type given struct {
+		alerts []entities.Alert
+	}
+	type want struct {
+		code        int
+		response    string
+		contentType string
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "alerts: firing and inactive",
+			given: given{
+				alerts: []entities.Alert{
+					{
+						Rule:        entities.AlertRule{Name: "heap", Expr: "gauge HeapAlloc > 500MB for 2m"},
+						State:       entities.AlertFiring,
+						Value:       600,
+						HasValue:    true,
+						ActiveSince: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+						FiredAt:     time.Date(2025, 1, 2, 3, 6, 5, 0, time.UTC),
+					},
+					{
+						Rule:  entities.AlertRule{Name: "stuck", Expr: "rate(counter PollCount) == 0"},
+						State: entities.AlertInactive,
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusOK,
+				response:    `[{"name":"heap","expr":"gauge HeapAlloc \u003e 500MB for 2m","state":"firing","value":600,"active_since":"2025-01-02T03:04:05Z","fired_at":"2025-01-02T03:06:05Z"},{"name":"stuck","expr":"rate(counter PollCount) == 0","state":"inactive"}]`,
+				contentType: "application/json",
+			},
+		},
+		{
+			name: "alerts: disabled",
+			given: given{
+				alerts: []entities.Alert{},
+			},
+			want: want{
+				code:        http.StatusOK,
+				response:    `[]`,
+				contentType: "application/json",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUsecase := &mockMetricsUsecase{
+				ListAlertsFunc: func() []entities.Alert {
+					return tt.given.alerts
+				},
+			}
+			r := NewMetricsRouter(mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, respContentType, respBody := testRequest(
+				t, ts, http.MethodGet, "/alerts")
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.contentType, respContentType)
+			assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			assert.Equal(t, 1, len(mockUsecase.calls.ListAlerts))
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelete(t *testing.T) {
+	type given struct {
+		path        string
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code      int
+		response  string
+		callCount int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "delete: positive",
+			given: given{
+				path: "/value/gauge/foo?host=a",
+				mockUsecase: &mockMetricsUsecase{
+					DeleteMetricFunc: func(ctx context.Context, metric entities.Metric,
+					) (*entities.Metric, error) {
+						require.Equal(t, entities.Metric{
+							Type:   entities.MetricTypeGauge,
+							Name:   "foo",
+							Labels: entities.Labels{"host": "a"},
+						}, metric)
+						metric.Value = 1.23
+						return &metric, nil
+					},
+				},
+			},
+			want: want{
+				code:      http.StatusOK,
+				callCount: 1,
+			},
+		},
+		{
+			name: "delete: unknown metric",
+			given: given{
+				path: "/value/counter/bar",
+				mockUsecase: &mockMetricsUsecase{
+					DeleteMetricFunc: func(ctx context.Context, metric entities.Metric,
+					) (*entities.Metric, error) {
+						return nil, entities.NewMetricNameNotFoundError(metric.Name)
+					},
+				},
+			},
+			want: want{
+				code:      http.StatusNotFound,
+				response:  "404 page not found",
+				callCount: 1,
+			},
+		},
+		{
+			name: "delete: unknown metric type",
+			given: given{
+				path:        "/value/unknown/bar",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusNotFound,
+				response: "404 page not found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, _, respBody := testRequest(t, ts, http.MethodDelete, tt.given.path)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.DeleteMetric))
+		})
+	}
+}
+
+func TestDeleteBatchFromJSON(t *testing.T) {
+	type given struct {
+		request     string
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code      int
+		response  string
+		callCount int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "delete batch: positive",
+			given: given{
+				request: `[{"id":"foo","type":"gauge"},{"id":"bar","type":"counter","labels":{"host":"a"}}]`,
+				mockUsecase: &mockMetricsUsecase{
+					DeleteMetricsFunc: func(ctx context.Context, metrics []entities.Metric,
+					) ([]entities.Metric, error) {
+						require.Equal(t, []entities.Metric{
+							{Type: entities.MetricTypeGauge, Name: "foo"},
+							{
+								Type:   entities.MetricTypeCounter,
+								Name:   "bar",
+								Labels: entities.Labels{"host": "a"},
+							},
+						}, metrics)
+						// unknown gauge is skipped
+						return []entities.Metric{{
+							Type:   entities.MetricTypeCounter,
+							Name:   "bar",
+							Labels: entities.Labels{"host": "a"},
+							Delta:  42,
+						}}, nil
+					},
+				},
+			},
+			want: want{
+				code:      http.StatusOK,
+				response:  `[{"id":"bar","type":"counter","labels":{"host":"a"},"delta":42}]`,
+				callCount: 1,
+			},
+		},
+		{
+			name: "delete batch: empty metric name",
+			given: given{
+				request:     `[{"type":"gauge"}]`,
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusNotFound,
+				response: "404 page not found",
+			},
+		},
+		{
+			name: "delete batch: incorrect metric type",
+			given: given{
+				request:     `[{"id":"foo","type":"unknown"}]`,
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "metric[0]: invalid metric type: unknown",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, _, respBody := testRequestJSON(t, ts, http.MethodPost,
+				"/deletes/", tt.given.request)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.DeleteMetrics))
+		})
+	}
+}
+
+func TestResetCounter(t *testing.T) {
+	type given struct {
+		path        string
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code      int
+		response  string
+		callCount int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "reset: positive",
+			given: given{
+				path: "/reset/counter/bar?host=a",
+				mockUsecase: &mockMetricsUsecase{
+					ResetCounterFunc: func(ctx context.Context, metric entities.Metric,
+					) (*entities.Metric, error) {
+						require.Equal(t, entities.Metric{
+							Type:   entities.MetricTypeCounter,
+							Name:   "bar",
+							Labels: entities.Labels{"host": "a"},
+						}, metric)
+						return &metric, nil
+					},
+				},
+			},
+			want: want{
+				code:      http.StatusOK,
+				callCount: 1,
+			},
+		},
+		{
+			name: "reset: unknown counter",
+			given: given{
+				path: "/reset/counter/bar",
+				mockUsecase: &mockMetricsUsecase{
+					ResetCounterFunc: func(ctx context.Context, metric entities.Metric,
+					) (*entities.Metric, error) {
+						return nil, entities.NewMetricNameNotFoundError(metric.Name)
+					},
+				},
+			},
+			want: want{
+				code:      http.StatusNotFound,
+				response:  "404 page not found",
+				callCount: 1,
+			},
+		},
+		{
+			name: "reset: gauge",
+			given: given{
+				path:        "/reset/gauge/foo",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusNotFound,
+				response: "404 page not found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, _, respBody := testRequest(t, ts, http.MethodPost, tt.given.path)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.ResetCounter))
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	type given struct {
+		path        string
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code      int
+		response  string
+		callCount int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "values: positive",
+			given: given{
+				path: "/values?type=gauge,counter&prefix=A&regex=c$&sort=value&order=desc&limit=10&offset=5",
+				mockUsecase: &mockMetricsUsecase{
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
+						require.Equal(t, entities.MetricFilter{
+							Types: []entities.MetricType{
+								entities.MetricTypeGauge,
+								entities.MetricTypeCounter,
+							},
+							NamePrefix: "A",
+							NameRegexp: regexp.MustCompile("c$"),
+							SortBy:     entities.SortByValue,
+							Descending: true,
+							Offset:     5,
+							Limit:      10,
+						}, filter)
+						return []entities.Metric{
+							{Type: entities.MetricTypeGauge, Name: "Alloc", Value: 1.5},
+							{Type: entities.MetricTypeCounter, Name: "Abc", Delta: 1},
+						}, nil
+					},
+				},
+			},
+			want: want{
+				code:      http.StatusOK,
+				response:  `[{"id":"Alloc","type":"gauge","value":1.5},{"id":"Abc","type":"counter","delta":1}]`,
+				callCount: 1,
+			},
+		},
+		{
+			name: "values: no filter",
+			given: given{
+				path: "/values",
+				mockUsecase: &mockMetricsUsecase{
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
+						require.Equal(t, entities.MetricFilter{}, filter)
+						return []entities.Metric{}, nil
+					},
+				},
+			},
+			want: want{
+				code:      http.StatusOK,
+				response:  `[]`,
+				callCount: 1,
+			},
+		},
+		{
+			name: "values: invalid type",
+			given: given{
+				path:        "/values?type=foo",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid metric filter: invalid metric type: foo",
+			},
+		},
+		{
+			name: "values: invalid regex",
+			given: given{
+				path:        "/values?regex=(",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid metric filter: regex: error parsing regexp: missing closing ): `(`",
+			},
+		},
+		{
+			name: "values: invalid sort",
+			given: given{
+				path:        "/values?sort=foo",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid metric filter: unknown sort: foo",
+			},
+		},
+		{
+			name: "values: negative limit",
+			given: given{
+				path:        "/values?limit=-1",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid metric filter: limit: negative value: -1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, _, respBody := testRequest(t, ts, http.MethodGet, tt.given.path)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.FindMetrics))
+		})
+	}
+}
@@ -53,6 +53,47 @@ func TestGetAsText(t *testing.T) {
 				callCount:   1,
 			},
 		},
+		{
+			name: "value: gauge with labels",
+			given: given{
+				method: http.MethodGet,
+				url:    "/value/gauge/foo?host=a&service=",
+				mockUsecase: &mockMetricsUsecase{
+					GetMetricFunc: func(ctx context.Context, metric entities.Metric,
+					) (*entities.Metric, error) {
+						require.Equal(t, entities.MetricTypeGauge, metric.Type)
+						require.Equal(t, entities.MetricName("foo"), metric.Name)
+						require.Equal(t, entities.Labels{"host": "a"}, metric.Labels)
+						return &entities.Metric{
+							Type:   entities.MetricTypeGauge,
+							Name:   "foo",
+							Labels: entities.Labels{"host": "a"},
+							Value:  4.56,
+						}, nil
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusOK,
+				response:    "4.56",
+				contentType: "text/plain; charset=utf-8",
+				callCount:   1,
+			},
+		},
+		{
+			name: "value: invalid label name",
+			given: given{
+				method:      http.MethodGet,
+				url:         "/value/gauge/foo?host-name=a",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				response:    "label name should match [a-zA-Z_][a-zA-Z0-9_]*: \"host-name\"\n",
+				contentType: "text/plain; charset=utf-8",
+				callCount:   0,
+			},
+		},
 		{
 			name: "value: counter positive",
 			given: given{
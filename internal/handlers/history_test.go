@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	from := time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	type given struct {
+		path        string
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code        int
+		response    string
+		contentType string
+		callCount   int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "history: positive",
+			given: given{
+				path: "/history/gauge/foo?from=2025-01-02T03:00:00Z&to=2025-01-02T04:00:00Z&step=30s&host=a",
+				mockUsecase: &mockMetricsUsecase{
+					GetHistoryFunc: func(ctx context.Context, metric entities.Metric,
+						f time.Time, t_ time.Time, step time.Duration,
+					) ([]entities.Sample, error) {
+						require.Equal(t, entities.Metric{
+							Type:   entities.MetricTypeGauge,
+							Name:   "foo",
+							Labels: entities.Labels{"host": "a"},
+						}, metric)
+						require.True(t, from.Equal(f))
+						require.True(t, to.Equal(t_))
+						require.Equal(t, 30*time.Second, step)
+						return []entities.Sample{
+							{Time: from, Value: 1.5},
+							{Time: from.Add(time.Minute), Value: 2},
+						}, nil
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusOK,
+				response:    `[{"time":"2025-01-02T03:00:00Z","value":1.5},{"time":"2025-01-02T03:01:00Z","value":2}]`,
+				contentType: "application/json",
+				callCount:   1,
+			},
+		},
+		{
+			name: "history: invalid from",
+			given: given{
+				path:        "/history/gauge/foo?from=yesterday",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				response:    `invalid history query: from: parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": cannot parse "yesterday" as "2006"`,
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "history: invalid step",
+			given: given{
+				path: "/history/counter/bar?step=0",
+				mockUsecase: &mockMetricsUsecase{
+					GetHistoryFunc: func(ctx context.Context, metric entities.Metric,
+						f time.Time, t_ time.Time, step time.Duration,
+					) ([]entities.Sample, error) {
+						return nil, entities.ErrInvalidHistoryQuery
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				response:    "invalid history query",
+				contentType: "text/plain; charset=utf-8",
+				callCount:   1,
+			},
+		},
+		{
+			name: "history: histogram",
+			given: given{
+				path: "/history/histogram/baz",
+				mockUsecase: &mockMetricsUsecase{
+					GetHistoryFunc: func(ctx context.Context, metric entities.Metric,
+						f time.Time, t_ time.Time, step time.Duration,
+					) ([]entities.Sample, error) {
+						return nil, entities.ErrHistoryNotSupported
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				response:    "history is not supported",
+				contentType: "text/plain; charset=utf-8",
+				callCount:   1,
+			},
+		},
+		{
+			name: "history: unknown metric type",
+			given: given{
+				path:        "/history/unknown/foo",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:        http.StatusNotFound,
+				response:    "404 page not found",
+				contentType: "text/plain; charset=utf-8",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, respContentType, respBody := testRequest(
+				t, ts, http.MethodGet, tt.given.path)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.contentType, respContentType)
+			assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.GetHistory))
+		})
+	}
+}
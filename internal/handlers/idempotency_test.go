@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBatchIdempotent(t *testing.T) {
+	tests := []struct {
+		name         string
+		replayed     bool
+		err          error
+		wantCode     int
+		wantReplayed string
+	}{
+		{
+			name:     "idempotent batch: first delivery",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:         "idempotent batch: replay",
+			replayed:     true,
+			wantCode:     http.StatusOK,
+			wantReplayed: "true",
+		},
+		{
+			name:     "idempotent batch: key reused",
+			err:      entities.ErrIdempotencyKeyReused,
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUsecase := &mockMetricsUsecase{
+				UpdateMetricsOnceFunc: func(ctx context.Context, key string, agent *entities.Agent,
+					metrics []entities.Metric,
+				) ([]entities.Metric, bool, error) {
+					require.Equal(t, "k1", key)
+					require.Equal(t, &entities.Agent{ID: "a1"}, agent)
+					if tt.err != nil {
+						return nil, false, tt.err
+					}
+					return metrics, tt.replayed, nil
+				},
+			}
+			r := NewMetricsRouter(mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/updates/",
+				strings.NewReader(`[{"id":"foo","type":"counter","delta":1}]`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(models.AgentIDHeader, "a1")
+			req.Header.Set(models.IdempotencyKeyHeader, "k1")
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			// проверяем параметры ответа
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			assert.Equal(t, tt.wantReplayed, resp.Header.Get(models.IdempotentReplayedHeader))
+			if tt.err == nil {
+				assert.Equal(t, `[{"id":"foo","type":"counter","delta":1}]`,
+					strings.TrimSpace(string(body)))
+			}
+			assert.Equal(t, 1, len(mockUsecase.calls.UpdateMetricsOnce))
+			assert.Equal(t, 0, len(mockUsecase.calls.UpdateAgentMetrics))
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// mainPageTemplate is executed directly into the response, so the page is
+// streamed and every value is escaped by html/template
+var mainPageTemplate = template.Must(template.New("main").Funcs(template.FuncMap{
+	"key":   metricKey,
+	"value": metricValue,
+	"type":  metricTypeName,
+	"since": staleSince,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Metrics</title>
+	{{- if .Refresh}}
+	<meta http-equiv="refresh" content="{{.Refresh}}">
+	{{- end}}
+</head>
+<body>
+	<form method="get">
+		{{- range .Types}}
+		<label><input type="checkbox" name="type" value="{{.Name}}"{{if .Checked}} checked{{end}}>{{.Name}}</label>
+		{{- end}}
+		<input name="prefix" placeholder="name prefix" value="{{.Query.Get "prefix"}}">
+		<input name="regex" placeholder="name regex" value="{{.Query.Get "regex"}}">
+		<select name="sort">
+			{{- range .SortKeys}}
+			<option{{if eq . ($.Query.Get "sort")}} selected{{end}}>{{.}}</option>
+			{{- end}}
+		</select>
+		<select name="order">
+			<option>asc</option>
+			<option{{if eq ($.Query.Get "order") "desc"}} selected{{end}}>desc</option>
+		</select>
+		<input name="refresh" type="number" min="0" placeholder="refresh, s" value="{{.Query.Get "refresh"}}">
+		<button type="submit">apply</button>
+	</form>
+	{{- if .Stale}}
+	<h2>stale</h2>
+	<table>
+		<tr>
+			<th>type</th>
+			<th>key</th>
+			<th>value</th>
+			<th>last update</th>
+		</tr>
+		{{- range .Stale}}
+		<tr>
+			<td>{{type .Metric}}</td>
+			<td>{{key .Metric}}</td>
+			<td>{{value .Metric}}</td>
+			<td>{{since .}}</td>
+		</tr>
+		{{- end}}
+	</table>
+	{{- end}}
+	{{- range .Sections}}
+	<h2>{{.Type}}</h2>
+	<table>
+		<tr>
+			<th>key</th>
+			<th>value</th>
+		</tr>
+		{{- range .Metrics}}
+		<tr>
+			<td>{{key .}}</td>
+			<td>{{value .}}</td>
+		</tr>
+		{{- end}}
+	</table>
+	{{- end}}
+</body>
+</html>
+`))
+
+// mainPageTypes are names of metric types in order of main page sections
+var mainPageTypes = []struct {
+	type_ entities.MetricType
+	name  string
+}{
+	{entities.MetricTypeGauge, "gauge"},
+	{entities.MetricTypeCounter, "counter"},
+	{entities.MetricTypeHistogram, "histogram"},
+}
+
+type mainPageType struct {
+	Name    string
+	Checked bool
+}
+
+type mainPageSection struct {
+	Type    string
+	Metrics []entities.Metric
+}
+
+type mainPage struct {
+	Refresh  int
+	Query    url.Values // to fill the form
+	Types    []mainPageType
+	SortKeys []string
+	Stale    []entities.StaleMetric
+	Sections []mainPageSection
+}
+
+// newMainPage groups metrics by type keeping their order within each section;
+// sections without metrics are omitted
+func newMainPage(query url.Values, refresh int, filter entities.MetricFilter,
+	metrics []entities.Metric, stale []entities.StaleMetric,
+) mainPage {
+	result := mainPage{
+		Refresh:  refresh,
+		Query:    query,
+		SortKeys: []string{"type", "name", "value"},
+		Stale:    stale,
+	}
+	for _, t := range mainPageTypes {
+		result.Types = append(result.Types, mainPageType{
+			Name:    t.name,
+			Checked: slices.Contains(filter.Types, t.type_),
+		})
+
+		var section []entities.Metric
+		for _, metric := range metrics {
+			if metric.Type == t.type_ {
+				section = append(section, metric)
+			}
+		}
+		if len(section) > 0 {
+			result.Sections = append(result.Sections, mainPageSection{
+				Type:    t.name,
+				Metrics: section,
+			})
+		}
+	}
+	return result
+}
+
+func metricKey(metric entities.Metric) string {
+	return metric.Key().String()
+}
+
+func metricValue(metric entities.Metric) string {
+	switch metric.Type {
+	case entities.MetricTypeGauge:
+		return fmt.Sprint(metric.Value)
+	case entities.MetricTypeCounter:
+		return fmt.Sprint(metric.Delta)
+	case entities.MetricTypeHistogram:
+		return metric.Histogram.String()
+	}
+	return ""
+}
+
+func metricTypeName(metric entities.Metric) string {
+	for _, t := range mainPageTypes {
+		if t.type_ == metric.Type {
+			return t.name
+		}
+	}
+	return ""
+}
+
+func staleSince(metric entities.StaleMetric) string {
+	if metric.LastUpdate.IsZero() {
+		return "unknown"
+	}
+	return metric.LastUpdate.UTC().Format(time.RFC3339)
+}
@@ -6,10 +6,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/PiskarevSA/go-advanced/internal/entities"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func noStaleMetrics(metrics []entities.Metric, now time.Time) []entities.StaleMetric {
+	return []entities.StaleMetric{}
+}
+
 func TestMainPage(t *testing.T) {
 	type given struct {
 		method      string
@@ -18,7 +25,8 @@ func TestMainPage(t *testing.T) {
 	}
 	type want struct {
 		code        int
-		response    string
+		contains    []string
+		notContains []string
 		contentType string
 		callCount   int
 	}
@@ -33,32 +41,19 @@ func TestMainPage(t *testing.T) {
 				method: http.MethodGet,
 				url:    "/",
 				mockUsecase: &mockMetricsUsecase{
-					DumpIteratorFunc: func(ctx context.Context) (
-						func() (type_ string, name string, value string, exists bool), error,
-					) {
-						fn := func() (type_ string, name string, value string, exists bool) {
-							return "", "", "", false
-						}
-						var err error
-						return fn, err
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
+						require.Equal(t, entities.MetricFilter{}, filter)
+						return []entities.Metric{}, nil
 					},
+					StaleMetricsFunc: noStaleMetrics,
 				},
 			},
 			want: want{
-				code: http.StatusOK,
-				response: `<!DOCTYPE html>
-<title>Metrics</title>
-<body>
-	<table>
-		<tr>
-			<th>type</th>
-			<th>key</th>
-			<th>value</th>
-		</tr>
-	</table>
-</body>
-`,
-				contentType: "text/html",
+				code:        http.StatusOK,
+				contains:    []string{"<!DOCTYPE html>", "<title>Metrics</title>", "</html>"},
+				notContains: []string{"<table>", `http-equiv="refresh"`},
+				contentType: "text/html; charset=utf-8",
 				callCount:   1,
 			},
 		},
@@ -68,77 +63,171 @@ func TestMainPage(t *testing.T) {
 				method: http.MethodGet,
 				url:    "/",
 				mockUsecase: &mockMetricsUsecase{
-					DumpIteratorFunc: func(ctx context.Context) (
-						func() (type_ string, name string, value string, exists bool), error,
-					) {
-						callNumber := 0
-						fn := func() (type_ string, name string, value string, exists bool) {
-							callNumber++
-							switch callNumber {
-							case 1:
-								return "type1", "name1", "value1", true
-							case 2:
-								return "type2", "name2", "value2", true
-							case 3:
-								return "type3", "name3", "value3", true
-							default:
-								return "", "", "", false
-							}
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
+						return []entities.Metric{
+							{Type: entities.MetricTypeGauge, Name: "Alloc", Value: 1.5},
+							{
+								Type:   entities.MetricTypeCounter,
+								Name:   "PollCount",
+								Labels: entities.Labels{"host": "a"},
+								Delta:  2,
+							},
+							{
+								Type: entities.MetricTypeHistogram,
+								Name: "GCPause",
+								Histogram: entities.Histogram{
+									Bounds: []float64{1},
+									Counts: []uint64{1, 0},
+									Count:  1,
+									Sum:    0.5,
+								},
+							},
+						}, nil
+					},
+					StaleMetricsFunc: noStaleMetrics,
+				},
+			},
+			want: want{
+				code: http.StatusOK,
+				contains: []string{
+					"<h2>gauge</h2>",
+					"<td>Alloc</td>\n\t\t\t<td>1.5</td>",
+					"<h2>counter</h2>",
+					"<td>PollCount{host=&#34;a&#34;}</td>\n\t\t\t<td>2</td>",
+					"<h2>histogram</h2>",
+					"<td>GCPause</td>",
+				},
+				contentType: "text/html; charset=utf-8",
+				callCount:   1,
+			},
+		},
+		{
+			name: "mainpage: names are escaped",
+			given: given{
+				method: http.MethodGet,
+				url:    "/?prefix=%22%3E%3Cscript%3E",
+				mockUsecase: &mockMetricsUsecase{
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
+						return []entities.Metric{
+							{Type: entities.MetricTypeGauge, Name: "<script>alert(1)</script>"},
+						}, nil
+					},
+					StaleMetricsFunc: noStaleMetrics,
+				},
+			},
+			want: want{
+				code: http.StatusOK,
+				contains: []string{
+					"<td>&lt;script&gt;alert(1)&lt;/script&gt;</td>",
+					`value="&#34;&gt;&lt;script&gt;"`,
+				},
+				notContains: []string{"<script>"},
+				contentType: "text/html; charset=utf-8",
+				callCount:   1,
+			},
+		},
+		{
+			name: "mainpage: filter and refresh",
+			given: given{
+				method: http.MethodGet,
+				url:    "/?type=counter&sort=name&order=desc&refresh=5",
+				mockUsecase: &mockMetricsUsecase{
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
+						require.Equal(t, entities.MetricFilter{
+							Types:      []entities.MetricType{entities.MetricTypeCounter},
+							SortBy:     entities.SortByName,
+							Descending: true,
+						}, filter)
+						return []entities.Metric{}, nil
+					},
+					StaleMetricsFunc: noStaleMetrics,
+				},
+			},
+			want: want{
+				code: http.StatusOK,
+				contains: []string{
+					`<meta http-equiv="refresh" content="5">`,
+					`<input type="checkbox" name="type" value="counter" checked>`,
+					`<option selected>name</option>`,
+					`<option selected>desc</option>`,
+				},
+				contentType: "text/html; charset=utf-8",
+				callCount:   1,
+			},
+		},
+		{
+			name: "mainpage: stale",
+			given: given{
+				method: http.MethodGet,
+				url:    "/",
+				mockUsecase: &mockMetricsUsecase{
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
+						return []entities.Metric{
+							{Type: entities.MetricTypeGauge, Name: "Alloc", Value: 1.5},
+						}, nil
+					},
+					StaleMetricsFunc: func(metrics []entities.Metric, now time.Time,
+					) []entities.StaleMetric {
+						// stale metrics are selected from the fetched page
+						require.Equal(t, []entities.Metric{
+							{Type: entities.MetricTypeGauge, Name: "Alloc", Value: 1.5},
+						}, metrics)
+						return []entities.StaleMetric{
+							{
+								Metric:     entities.Metric{Type: entities.MetricTypeGauge, Name: "Alloc", Value: 1.5},
+								LastUpdate: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+							},
+							{
+								Metric: entities.Metric{Type: entities.MetricTypeCounter, Name: "PollCount", Delta: 2},
+							},
 						}
-						var err error
-						return fn, err
 					},
 				},
 			},
 			want: want{
 				code: http.StatusOK,
-				response: `<!DOCTYPE html>
-<title>Metrics</title>
-<body>
-	<table>
-		<tr>
-			<th>type</th>
-			<th>key</th>
-			<th>value</th>
-		</tr>
-		<tr>
-			<td>type1</td>
-			<td>name1</td>
-			<td>value1</td>
-		</tr>
-		<tr>
-			<td>type2</td>
-			<td>name2</td>
-			<td>value2</td>
-		</tr>
-		<tr>
-			<td>type3</td>
-			<td>name3</td>
-			<td>value3</td>
-		</tr>
-	</table>
-</body>
-`,
-				contentType: "text/html",
+				contains: []string{
+					"<h2>stale</h2>",
+					"<td>gauge</td>\n\t\t\t<td>Alloc</td>\n\t\t\t<td>1.5</td>\n\t\t\t<td>2025-01-02T03:04:05Z</td>",
+					"<td>PollCount</td>\n\t\t\t<td>2</td>\n\t\t\t<td>unknown</td>",
+				},
+				contentType: "text/html; charset=utf-8",
 				callCount:   1,
 			},
 		},
+		{
+			name: "mainpage: invalid refresh",
+			given: given{
+				method:      http.MethodGet,
+				url:         "/?refresh=often",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				contains:    []string{"invalid metric filter: refresh"},
+				contentType: "text/plain; charset=utf-8",
+				callCount:   0,
+			},
+		},
 		{
 			name: "mainpage: some error",
 			given: given{
 				method: http.MethodGet,
 				url:    "/",
 				mockUsecase: &mockMetricsUsecase{
-					DumpIteratorFunc: func(ctx context.Context) (
-						func() (type_ string, name string, value string, exists bool), error,
-					) {
+					FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+					) ([]entities.Metric, error) {
 						return nil, errors.New("some error")
 					},
 				},
 			},
 			want: want{
 				code:        http.StatusInternalServerError,
-				response:    "some error\n",
+				contains:    []string{"some error\n"},
 				contentType: "text/plain; charset=utf-8",
 				callCount:   1,
 			},
@@ -152,7 +241,6 @@ func TestMainPage(t *testing.T) {
 			},
 			want: want{
 				code:        http.StatusMethodNotAllowed,
-				response:    "",
 				contentType: "",
 				callCount:   0,
 			},
@@ -170,8 +258,13 @@ func TestMainPage(t *testing.T) {
 			// проверяем параметры ответа
 			assert.Equal(t, tt.want.code, respCode)
 			assert.Equal(t, tt.want.contentType, respContentType)
-			assert.Equal(t, tt.want.response, respBody)
-			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.DumpIterator))
+			for _, s := range tt.want.contains {
+				assert.Contains(t, respBody, s)
+			}
+			for _, s := range tt.want.notContains {
+				assert.NotContains(t, respBody, s)
+			}
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.FindMetrics))
 		})
 	}
 }
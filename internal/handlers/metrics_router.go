@@ -7,41 +7,36 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/entities"
 	"github.com/PiskarevSA/go-advanced/internal/handlers/adapters"
+	"github.com/PiskarevSA/go-advanced/internal/models"
 	"github.com/go-chi/chi/v5"
 )
 
-const (
-	docTemplate = `<!DOCTYPE html>
-<title>Metrics</title>
-<body>
-	<table>
-		<tr>
-			<th>type</th>
-			<th>key</th>
-			<th>value</th>
-		</tr>%s
-	</table>
-</body>
-`
-
-	rowTemplate = `
-		<tr>
-			<td>%s</td>
-			<td>%s</td>
-			<td>%s</td>
-		</tr>`
-)
-
 const timeout = 15 * time.Second
 
 type metricsUsecase interface {
 	GetMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 	UpdateMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 	UpdateMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+	UpdateAgentMetrics(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error)
+	UpdateMetricsOnce(ctx context.Context, key string, agent *entities.Agent,
+		metrics []entities.Metric) ([]entities.Metric, bool, error)
+	DeleteMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	DeleteMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+	ResetCounter(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	ListAgents(ctx context.Context) ([]entities.Agent, error)
+	FindMetrics(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error)
+	Subscribe(filter entities.MetricFilter) (updates <-chan []entities.Metric, unsubscribe func())
+	GetHistory(ctx context.Context, metric entities.Metric, from time.Time, to time.Time,
+		step time.Duration) ([]entities.Sample, error)
+	ListAlerts() []entities.Alert
+	ListStaleMetrics(ctx context.Context, filter entities.MetricFilter,
+		now time.Time) ([]entities.StaleMetric, error)
+	StaleMetrics(metrics []entities.Metric, now time.Time) []entities.StaleMetric
 	DumpIterator(ctx context.Context) (func() (type_ string, name string, value string, exists bool), error)
 	Ping(ctx context.Context) error
 }
@@ -70,17 +65,61 @@ func (r *MetricsRouter) WithAllHandlers() *MetricsRouter {
 	r.Post(`/update/{type}/{name}/{value}`, r.updateFromURLHandler)
 	r.Post(`/value/`, r.getAsJSONHandler)
 	r.Get(`/value/{type}/{name}`, r.getAsTextHandler)
+	r.Get(`/values`, r.findHandler)
+	r.Delete(`/value/{type}/{name}`, r.deleteHandler)
+	r.Post(`/deletes/`, r.deleteBatchFromJSONHandler)
+	r.Post(`/reset/counter/{name}`, r.resetCounterHandler)
 	r.Get(`/ping`, r.ping)
+	r.Get(`/metrics`, r.prometheusHandler)
+	r.Get(`/agents`, r.agentsHandler)
+	r.Get(`/stream`, r.streamHandler)
+	r.Get(`/history/{type}/{name}`, r.historyHandler)
+	r.Get(`/alerts`, r.alertsHandler)
+	r.Get(`/stale`, r.staleHandler)
 
 	return r
 }
 
 // mainPageHandler handles endpoint: GET /
 //
-// Request: none
+// Request: none; optional url query parameters are the same as for GET /values
+// and refresh, auto-refresh interval in seconds
 //
-// Response	type: "text/html", body: html document containing dumped metrics
+// Response	type: "text/html; charset=utf-8", body: html document containing
+// stale metrics, if any, and metrics grouped by type
 func (r *MetricsRouter) mainPageHandler(res http.ResponseWriter, req *http.Request) {
+	filter, refresh, err := adapters.ConvertMainPageRequest(req)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	metrics, err := r.metricsUsecase.FindMetrics(ctx, *filter)
+	if err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+	stale := r.metricsUsecase.StaleMetrics(metrics, time.Now())
+
+	page := newMainPage(req.URL.Query(), refresh, *filter, metrics, stale)
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := mainPageTemplate.Execute(res, page); err != nil {
+		// the response is partially written, so just log the error
+		slog.Error("main page rendering error", "error", err)
+	}
+}
+
+// prometheusHandler handles endpoint: GET /metrics
+//
+// Request: none
+//
+// Response type: "text/plain; version=0.0.4; charset=utf-8", body: gauges and
+// counters in Prometheus text exposition format; metric names are sanitized,
+// metrics whose sanitized names are already exposed are skipped, labels are
+// exposed as is
+func (r *MetricsRouter) prometheusHandler(res http.ResponseWriter, req *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	metricsIterator, err := r.metricsUsecase.DumpIterator(ctx)
@@ -89,19 +128,36 @@ func (r *MetricsRouter) mainPageHandler(res http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	var rows string
+	var sb strings.Builder
+	exposed := make(map[string]struct{}) // metric families
+	var family, familyName string        // current metric family
 	for {
 		type_, name, value, exists := metricsIterator()
 		if !exists {
 			break
 		}
-		rows += fmt.Sprintf(rowTemplate, type_, name, value)
+		prometheusType, ok := prometheusTypes[type_]
+		if !ok {
+			continue
+		}
+		key := entities.ParseMetricKey(name)
+		prometheusName := sanitizePrometheusName(string(key.Name))
+		if prometheusName != family || string(key.Name) != familyName {
+			// series of the same family are dumped one after another
+			if _, ok := exposed[prometheusName]; ok {
+				slog.Warn("[prometheus] metric name collision, skipped",
+					"type", type_, "name", name, "prometheusName", prometheusName)
+				continue
+			}
+			exposed[prometheusName] = struct{}{}
+			family, familyName = prometheusName, string(key.Name)
+			writePrometheusType(&sb, prometheusType, prometheusName)
+		}
+		writePrometheusSample(&sb, prometheusName, key.Labels, value)
 	}
 
-	doc := fmt.Sprintf(docTemplate, rows)
-
-	res.Header().Set("Content-Type", "text/html")
-	_, err = res.Write([]byte(doc))
+	res.Header().Set("Content-Type", prometheusContentType)
+	_, err = res.Write([]byte(sb.String()))
 	if err != nil {
 		handleAsInternalServerError(err, res)
 	}
@@ -142,7 +198,8 @@ func (r *MetricsRouter) getAsJSONHandler(res http.ResponseWriter, req *http.Requ
 
 // getAsTextHandler handles endpoint: GET /value/{type}/{name}
 //
-// Request: none
+// Request: none; optional url query parameters are metric labels, e.g.
+// ?host=a&service=b
 //
 // Response type: "text/plain; charset=utf-8", body: metric value as string
 func (r *MetricsRouter) getAsTextHandler(res http.ResponseWriter, req *http.Request) {
@@ -167,6 +224,8 @@ func (r *MetricsRouter) getAsTextHandler(res http.ResponseWriter, req *http.Requ
 		response = fmt.Sprint(responseMetric.Value)
 	case entities.MetricTypeCounter:
 		response = fmt.Sprint(responseMetric.Delta)
+	case entities.MetricTypeHistogram:
+		response = responseMetric.Histogram.String()
 	default:
 		err = entities.NewInternalError(
 			"unexpected internal metric type: "+responseMetric.Type.String(), nil)
@@ -183,6 +242,73 @@ func (r *MetricsRouter) getAsTextHandler(res http.ResponseWriter, req *http.Requ
 	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
 }
 
+// findHandler handles endpoint: GET /values
+//
+// Request: none; optional url query parameters: type (may be repeated or
+// comma separated), prefix and regex of metric name, sort (type, name or
+// value), order (asc or desc), limit and offset
+//
+// Response type: "application/json", body: []models.Metric
+func (r *MetricsRouter) findHandler(res http.ResponseWriter, req *http.Request) {
+	filter, err := adapters.ConvertMetricFilterFromRequest(req)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	metrics, err := r.metricsUsecase.FindMetrics(ctx, *filter)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	// success
+	response, err := adapters.ConvertEntityMetrics(metrics)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(response); err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+}
+
+// historyHandler handles endpoint: GET /history/{type}/{name}
+//
+// Request: none; url query parameters: from, to (RFC 3339, default to now and
+// an hour before to), step (Go duration, e.g. 30s, default 1m); other url query
+// parameters are metric labels
+//
+// Response type: "application/json", body: []models.Sample, gauge and counter
+// history downsampled with given step
+func (r *MetricsRouter) historyHandler(res http.ResponseWriter, req *http.Request) {
+	query, err := adapters.ConvertHistoryRequest(req)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	samples, err := r.metricsUsecase.GetHistory(ctx, query.Metric, query.From,
+		query.To, query.Step)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	// success
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(adapters.ConvertEntitySamples(samples)); err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+}
+
 func handleGetterError(err error, res http.ResponseWriter, req *http.Request) {
 	var (
 		invalidMetricTypeError  *entities.InvalidMetricTypeError
@@ -195,6 +321,16 @@ func handleGetterError(err error, res http.ResponseWriter, req *http.Request) {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 	case errors.Is(err, entities.ErrEmptyMetricName):
 		http.NotFound(res, req)
+	case errors.Is(err, entities.ErrInvalidLabelName):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrInvalidMetricName):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrHistoryNotSupported):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrInvalidHistoryQuery):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrInvalidMetricFilter):
+		http.Error(res, err.Error(), http.StatusBadRequest)
 	case errors.As(err, &invalidMetricTypeError):
 		http.NotFound(res, req)
 	case errors.As(err, &metricNameNotFoundError):
@@ -243,7 +379,10 @@ func (r *MetricsRouter) updateFromJSONHandler(res http.ResponseWriter, req *http
 
 // updateBatchFromJSONHandler handles endpoint: POST /updates/
 //
-// Request type: "application/json", body: []models.Metric
+// Request type: "application/json", body: []models.Metric; batches sent by
+// agent contain models.AgentIDHeader and models.AgentHostnameHeader; a batch
+// with models.IdempotencyKeyHeader is applied once, its replays get the
+// original response with models.IdempotentReplayedHeader
 //
 // Response type: "application/json", body: []models.Metric
 func (r *MetricsRouter) updateBatchFromJSONHandler(res http.ResponseWriter, req *http.Request) {
@@ -252,14 +391,29 @@ func (r *MetricsRouter) updateBatchFromJSONHandler(res http.ResponseWriter, req
 		handleUpdateError(err, res, req)
 		return
 	}
+	agent := adapters.ConvertAgentFromRequest(req)
+	idempotencyKey := req.Header.Get(models.IdempotencyKeyHeader)
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	updatedMetrics, err := r.metricsUsecase.UpdateMetrics(ctx, validMetrics)
+	var updatedMetrics []entities.Metric
+	var replayed bool
+	switch {
+	case len(idempotencyKey) > 0:
+		updatedMetrics, replayed, err = r.metricsUsecase.UpdateMetricsOnce(ctx,
+			idempotencyKey, agent, validMetrics)
+	case agent != nil:
+		updatedMetrics, err = r.metricsUsecase.UpdateAgentMetrics(ctx, *agent, validMetrics)
+	default:
+		updatedMetrics, err = r.metricsUsecase.UpdateMetrics(ctx, validMetrics)
+	}
 	if err != nil {
 		handleUpdateError(err, res, req)
 		return
 	}
+	if replayed {
+		res.Header().Set(models.IdempotentReplayedHeader, "true")
+	}
 	// success
 	response, err := adapters.ConvertEntityMetrics(updatedMetrics)
 	if err != nil {
@@ -274,7 +428,7 @@ func (r *MetricsRouter) updateBatchFromJSONHandler(res http.ResponseWriter, req
 
 // updateFromURLHandler handles endpoint: POST /update/{type}/{name}/{value}
 //
-// Request: none
+// Request: none; optional url query parameters are metric labels
 //
 // Response	type: "text/plain; charset=utf-8", body: none
 func (r *MetricsRouter) updateFromURLHandler(res http.ResponseWriter, req *http.Request) {
@@ -315,8 +469,18 @@ func handleUpdateError(err error, res http.ResponseWriter, req *http.Request) {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 	case errors.Is(err, entities.ErrMissingDelta):
 		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrMissingHistogram):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrHistogramFromURL):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrInvalidLabelName):
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrInvalidMetricName):
+		http.Error(res, err.Error(), http.StatusBadRequest)
 	case errors.As(err, &jsonRequestDecodeError):
 		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, entities.ErrIdempotencyKeyReused):
+		http.Error(res, err.Error(), http.StatusUnprocessableEntity)
 	case errors.As(err, &internalError):
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 	default:
@@ -326,6 +490,160 @@ func handleUpdateError(err error, res http.ResponseWriter, req *http.Request) {
 	slog.Error("update error handled", "error", err)
 }
 
+// deleteHandler handles endpoint: DELETE /value/{type}/{name}
+//
+// Request: none; optional url query parameters are metric labels
+//
+// Response type: "text/plain; charset=utf-8", body: none
+func (r *MetricsRouter) deleteHandler(res http.ResponseWriter, req *http.Request) {
+	validMetric, err := adapters.ConvertMetricFromGetGetAsTextRequest(req)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := r.metricsUsecase.DeleteMetric(ctx, *validMetric); err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+	// success
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+}
+
+// deleteBatchFromJSONHandler handles endpoint: POST /deletes/
+//
+// Request type: "application/json", body: []models.Metric with id, type and
+// optional labels
+//
+// Response type: "application/json", body: []models.Metric, last values of
+// deleted metrics; unknown metrics are skipped
+func (r *MetricsRouter) deleteBatchFromJSONHandler(res http.ResponseWriter, req *http.Request) {
+	validMetrics, err := adapters.ConvertBatchMetricFromDeleteRequest(req)
+	if err != nil {
+		handleUpdateError(err, res, req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	deletedMetrics, err := r.metricsUsecase.DeleteMetrics(ctx, validMetrics)
+	if err != nil {
+		handleUpdateError(err, res, req)
+		return
+	}
+	// success
+	response, err := adapters.ConvertEntityMetrics(deletedMetrics)
+	if err != nil {
+		handleUpdateError(err, res, req)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(response); err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+}
+
+// resetCounterHandler handles endpoint: POST /reset/counter/{name}
+//
+// Request: none; optional url query parameters are metric labels
+//
+// Response type: "text/plain; charset=utf-8", body: none
+func (r *MetricsRouter) resetCounterHandler(res http.ResponseWriter, req *http.Request) {
+	validMetric, err := adapters.ConvertMetricFromResetCounterRequest(req)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := r.metricsUsecase.ResetCounter(ctx, *validMetric); err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+	// success
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+}
+
+// agentsHandler handles endpoint: GET /agents
+//
+// Request: none
+//
+// Response type: "application/json", body: []models.Agent sorted by id
+func (r *MetricsRouter) agentsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	agents, err := r.metricsUsecase.ListAgents(ctx)
+	if err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+
+	response, err := adapters.ConvertEntityAgents(agents)
+	if err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(response); err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+}
+
+// staleHandler handles endpoint: GET /stale
+//
+// Request: none; optional url query parameters type, prefix and regex select
+// metrics the same way as for GET /values
+//
+// Response type: "application/json", body: []models.StaleMetric, metrics which
+// were not updated within configured interval, sorted by type and name
+func (r *MetricsRouter) staleHandler(res http.ResponseWriter, req *http.Request) {
+	filter, err := adapters.ConvertMetricFilterFromRequest(req)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	metrics, err := r.metricsUsecase.ListStaleMetrics(ctx, *filter, time.Now())
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	// success
+	response, err := adapters.ConvertEntityStaleMetrics(metrics)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(response); err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+}
+
+// alertsHandler handles endpoint: GET /alerts
+//
+// Request: none
+//
+// Response type: "application/json", body: []models.Alert, states of all alert
+// rules in the order they are configured
+func (r *MetricsRouter) alertsHandler(res http.ResponseWriter, req *http.Request) {
+	response := adapters.ConvertEntityAlerts(r.metricsUsecase.ListAlerts())
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(response); err != nil {
+		handleAsInternalServerError(err, res)
+		return
+	}
+}
+
 func (r *MetricsRouter) ping(res http.ResponseWriter, req *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -7,6 +7,7 @@ package handlers
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/entities"
 )
@@ -21,21 +22,57 @@ var _ metricsUsecase = &mockMetricsUsecase{}
 //
 //		// make and configure a mocked metricsUsecase
 //		mockedmetricsUsecase := &mockMetricsUsecase{
+//			DeleteMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+//				panic("mock out the DeleteMetric method")
+//			},
+//			DeleteMetricsFunc: func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
+//				panic("mock out the DeleteMetrics method")
+//			},
 //			DumpIteratorFunc: func(ctx context.Context) (func() (type_ string, name string, value string, exists bool), error) {
 //				panic("mock out the DumpIterator method")
 //			},
+//			FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error) {
+//				panic("mock out the FindMetrics method")
+//			},
+//			GetHistoryFunc: func(ctx context.Context, metric entities.Metric, from time.Time, to time.Time, step time.Duration) ([]entities.Sample, error) {
+//				panic("mock out the GetHistory method")
+//			},
 //			GetMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 //				panic("mock out the GetMetric method")
 //			},
+//			ListAgentsFunc: func(ctx context.Context) ([]entities.Agent, error) {
+//				panic("mock out the ListAgents method")
+//			},
+//			ListAlertsFunc: func() []entities.Alert {
+//				panic("mock out the ListAlerts method")
+//			},
+//			ListStaleMetricsFunc: func(ctx context.Context, filter entities.MetricFilter, now time.Time) ([]entities.StaleMetric, error) {
+//				panic("mock out the ListStaleMetrics method")
+//			},
 //			PingFunc: func(ctx context.Context) error {
 //				panic("mock out the Ping method")
 //			},
+//			ResetCounterFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+//				panic("mock out the ResetCounter method")
+//			},
+//			StaleMetricsFunc: func(metrics []entities.Metric, now time.Time) []entities.StaleMetric {
+//				panic("mock out the StaleMetrics method")
+//			},
+//			SubscribeFunc: func(filter entities.MetricFilter) (<-chan []entities.Metric, func()) {
+//				panic("mock out the Subscribe method")
+//			},
+//			UpdateAgentMetricsFunc: func(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error) {
+//				panic("mock out the UpdateAgentMetrics method")
+//			},
 //			UpdateMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 //				panic("mock out the UpdateMetric method")
 //			},
 //			UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
 //				panic("mock out the UpdateMetrics method")
 //			},
+//			UpdateMetricsOnceFunc: func(ctx context.Context, key string, agent *entities.Agent, metrics []entities.Metric) ([]entities.Metric, bool, error) {
+//				panic("mock out the UpdateMetricsOnce method")
+//			},
 //		}
 //
 //		// use mockedmetricsUsecase in code that requires metricsUsecase
@@ -43,28 +80,98 @@ var _ metricsUsecase = &mockMetricsUsecase{}
 //
 //	}
 type mockMetricsUsecase struct {
+	// DeleteMetricFunc mocks the DeleteMetric method.
+	DeleteMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+
+	// DeleteMetricsFunc mocks the DeleteMetrics method.
+	DeleteMetricsFunc func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+
 	// DumpIteratorFunc mocks the DumpIterator method.
 	DumpIteratorFunc func(ctx context.Context) (func() (type_ string, name string, value string, exists bool), error)
 
+	// FindMetricsFunc mocks the FindMetrics method.
+	FindMetricsFunc func(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error)
+
+	// GetHistoryFunc mocks the GetHistory method.
+	GetHistoryFunc func(ctx context.Context, metric entities.Metric, from time.Time, to time.Time, step time.Duration) ([]entities.Sample, error)
+
 	// GetMetricFunc mocks the GetMetric method.
 	GetMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 
+	// ListAgentsFunc mocks the ListAgents method.
+	ListAgentsFunc func(ctx context.Context) ([]entities.Agent, error)
+
+	// ListAlertsFunc mocks the ListAlerts method.
+	ListAlertsFunc func() []entities.Alert
+
+	// ListStaleMetricsFunc mocks the ListStaleMetrics method.
+	ListStaleMetricsFunc func(ctx context.Context, filter entities.MetricFilter, now time.Time) ([]entities.StaleMetric, error)
+
 	// PingFunc mocks the Ping method.
 	PingFunc func(ctx context.Context) error
 
+	// ResetCounterFunc mocks the ResetCounter method.
+	ResetCounterFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+
+	// StaleMetricsFunc mocks the StaleMetrics method.
+	StaleMetricsFunc func(metrics []entities.Metric, now time.Time) []entities.StaleMetric
+
+	// SubscribeFunc mocks the Subscribe method.
+	SubscribeFunc func(filter entities.MetricFilter) (<-chan []entities.Metric, func())
+
+	// UpdateAgentMetricsFunc mocks the UpdateAgentMetrics method.
+	UpdateAgentMetricsFunc func(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error)
+
 	// UpdateMetricFunc mocks the UpdateMetric method.
 	UpdateMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 
 	// UpdateMetricsFunc mocks the UpdateMetrics method.
 	UpdateMetricsFunc func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
 
+	// UpdateMetricsOnceFunc mocks the UpdateMetricsOnce method.
+	UpdateMetricsOnceFunc func(ctx context.Context, key string, agent *entities.Agent, metrics []entities.Metric) ([]entities.Metric, bool, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// DeleteMetric holds details about calls to the DeleteMetric method.
+		DeleteMetric []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+		}
+		// DeleteMetrics holds details about calls to the DeleteMetrics method.
+		DeleteMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
 		// DumpIterator holds details about calls to the DumpIterator method.
 		DumpIterator []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// FindMetrics holds details about calls to the FindMetrics method.
+		FindMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter entities.MetricFilter
+		}
+		// GetHistory holds details about calls to the GetHistory method.
+		GetHistory []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+			// From is the from argument value.
+			From time.Time
+			// To is the to argument value.
+			To time.Time
+			// Step is the step argument value.
+			Step time.Duration
+		}
 		// GetMetric holds details about calls to the GetMetric method.
 		GetMetric []struct {
 			// Ctx is the ctx argument value.
@@ -72,11 +179,56 @@ type mockMetricsUsecase struct {
 			// Metric is the metric argument value.
 			Metric entities.Metric
 		}
+		// ListAgents holds details about calls to the ListAgents method.
+		ListAgents []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// ListAlerts holds details about calls to the ListAlerts method.
+		ListAlerts []struct {
+		}
+		// ListStaleMetrics holds details about calls to the ListStaleMetrics method.
+		ListStaleMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter entities.MetricFilter
+			// Now is the now argument value.
+			Now time.Time
+		}
 		// Ping holds details about calls to the Ping method.
 		Ping []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// ResetCounter holds details about calls to the ResetCounter method.
+		ResetCounter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+		}
+		// StaleMetrics holds details about calls to the StaleMetrics method.
+		StaleMetrics []struct {
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+			// Now is the now argument value.
+			Now time.Time
+		}
+		// Subscribe holds details about calls to the Subscribe method.
+		Subscribe []struct {
+			// Filter is the filter argument value.
+			Filter entities.MetricFilter
+		}
+		// UpdateAgentMetrics holds details about calls to the UpdateAgentMetrics method.
+		UpdateAgentMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Agent is the agent argument value.
+			Agent entities.Agent
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
 		// UpdateMetric holds details about calls to the UpdateMetric method.
 		UpdateMetric []struct {
 			// Ctx is the ctx argument value.
@@ -91,12 +243,107 @@ type mockMetricsUsecase struct {
 			// Metrics is the metrics argument value.
 			Metrics []entities.Metric
 		}
+		// UpdateMetricsOnce holds details about calls to the UpdateMetricsOnce method.
+		UpdateMetricsOnce []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+			// Agent is the agent argument value.
+			Agent *entities.Agent
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
 	}
-	lockDumpIterator  sync.RWMutex
-	lockGetMetric     sync.RWMutex
-	lockPing          sync.RWMutex
-	lockUpdateMetric  sync.RWMutex
-	lockUpdateMetrics sync.RWMutex
+	lockDeleteMetric       sync.RWMutex
+	lockDeleteMetrics      sync.RWMutex
+	lockDumpIterator       sync.RWMutex
+	lockFindMetrics        sync.RWMutex
+	lockGetHistory         sync.RWMutex
+	lockGetMetric          sync.RWMutex
+	lockListAgents         sync.RWMutex
+	lockListAlerts         sync.RWMutex
+	lockListStaleMetrics   sync.RWMutex
+	lockPing               sync.RWMutex
+	lockResetCounter       sync.RWMutex
+	lockStaleMetrics       sync.RWMutex
+	lockSubscribe          sync.RWMutex
+	lockUpdateAgentMetrics sync.RWMutex
+	lockUpdateMetric       sync.RWMutex
+	lockUpdateMetrics      sync.RWMutex
+	lockUpdateMetricsOnce  sync.RWMutex
+}
+
+// DeleteMetric calls DeleteMetricFunc.
+func (mock *mockMetricsUsecase) DeleteMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+	if mock.DeleteMetricFunc == nil {
+		panic("mockMetricsUsecase.DeleteMetricFunc: method is nil but metricsUsecase.DeleteMetric was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+	}
+	mock.lockDeleteMetric.Lock()
+	mock.calls.DeleteMetric = append(mock.calls.DeleteMetric, callInfo)
+	mock.lockDeleteMetric.Unlock()
+	return mock.DeleteMetricFunc(ctx, metric)
+}
+
+// DeleteMetricCalls gets all the calls that were made to DeleteMetric.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.DeleteMetricCalls())
+func (mock *mockMetricsUsecase) DeleteMetricCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}
+	mock.lockDeleteMetric.RLock()
+	calls = mock.calls.DeleteMetric
+	mock.lockDeleteMetric.RUnlock()
+	return calls
+}
+
+// DeleteMetrics calls DeleteMetricsFunc.
+func (mock *mockMetricsUsecase) DeleteMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
+	if mock.DeleteMetricsFunc == nil {
+		panic("mockMetricsUsecase.DeleteMetricsFunc: method is nil but metricsUsecase.DeleteMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Metrics: metrics,
+	}
+	mock.lockDeleteMetrics.Lock()
+	mock.calls.DeleteMetrics = append(mock.calls.DeleteMetrics, callInfo)
+	mock.lockDeleteMetrics.Unlock()
+	return mock.DeleteMetricsFunc(ctx, metrics)
+}
+
+// DeleteMetricsCalls gets all the calls that were made to DeleteMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.DeleteMetricsCalls())
+func (mock *mockMetricsUsecase) DeleteMetricsCalls() []struct {
+	Ctx     context.Context
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Metrics []entities.Metric
+	}
+	mock.lockDeleteMetrics.RLock()
+	calls = mock.calls.DeleteMetrics
+	mock.lockDeleteMetrics.RUnlock()
+	return calls
 }
 
 // DumpIterator calls DumpIteratorFunc.
@@ -131,6 +378,90 @@ func (mock *mockMetricsUsecase) DumpIteratorCalls() []struct {
 	return calls
 }
 
+// FindMetrics calls FindMetricsFunc.
+func (mock *mockMetricsUsecase) FindMetrics(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error) {
+	if mock.FindMetricsFunc == nil {
+		panic("mockMetricsUsecase.FindMetricsFunc: method is nil but metricsUsecase.FindMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter entities.MetricFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockFindMetrics.Lock()
+	mock.calls.FindMetrics = append(mock.calls.FindMetrics, callInfo)
+	mock.lockFindMetrics.Unlock()
+	return mock.FindMetricsFunc(ctx, filter)
+}
+
+// FindMetricsCalls gets all the calls that were made to FindMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.FindMetricsCalls())
+func (mock *mockMetricsUsecase) FindMetricsCalls() []struct {
+	Ctx    context.Context
+	Filter entities.MetricFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter entities.MetricFilter
+	}
+	mock.lockFindMetrics.RLock()
+	calls = mock.calls.FindMetrics
+	mock.lockFindMetrics.RUnlock()
+	return calls
+}
+
+// GetHistory calls GetHistoryFunc.
+func (mock *mockMetricsUsecase) GetHistory(ctx context.Context, metric entities.Metric, from time.Time, to time.Time, step time.Duration) ([]entities.Sample, error) {
+	if mock.GetHistoryFunc == nil {
+		panic("mockMetricsUsecase.GetHistoryFunc: method is nil but metricsUsecase.GetHistory was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+		From   time.Time
+		To     time.Time
+		Step   time.Duration
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+		From:   from,
+		To:     to,
+		Step:   step,
+	}
+	mock.lockGetHistory.Lock()
+	mock.calls.GetHistory = append(mock.calls.GetHistory, callInfo)
+	mock.lockGetHistory.Unlock()
+	return mock.GetHistoryFunc(ctx, metric, from, to, step)
+}
+
+// GetHistoryCalls gets all the calls that were made to GetHistory.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.GetHistoryCalls())
+func (mock *mockMetricsUsecase) GetHistoryCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+	From   time.Time
+	To     time.Time
+	Step   time.Duration
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+		From   time.Time
+		To     time.Time
+		Step   time.Duration
+	}
+	mock.lockGetHistory.RLock()
+	calls = mock.calls.GetHistory
+	mock.lockGetHistory.RUnlock()
+	return calls
+}
+
 // GetMetric calls GetMetricFunc.
 func (mock *mockMetricsUsecase) GetMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 	if mock.GetMetricFunc == nil {
@@ -167,6 +498,105 @@ func (mock *mockMetricsUsecase) GetMetricCalls() []struct {
 	return calls
 }
 
+// ListAgents calls ListAgentsFunc.
+func (mock *mockMetricsUsecase) ListAgents(ctx context.Context) ([]entities.Agent, error) {
+	if mock.ListAgentsFunc == nil {
+		panic("mockMetricsUsecase.ListAgentsFunc: method is nil but metricsUsecase.ListAgents was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListAgents.Lock()
+	mock.calls.ListAgents = append(mock.calls.ListAgents, callInfo)
+	mock.lockListAgents.Unlock()
+	return mock.ListAgentsFunc(ctx)
+}
+
+// ListAgentsCalls gets all the calls that were made to ListAgents.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.ListAgentsCalls())
+func (mock *mockMetricsUsecase) ListAgentsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListAgents.RLock()
+	calls = mock.calls.ListAgents
+	mock.lockListAgents.RUnlock()
+	return calls
+}
+
+// ListAlerts calls ListAlertsFunc.
+func (mock *mockMetricsUsecase) ListAlerts() []entities.Alert {
+	if mock.ListAlertsFunc == nil {
+		panic("mockMetricsUsecase.ListAlertsFunc: method is nil but metricsUsecase.ListAlerts was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListAlerts.Lock()
+	mock.calls.ListAlerts = append(mock.calls.ListAlerts, callInfo)
+	mock.lockListAlerts.Unlock()
+	return mock.ListAlertsFunc()
+}
+
+// ListAlertsCalls gets all the calls that were made to ListAlerts.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.ListAlertsCalls())
+func (mock *mockMetricsUsecase) ListAlertsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListAlerts.RLock()
+	calls = mock.calls.ListAlerts
+	mock.lockListAlerts.RUnlock()
+	return calls
+}
+
+// ListStaleMetrics calls ListStaleMetricsFunc.
+func (mock *mockMetricsUsecase) ListStaleMetrics(ctx context.Context, filter entities.MetricFilter, now time.Time) ([]entities.StaleMetric, error) {
+	if mock.ListStaleMetricsFunc == nil {
+		panic("mockMetricsUsecase.ListStaleMetricsFunc: method is nil but metricsUsecase.ListStaleMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter entities.MetricFilter
+		Now    time.Time
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+		Now:    now,
+	}
+	mock.lockListStaleMetrics.Lock()
+	mock.calls.ListStaleMetrics = append(mock.calls.ListStaleMetrics, callInfo)
+	mock.lockListStaleMetrics.Unlock()
+	return mock.ListStaleMetricsFunc(ctx, filter, now)
+}
+
+// ListStaleMetricsCalls gets all the calls that were made to ListStaleMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.ListStaleMetricsCalls())
+func (mock *mockMetricsUsecase) ListStaleMetricsCalls() []struct {
+	Ctx    context.Context
+	Filter entities.MetricFilter
+	Now    time.Time
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter entities.MetricFilter
+		Now    time.Time
+	}
+	mock.lockListStaleMetrics.RLock()
+	calls = mock.calls.ListStaleMetrics
+	mock.lockListStaleMetrics.RUnlock()
+	return calls
+}
+
 // Ping calls PingFunc.
 func (mock *mockMetricsUsecase) Ping(ctx context.Context) error {
 	if mock.PingFunc == nil {
@@ -199,6 +629,150 @@ func (mock *mockMetricsUsecase) PingCalls() []struct {
 	return calls
 }
 
+// ResetCounter calls ResetCounterFunc.
+func (mock *mockMetricsUsecase) ResetCounter(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+	if mock.ResetCounterFunc == nil {
+		panic("mockMetricsUsecase.ResetCounterFunc: method is nil but metricsUsecase.ResetCounter was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+	}
+	mock.lockResetCounter.Lock()
+	mock.calls.ResetCounter = append(mock.calls.ResetCounter, callInfo)
+	mock.lockResetCounter.Unlock()
+	return mock.ResetCounterFunc(ctx, metric)
+}
+
+// ResetCounterCalls gets all the calls that were made to ResetCounter.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.ResetCounterCalls())
+func (mock *mockMetricsUsecase) ResetCounterCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}
+	mock.lockResetCounter.RLock()
+	calls = mock.calls.ResetCounter
+	mock.lockResetCounter.RUnlock()
+	return calls
+}
+
+// StaleMetrics calls StaleMetricsFunc.
+func (mock *mockMetricsUsecase) StaleMetrics(metrics []entities.Metric, now time.Time) []entities.StaleMetric {
+	if mock.StaleMetricsFunc == nil {
+		panic("mockMetricsUsecase.StaleMetricsFunc: method is nil but metricsUsecase.StaleMetrics was just called")
+	}
+	callInfo := struct {
+		Metrics []entities.Metric
+		Now     time.Time
+	}{
+		Metrics: metrics,
+		Now:     now,
+	}
+	mock.lockStaleMetrics.Lock()
+	mock.calls.StaleMetrics = append(mock.calls.StaleMetrics, callInfo)
+	mock.lockStaleMetrics.Unlock()
+	return mock.StaleMetricsFunc(metrics, now)
+}
+
+// StaleMetricsCalls gets all the calls that were made to StaleMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.StaleMetricsCalls())
+func (mock *mockMetricsUsecase) StaleMetricsCalls() []struct {
+	Metrics []entities.Metric
+	Now     time.Time
+} {
+	var calls []struct {
+		Metrics []entities.Metric
+		Now     time.Time
+	}
+	mock.lockStaleMetrics.RLock()
+	calls = mock.calls.StaleMetrics
+	mock.lockStaleMetrics.RUnlock()
+	return calls
+}
+
+// Subscribe calls SubscribeFunc.
+func (mock *mockMetricsUsecase) Subscribe(filter entities.MetricFilter) (<-chan []entities.Metric, func()) {
+	if mock.SubscribeFunc == nil {
+		panic("mockMetricsUsecase.SubscribeFunc: method is nil but metricsUsecase.Subscribe was just called")
+	}
+	callInfo := struct {
+		Filter entities.MetricFilter
+	}{
+		Filter: filter,
+	}
+	mock.lockSubscribe.Lock()
+	mock.calls.Subscribe = append(mock.calls.Subscribe, callInfo)
+	mock.lockSubscribe.Unlock()
+	return mock.SubscribeFunc(filter)
+}
+
+// SubscribeCalls gets all the calls that were made to Subscribe.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.SubscribeCalls())
+func (mock *mockMetricsUsecase) SubscribeCalls() []struct {
+	Filter entities.MetricFilter
+} {
+	var calls []struct {
+		Filter entities.MetricFilter
+	}
+	mock.lockSubscribe.RLock()
+	calls = mock.calls.Subscribe
+	mock.lockSubscribe.RUnlock()
+	return calls
+}
+
+// UpdateAgentMetrics calls UpdateAgentMetricsFunc.
+func (mock *mockMetricsUsecase) UpdateAgentMetrics(ctx context.Context, agent entities.Agent, metrics []entities.Metric) ([]entities.Metric, error) {
+	if mock.UpdateAgentMetricsFunc == nil {
+		panic("mockMetricsUsecase.UpdateAgentMetricsFunc: method is nil but metricsUsecase.UpdateAgentMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Agent   entities.Agent
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Agent:   agent,
+		Metrics: metrics,
+	}
+	mock.lockUpdateAgentMetrics.Lock()
+	mock.calls.UpdateAgentMetrics = append(mock.calls.UpdateAgentMetrics, callInfo)
+	mock.lockUpdateAgentMetrics.Unlock()
+	return mock.UpdateAgentMetricsFunc(ctx, agent, metrics)
+}
+
+// UpdateAgentMetricsCalls gets all the calls that were made to UpdateAgentMetrics.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.UpdateAgentMetricsCalls())
+func (mock *mockMetricsUsecase) UpdateAgentMetricsCalls() []struct {
+	Ctx     context.Context
+	Agent   entities.Agent
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Agent   entities.Agent
+		Metrics []entities.Metric
+	}
+	mock.lockUpdateAgentMetrics.RLock()
+	calls = mock.calls.UpdateAgentMetrics
+	mock.lockUpdateAgentMetrics.RUnlock()
+	return calls
+}
+
 // UpdateMetric calls UpdateMetricFunc.
 func (mock *mockMetricsUsecase) UpdateMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 	if mock.UpdateMetricFunc == nil {
@@ -270,3 +844,47 @@ func (mock *mockMetricsUsecase) UpdateMetricsCalls() []struct {
 	mock.lockUpdateMetrics.RUnlock()
 	return calls
 }
+
+// UpdateMetricsOnce calls UpdateMetricsOnceFunc.
+func (mock *mockMetricsUsecase) UpdateMetricsOnce(ctx context.Context, key string, agent *entities.Agent, metrics []entities.Metric) ([]entities.Metric, bool, error) {
+	if mock.UpdateMetricsOnceFunc == nil {
+		panic("mockMetricsUsecase.UpdateMetricsOnceFunc: method is nil but metricsUsecase.UpdateMetricsOnce was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Key     string
+		Agent   *entities.Agent
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Key:     key,
+		Agent:   agent,
+		Metrics: metrics,
+	}
+	mock.lockUpdateMetricsOnce.Lock()
+	mock.calls.UpdateMetricsOnce = append(mock.calls.UpdateMetricsOnce, callInfo)
+	mock.lockUpdateMetricsOnce.Unlock()
+	return mock.UpdateMetricsOnceFunc(ctx, key, agent, metrics)
+}
+
+// UpdateMetricsOnceCalls gets all the calls that were made to UpdateMetricsOnce.
+// Check the length with:
+//
+//	len(mockedmetricsUsecase.UpdateMetricsOnceCalls())
+func (mock *mockMetricsUsecase) UpdateMetricsOnceCalls() []struct {
+	Ctx     context.Context
+	Key     string
+	Agent   *entities.Agent
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Key     string
+		Agent   *entities.Agent
+		Metrics []entities.Metric
+	}
+	mock.lockUpdateMetricsOnce.RLock()
+	calls = mock.calls.UpdateMetricsOnce
+	mock.lockUpdateMetricsOnce.RUnlock()
+	return calls
+}
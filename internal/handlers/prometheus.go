@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"strings"
+)
+
+// prometheusContentType is content type of Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// prometheusTypes maps dumped metric type to Prometheus metric type; metrics
+// of other types are not exposed
+var prometheusTypes = map[string]string{
+	"gauge":   "gauge",
+	"counter": "counter",
+}
+
+// sanitizePrometheusName converts metric name to the one allowed by
+// Prometheus, i.e. matching [a-zA-Z_:][a-zA-Z0-9_:]*, by replacing every
+// invalid character with underscore
+func sanitizePrometheusName(name string) string {
+	if len(name) == 0 {
+		return "_"
+	}
+	var sb strings.Builder
+	sb.Grow(len(name) + 1)
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+			sb.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+// writePrometheusType writes TYPE line of metric family, e.g.
+//
+//	# TYPE Alloc gauge
+func writePrometheusType(sb *strings.Builder, type_ string, name string) {
+	sb.WriteString("# TYPE ")
+	sb.WriteString(name)
+	sb.WriteString(" ")
+	sb.WriteString(type_)
+	sb.WriteString("\n")
+}
+
+// writePrometheusSample writes sample line, e.g.
+//
+//	Alloc{host="a"} 123456
+//
+// labels are expected in canonical representation, see entities.Labels.String
+func writePrometheusSample(sb *strings.Builder, name string, labels string, value string) {
+	sb.WriteString(name)
+	if len(labels) > 0 {
+		sb.WriteString("{")
+		sb.WriteString(labels)
+		sb.WriteString("}")
+	}
+	sb.WriteString(" ")
+	sb.WriteString(value)
+	sb.WriteString("\n")
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheus(t *testing.T) {
+	type given struct {
+		method      string
+		url         string
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code        int
+		response    string
+		contentType string
+		callCount   int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "prometheus: filled",
+			given: given{
+				method: http.MethodGet,
+				url:    "/metrics",
+				mockUsecase: &mockMetricsUsecase{
+					DumpIteratorFunc: func(ctx context.Context) (
+						func() (type_ string, name string, value string, exists bool), error,
+					) {
+						callNumber := 0
+						fn := func() (type_ string, name string, value string, exists bool) {
+							callNumber++
+							switch callNumber {
+							case 1:
+								return "gauge", "Alloc", "1.23", true
+							case 2:
+								return "gauge", `Alloc{host="a"}`, "2.5", true
+							case 3:
+								return "gauge", "1st metric.name-x", "4.56", true
+							case 4:
+								return "counter", "PollCount", "789", true
+							case 5:
+								return "counter", "Alloc", "10", true
+							case 6:
+								return "histogram", "GCPauseSeconds", "count=0 sum=0 buckets=[+Inf:0]", true
+							default:
+								return "", "", "", false
+							}
+						}
+						var err error
+						return fn, err
+					},
+				},
+			},
+			want: want{
+				code: http.StatusOK,
+				response: `# TYPE Alloc gauge
+Alloc 1.23
+Alloc{host="a"} 2.5
+# TYPE _1st_metric_name_x gauge
+_1st_metric_name_x 4.56
+# TYPE PollCount counter
+PollCount 789
+`,
+				contentType: "text/plain; version=0.0.4; charset=utf-8",
+				callCount:   1,
+			},
+		},
+		{
+			name: "prometheus: some error",
+			given: given{
+				method: http.MethodGet,
+				url:    "/metrics",
+				mockUsecase: &mockMetricsUsecase{
+					DumpIteratorFunc: func(ctx context.Context) (
+						func() (type_ string, name string, value string, exists bool), error,
+					) {
+						return nil, errors.New("some error")
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusInternalServerError,
+				response:    "some error\n",
+				contentType: "text/plain; charset=utf-8",
+				callCount:   1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, respContentType, respBody := testRequest(
+				t, ts, tt.given.method, tt.given.url)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.contentType, respContentType)
+			assert.Equal(t, tt.want.response, respBody)
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.DumpIterator))
+		})
+	}
+}
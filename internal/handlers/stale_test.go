@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStale(t *testing.T) {
+	type given struct {
+		url         string
+		mockUsecase *mockMetricsUsecase
+	}
+	type want struct {
+		code        int
+		response    string
+		contentType string
+		callCount   int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "stale: positive",
+			given: given{
+				url: "/stale?type=gauge&prefix=A",
+				mockUsecase: &mockMetricsUsecase{
+					ListStaleMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+						now time.Time,
+					) ([]entities.StaleMetric, error) {
+						require.Equal(t, []entities.MetricType{entities.MetricTypeGauge}, filter.Types)
+						require.Equal(t, "A", filter.NamePrefix)
+						return []entities.StaleMetric{
+							{
+								Metric: entities.Metric{
+									Type:   entities.MetricTypeGauge,
+									Name:   "Alloc",
+									Labels: entities.Labels{"hostname": "host1"},
+									Value:  1.5,
+								},
+								LastUpdate: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+							},
+							{
+								Metric: entities.Metric{Type: entities.MetricTypeGauge, Name: "Avail", Value: 2},
+							},
+						}, nil
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusOK,
+				response:    `[{"id":"Alloc","type":"gauge","labels":{"hostname":"host1"},"value":1.5,"last_update":"2025-01-02T03:04:05Z"},{"id":"Avail","type":"gauge","value":2}]`,
+				contentType: "application/json",
+				callCount:   1,
+			},
+		},
+		{
+			name: "stale: invalid filter",
+			given: given{
+				url:         "/stale?type=foo",
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				contentType: "text/plain; charset=utf-8",
+				callCount:   0,
+			},
+		},
+		{
+			name: "stale: some error",
+			given: given{
+				url: "/stale",
+				mockUsecase: &mockMetricsUsecase{
+					ListStaleMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+						now time.Time,
+					) ([]entities.StaleMetric, error) {
+						return nil, errors.New("some error")
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusInternalServerError,
+				response:    "some error",
+				contentType: "text/plain; charset=utf-8",
+				callCount:   1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewMetricsRouter(tt.given.mockUsecase).WithAllHandlers()
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			respCode, respContentType, respBody := testRequest(
+				t, ts, http.MethodGet, tt.given.url)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.want.code, respCode)
+			assert.Equal(t, tt.want.contentType, respContentType)
+			if len(tt.want.response) > 0 {
+				assert.Equal(t, tt.want.response, strings.TrimSpace(respBody))
+			}
+			assert.Equal(t, tt.want.callCount, len(tt.given.mockUsecase.calls.ListStaleMetrics))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/handlers/adapters"
+)
+
+// streamKeepAlive is the interval of comments sent to idle stream to keep the
+// connection open
+const streamKeepAlive = 15 * time.Second
+
+// streamHandler handles endpoint: GET /stream
+//
+// Request: none; optional url query parameters type, prefix and regex select
+// metrics the same way as for GET /values
+//
+// Response type: "text/event-stream", body: server-sent events "update" with
+// data []models.Metric, one event per successful update request; the stream is
+// closed if the client doesn't keep up with updates
+func (r *MetricsRouter) streamHandler(res http.ResponseWriter, req *http.Request) {
+	filter, err := adapters.ConvertMetricFilterFromRequest(req)
+	if err != nil {
+		handleGetterError(err, res, req)
+		return
+	}
+
+	updates, unsubscribe := r.metricsUsecase.Subscribe(*filter)
+	defer unsubscribe()
+
+	rc := http.NewResponseController(res)
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Error("[stream] flush error", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case metrics, ok := <-updates:
+			if !ok {
+				// dropped as slow subscriber or server is shutting down
+				return
+			}
+			response, err := adapters.ConvertEntityMetrics(metrics)
+			if err != nil {
+				slog.Error("[stream] convert error", "error", err)
+				continue
+			}
+			data, err := json.Marshal(response)
+			if err != nil {
+				slog.Error("[stream] marshal error", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: update\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
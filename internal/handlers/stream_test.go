@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	updates := make(chan []entities.Metric, 2)
+	updates <- []entities.Metric{
+		{Type: entities.MetricTypeGauge, Name: "Alloc", Value: 1.5},
+	}
+	updates <- []entities.Metric{
+		{Type: entities.MetricTypeCounter, Name: "PollCount", Delta: 2},
+	}
+	close(updates) // e.g. server is shutting down
+
+	unsubscribed := false
+	mockUsecase := &mockMetricsUsecase{
+		SubscribeFunc: func(filter entities.MetricFilter,
+		) (<-chan []entities.Metric, func()) {
+			require.Equal(t, "Poll", filter.NamePrefix)
+			return updates, func() { unsubscribed = true }
+		},
+	}
+	r := NewMetricsRouter(mockUsecase).WithAllHandlers()
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	respCode, respContentType, respBody := testRequest(
+		t, ts, http.MethodGet, "/stream?prefix=Poll")
+	assert.Equal(t, http.StatusOK, respCode)
+	assert.Equal(t, "text/event-stream", respContentType)
+	assert.Equal(t, "event: update\n"+
+		`data: [{"id":"Alloc","type":"gauge","value":1.5}]`+"\n\n"+
+		"event: update\n"+
+		`data: [{"id":"PollCount","type":"counter","delta":2}]`+"\n\n",
+		respBody)
+	assert.True(t, unsubscribed)
+}
+
+func TestStream_InvalidFilter(t *testing.T) {
+	mockUsecase := &mockMetricsUsecase{}
+	r := NewMetricsRouter(mockUsecase).WithAllHandlers()
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	respCode, _, _ := testRequest(t, ts, http.MethodGet, "/stream?regex=(")
+	assert.Equal(t, http.StatusBadRequest, respCode)
+	assert.Equal(t, 0, len(mockUsecase.calls.Subscribe))
+}
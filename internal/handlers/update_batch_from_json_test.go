@@ -73,6 +73,54 @@ func TestUpdateBatchFromJSON(t *testing.T) {
 				callCount:   1,
 			},
 		},
+		{
+			name: "update batch: histogram",
+			given: given{
+				method: http.MethodPost,
+				url:    "/updates/",
+				body:   `[{"id":"baz","type":"histogram","buckets":[0.5,1],"counts":[1,0,2],"count":3,"sum":4.25}]`,
+				mockUsecase: &mockMetricsUsecase{
+					UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric,
+					) ([]entities.Metric, error) {
+						expectedMetrics := []entities.Metric{
+							{
+								Type: entities.MetricTypeHistogram,
+								Name: "baz",
+								Histogram: entities.Histogram{
+									Bounds: []float64{0.5, 1},
+									Counts: []uint64{1, 0, 2},
+									Count:  3,
+									Sum:    4.25,
+								},
+							},
+						}
+						require.Equal(t, expectedMetrics, metrics)
+						return expectedMetrics, nil
+					},
+				},
+			},
+			want: want{
+				code:        http.StatusOK,
+				response:    `[{"id":"baz","type":"histogram","buckets":[0.5,1],"counts":[1,0,2],"count":3,"sum":4.25}]`,
+				contentType: "application/json",
+				callCount:   1,
+			},
+		},
+		{
+			name: "update batch: inconsistent histogram",
+			given: given{
+				method:      http.MethodPost,
+				url:         "/updates/",
+				body:        `[{"id":"baz","type":"histogram","buckets":[0.5,1],"counts":[1,0,2],"count":5,"sum":4.25}]`,
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				response:    "metric[0]: invalid metric value: histogram count should be equal to sum of counts",
+				contentType: "text/plain; charset=utf-8",
+				callCount:   0,
+			},
+		},
 		{
 			name: "update batch: invalid method",
 			given: given{
@@ -114,6 +114,21 @@ func TestUpdateFromJSON(t *testing.T) {
 				callCount:   0,
 			},
 		},
+		{
+			name: "update: metric name with braces",
+			given: given{
+				method:      http.MethodPost,
+				url:         "/update/",
+				body:        `{"id":"foo{bar=\"baz\"}","type":"gauge","value":1.23}`,
+				mockUsecase: &mockMetricsUsecase{},
+			},
+			want: want{
+				code:        http.StatusBadRequest,
+				response:    `metric name should not contain { or }: "foo{bar=\"baz\"}"`,
+				contentType: "text/plain; charset=utf-8",
+				callCount:   0,
+			},
+		},
 		{
 			name: "update: unexpected metric type",
 			given: given{
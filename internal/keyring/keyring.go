@@ -0,0 +1,152 @@
+// Package keyring хранит ключи подписи HMAC-SHA256 с идентификаторами:
+// основной ключ, которым подписываются сообщения, и все ключи, подпись
+// которыми принимается при проверке. Это позволяет менять ключ без простоя:
+// сначала новый ключ добавляется в принимаемые, затем становится основным,
+// затем старый ключ удаляется.
+package keyring
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownKeyID означает, что сообщение подписано ключом, которого нет
+// среди принимаемых
+var ErrUnknownKeyID = errors.New("unknown key id")
+
+// Key — ключ подписи; ключ с пустым идентификатором задается флагом -k
+// (переменной окружения KEY) и передается без заголовка KeyID, как до
+// появления идентификаторов
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// Keyring безопасен для одновременного использования, ключи из файла
+// перечитываются методом Reload
+type Keyring struct {
+	mu       sync.RWMutex
+	key      string            // ключ с пустым идентификатором, не используется, если пуст
+	path     string            // файл ключей, не используется, если пуст
+	primary  *Key              // nil, если ключей нет
+	accepted map[string]string // идентификатор -> ключ
+}
+
+// New возвращает неизменяемый набор ключей, первый из которых основной;
+// ключи с пустым значением пропускаются
+func New(keys ...Key) *Keyring {
+	result := &Keyring{}
+	// keys with unique ids can't fail
+	_ = result.set(keys)
+	return result
+}
+
+// Load возвращает набор ключей из файла path (если задан) и ключа key с
+// пустым идентификатором (если задан).
+//
+// Файл содержит по одному ключу в строке в виде `идентификатор ключ`; пустые
+// строки и строки, начинающиеся с #, пропускаются. Основной ключ — первый в
+// файле, а если файл не задан или пуст, то key.
+func Load(key string, path string) (*Keyring, error) {
+	result := &Keyring{
+		key:  key,
+		path: path,
+	}
+	if err := result.Reload(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Reload перечитывает файл ключей; при ошибке прежние ключи сохраняются
+func (k *Keyring) Reload() error {
+	var keys []Key
+	if len(k.path) > 0 {
+		data, err := os.ReadFile(k.path)
+		if err != nil {
+			return fmt.Errorf("read key file: %w", err)
+		}
+		if keys, err = parse(data); err != nil {
+			return fmt.Errorf("key file %v: %w", k.path, err)
+		}
+	}
+	keys = append(keys, Key{Secret: k.key})
+	return k.set(keys)
+}
+
+func parse(data []byte) ([]Key, error) {
+	var result []Key
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %v: `id key` expected", line)
+		}
+		result = append(result, Key{ID: fields[0], Secret: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (k *Keyring) set(keys []Key) error {
+	var primary *Key
+	accepted := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if len(key.Secret) == 0 {
+			continue
+		}
+		if _, ok := accepted[key.ID]; ok {
+			return fmt.Errorf("duplicate key id: %q", key.ID)
+		}
+		accepted[key.ID] = key.Secret
+		if primary == nil {
+			primary = &key
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary = primary
+	k.accepted = accepted
+	return nil
+}
+
+// Primary возвращает основной ключ; ok ложно, если ключей нет
+func (k *Keyring) Primary() (key Key, ok bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.primary == nil {
+		return Key{}, false
+	}
+	return *k.primary, true
+}
+
+// Lookup возвращает принимаемый ключ с идентификатором id или
+// ErrUnknownKeyID
+func (k *Keyring) Lookup(id string) (Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	secret, ok := k.accepted[id]
+	if !ok {
+		return Key{}, fmt.Errorf("%w: %q", ErrUnknownKeyID, id)
+	}
+	return Key{ID: id, Secret: secret}, nil
+}
+
+// Empty сообщает, что ключей нет, то есть подпись не используется
+func (k *Keyring) Empty() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary == nil
+}
@@ -0,0 +1,104 @@
+package keyring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		file        string // key file is not used if empty
+		wantErr     bool
+		wantPrimary Key
+		wantEmpty   bool
+		accepted    []Key
+	}{
+		{
+			name:      "no keys",
+			wantEmpty: true,
+		},
+		{
+			name:        "single key",
+			key:         "secret",
+			wantPrimary: Key{Secret: "secret"},
+			accepted:    []Key{{Secret: "secret"}},
+		},
+		{
+			name:        "key file and key",
+			key:         "secret",
+			file:        "# rotated 2026-10-01\n2 new\n\n1 old\n",
+			wantPrimary: Key{ID: "2", Secret: "new"},
+			accepted:    []Key{{ID: "2", Secret: "new"}, {ID: "1", Secret: "old"}, {Secret: "secret"}},
+		},
+		{
+			name:    "malformed line",
+			file:    "2 new extra\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			file:    "1 new\n1 old\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			if len(tt.file) > 0 {
+				path = filepath.Join(t.TempDir(), "keys")
+				require.NoError(t, os.WriteFile(path, []byte(tt.file), 0o600))
+			}
+			keys, err := Load(tt.key, path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEmpty, keys.Empty())
+			primary, ok := keys.Primary()
+			assert.Equal(t, !tt.wantEmpty, ok)
+			assert.Equal(t, tt.wantPrimary, primary)
+			for _, want := range tt.accepted {
+				got, err := keys.Lookup(want.ID)
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+			_, err = keys.Lookup("unknown")
+			assert.ErrorIs(t, err, ErrUnknownKeyID)
+		})
+	}
+}
+
+func TestKeyring_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("1 old\n"), 0o600))
+	keys, err := Load("", path)
+	require.NoError(t, err)
+
+	// new key is added as primary, old one is still accepted
+	require.NoError(t, os.WriteFile(path, []byte("2 new\n1 old\n"), 0o600))
+	require.NoError(t, keys.Reload())
+	primary, ok := keys.Primary()
+	require.True(t, ok)
+	assert.Equal(t, Key{ID: "2", Secret: "new"}, primary)
+	_, err = keys.Lookup("1")
+	assert.NoError(t, err)
+
+	// broken file keeps previous keys
+	require.NoError(t, os.WriteFile(path, []byte("broken\n"), 0o600))
+	assert.Error(t, keys.Reload())
+	_, err = keys.Lookup("1")
+	assert.NoError(t, err)
+
+	// old key is removed
+	require.NoError(t, os.WriteFile(path, []byte("2 new\n"), 0o600))
+	require.NoError(t, keys.Reload())
+	_, err = keys.Lookup("1")
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
@@ -27,9 +27,11 @@ func newCompressWriter(w http.ResponseWriter) *compressibleWriter {
 
 func (c *compressibleWriter) Write(p []byte) (int, error) {
 	if c.compress == nil {
+		// параметры Content-Type (например, charset) не учитываются
+		mediaType, _, _ := strings.Cut(c.ResponseWriter.Header().Get("Content-Type"), ";")
 		compress := slices.Contains(
 			[]string{"application/json", "text/html"},
-			c.ResponseWriter.Header().Get("Content-Type"))
+			strings.TrimSpace(mediaType))
 		if compress {
 			c.zw = gzip.NewWriter(c.ResponseWriter)
 			c.ResponseWriter.Header().Set("Content-Encoding", "gzip")
@@ -43,6 +45,19 @@ func (c *compressibleWriter) Write(p []byte) (int, error) {
 	}
 }
 
+// Flush досылает сжатые данные из буфера, например, для потоковых ответов
+func (c *compressibleWriter) Flush() {
+	if c.zw != nil {
+		_ = c.zw.Flush()
+	}
+	_ = http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного writer
+func (c *compressibleWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
 // Close закрывает gzip.Writer и досылает все данные из буфера.
 func (c *compressibleWriter) Close() error {
 	if c.zw != nil {
@@ -5,40 +5,148 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"hash"
 	"io"
 	"net/http"
+	"strings"
+
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+)
+
+// IntegrityHeader holds hex encoded HMAC-SHA256 signature of the body.
+//
+// The signature covers the body exactly as it is transferred: the request
+// body after compression and encryption, the response body after
+// compression. Therefore Integrity must be registered before the rsa
+// decoder and Encoding, and the client must sign the request after
+// encrypting it (see SignRequest) and verify the response before
+// decompressing it (see VerifyResponse).
+const IntegrityHeader = "HashSHA256"
+
+// KeyIDHeader holds id of the key, which IntegrityHeader is calculated with;
+// missing header means the key with empty id
+const KeyIDHeader = "KeyID"
+
+var (
+	ErrMissingSignature = errors.New("missing signature")
+	ErrInvalidSignature = errors.New("invalid signature")
 )
 
-const integrityKey = "HashSHA256"
+func sign(body []byte, key string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verify compares signatures in constant time
+func verify(body []byte, key string, hexSum string) error {
+	if len(hexSum) == 0 {
+		return ErrMissingSignature
+	}
+	if !hmac.Equal([]byte(sign(body, key)), []byte(hexSum)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// setSignature sets IntegrityHeader and KeyIDHeader, if key id is not empty
+func setSignature(header http.Header, body []byte, key keyring.Key) {
+	header.Set(IntegrityHeader, sign(body, key.Secret))
+	if len(key.ID) > 0 {
+		header.Set(KeyIDHeader, key.ID)
+	} else {
+		header.Del(KeyIDHeader)
+	}
+}
+
+// SignRequest sets IntegrityHeader and KeyIDHeader of the request, which must
+// already have its final body, i.e. compressed and encrypted
+func SignRequest(req *http.Request, key keyring.Key) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	setSignature(req.Header, body, key)
+	return nil
+}
+
+// VerifyResponse checks IntegrityHeader of the response against its body as
+// received, i.e. before decompression, using the key from keys with id in
+// KeyIDHeader; the request must have been sent with explicit
+// "Accept-Encoding: gzip", otherwise http.Transport decompresses the body
+// transparently
+func VerifyResponse(res *http.Response, body []byte, keys *keyring.Keyring) error {
+	key, err := keys.Lookup(res.Header.Get(KeyIDHeader))
+	if err != nil {
+		return err
+	}
+	return verify(body, key.Secret, res.Header.Get(IntegrityHeader))
+}
 
 type signedWriter struct {
 	http.ResponseWriter
 	bodyBuffer bytes.Buffer
-	signer     hash.Hash
+	key        keyring.Key // the key of the request or the primary one
+	statusCode int         // delayed until the signature header is set, 0 if not written
+	stream     *bool       // event streams are not buffered and not signed
 }
 
-func newSignedWriter(w http.ResponseWriter, key string) *signedWriter {
+func newSignedWriter(w http.ResponseWriter, key keyring.Key) *signedWriter {
 	return &signedWriter{
 		ResponseWriter: w,
 		bodyBuffer:     *bytes.NewBuffer(nil),
-		signer:         hmac.New(sha256.New, []byte(key)),
+		key:            key,
+	}
+}
+
+func (w *signedWriter) isStream() bool {
+	if w.stream == nil {
+		stream := strings.HasPrefix(w.ResponseWriter.Header().Get("Content-Type"),
+			"text/event-stream")
+		w.stream = &stream
+	}
+	return *w.stream
+}
+
+// WriteHeader delays the status, otherwise the headers are sent before the
+// signature is calculated
+func (w *signedWriter) WriteHeader(statusCode int) {
+	if w.isStream() {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
 	}
 }
 
 func (w *signedWriter) Write(p []byte) (int, error) {
-	if _, err := w.signer.Write(p); err != nil {
-		return 0, fmt.Errorf("signer: %w", err)
+	if w.isStream() {
+		return w.ResponseWriter.Write(p)
 	}
 	// delay actual writing until hash will be calculated
 	return w.bodyBuffer.Write(p)
 }
 
+// Unwrap allows http.ResponseController to flush event streams
+func (w *signedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *signedWriter) Sign() error {
-	sign := w.signer.Sum(nil)
-	hexSum := hex.EncodeToString(sign[:])
-	w.ResponseWriter.Header().Set("HashSHA256", hexSum)
+	if w.isStream() {
+		return nil
+	}
+	setSignature(w.ResponseWriter.Header(), w.bodyBuffer.Bytes(), w.key)
+	if w.statusCode != 0 {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+	}
 	// do actual writing
 	if _, err := w.ResponseWriter.Write(w.bodyBuffer.Bytes()); err != nil {
 		return fmt.Errorf("signer: %w", err)
@@ -46,36 +154,62 @@ func (w *signedWriter) Sign() error {
 	return nil
 }
 
-// SignVerifier verifies header "HashSHA256" using provided key
+// SignVerifier verifies header "HashSHA256" using the key with id from
+// header "KeyID" and signs the response with the same key
 type SignVerifier struct {
-	key string
+	keys        *keyring.Keyring
+	publicPaths map[string]bool // paths available by unsigned GET and HEAD
 }
 
-func NewSignVerifier(key string) *SignVerifier {
-	return &SignVerifier{
-		key: key,
+// NewSignVerifier returns SignVerifier, which accepts unsigned GET and HEAD
+// requests to publicPaths only, e.g. the main page for browsers and /metrics
+// for scrapers
+func NewSignVerifier(keys *keyring.Keyring, publicPaths ...string) *SignVerifier {
+	result := &SignVerifier{
+		keys:        keys,
+		publicPaths: make(map[string]bool, len(publicPaths)),
+	}
+	for _, path := range publicPaths {
+		result.publicPaths[path] = true
 	}
+	return result
 }
 
 func (c *SignVerifier) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !c.verifyRequest(w, r) {
+		// keys may appear or disappear on reload
+		primary, ok := c.keys.Primary()
+		if !ok || c.isPublic(r) {
+			next.ServeHTTP(w, r)
 			return
 		}
 
-		sw := newSignedWriter(w, c.key)
+		// rejections are signed too, so that the client can trust them
+		sw := newSignedWriter(w, primary)
 
-		next.ServeHTTP(sw, r)
+		if key, ok := c.verifyRequest(sw, r); ok {
+			sw.key = key
+			next.ServeHTTP(sw, r)
+		}
 
 		c.signResponse(sw)
 	})
 }
 
-func (c *SignVerifier) verifyRequest(w http.ResponseWriter, r *http.Request) bool {
-	expectedHexSum := r.Header.Values(integrityKey)
-	if len(expectedHexSum) == 0 {
-		return true
-	}
+// isPublic reports whether the request is unsigned GET or HEAD to a public
+// path; its response is not signed, since browsers and scrapers don't verify
+// it, so it's not buffered and the main page is streamed
+func (c *SignVerifier) isPublic(r *http.Request) bool {
+	return len(r.Header.Get(IntegrityHeader)) == 0 &&
+		(r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		c.publicPaths[r.URL.Path]
+}
+
+// verifyRequest returns the key of the request, if it is valid; responses to
+// signed requests are buffered until the signature is calculated
+func (c *SignVerifier) verifyRequest(w *signedWriter, r *http.Request,
+) (keyring.Key, bool) {
+	expectedHexSum := r.Header.Get(IntegrityHeader)
 
 	body, err := io.ReadAll(r.Body)
 	r.Body.Close()
@@ -86,19 +220,20 @@ func (c *SignVerifier) verifyRequest(w http.ResponseWriter, r *http.Request) boo
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return false
+		return keyring.Key{}, false
 	}
 
-	h := hmac.New(sha256.New, []byte(c.key))
-	h.Write(body)
-	sign := h.Sum(nil)
-	actualHexSum := hex.EncodeToString(sign[:])
-	if actualHexSum != expectedHexSum[0] {
-		http.Error(w, "invalid signature", http.StatusBadRequest)
-		return false
+	key, err := c.keys.Lookup(r.Header.Get(KeyIDHeader))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return keyring.Key{}, false
+	}
+	if err := verify(body, key.Secret, expectedHexSum); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return keyring.Key{}, false
 	}
 
-	return true
+	return key, true
 }
 
 func (c *SignVerifier) signResponse(sw *signedWriter) {
@@ -107,12 +242,21 @@ func (c *SignVerifier) signResponse(sw *signedWriter) {
 	}
 }
 
-func Integrity(key string) func(next http.Handler) http.Handler {
+// Integrity verifies requests and signs responses with a single key, see
+// IntegrityKeyring
+func Integrity(key string, publicPaths ...string) func(next http.Handler) http.Handler {
 	if len(key) == 0 {
 		return func(next http.Handler) http.Handler {
 			return next
 		}
 	}
-	v := NewSignVerifier(key)
-	return v.Handler
+	return IntegrityKeyring(keyring.New(keyring.Key{Secret: key}), publicPaths...)
+}
+
+// IntegrityKeyring verifies requests signed with any of the keys and signs
+// responses with the key of the request; unsigned GET and HEAD requests are
+// accepted to publicPaths only
+func IntegrityKeyring(keys *keyring.Keyring, publicPaths ...string,
+) func(next http.Handler) http.Handler {
+	return NewSignVerifier(keys, publicPaths...).Handler
 }
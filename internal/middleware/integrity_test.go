@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, body string) []byte {
+	buffer := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(buffer)
+	_, err := w.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buffer.Bytes()
+}
+
+func TestIntegrity(t *testing.T) {
+	const key = "secret"
+	tests := []struct {
+		name   string
+		method string
+		body   []byte
+		sign   string // key to sign request, not signed if empty
+		code   int
+	}{
+		{name: "signed post", method: http.MethodPost, body: gzipped(t, `[]`), sign: key, code: http.StatusCreated},
+		{name: "unsigned post", method: http.MethodPost, body: gzipped(t, `[]`), code: http.StatusBadRequest},
+		{name: "post signed with wrong key", method: http.MethodPost, body: gzipped(t, `[]`), sign: "wrong", code: http.StatusBadRequest},
+		{name: "unsigned delete", method: http.MethodDelete, code: http.StatusBadRequest},
+		{name: "signed delete", method: http.MethodDelete, sign: key, code: http.StatusCreated},
+		{name: "unsigned get of not public path", method: http.MethodGet, code: http.StatusBadRequest},
+		{name: "signed get", method: http.MethodGet, sign: key, code: http.StatusCreated},
+		{name: "get signed with wrong key", method: http.MethodGet, sign: "wrong", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// handler sets status explicitly and responds with compressible body
+			handler := Integrity(key)(Encoding(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"status":"ok"}`))
+				})))
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			req, err := http.NewRequest(tt.method, ts.URL, bytes.NewReader(tt.body))
+			require.NoError(t, err)
+			if len(tt.body) > 0 {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			req.Header.Set("Accept-Encoding", "gzip")
+			if len(tt.sign) > 0 {
+				require.NoError(t, SignRequest(req, keyring.Key{Secret: tt.sign}))
+			}
+
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			// проверяем параметры ответа
+			assert.Equal(t, tt.code, res.StatusCode)
+			// every response is signed over the received, possibly compressed, bytes
+			assert.NoError(t, VerifyResponse(res, body, keyring.New(keyring.Key{Secret: key})))
+			assert.ErrorIs(t, VerifyResponse(res, body, keyring.New(keyring.Key{Secret: "wrong"})),
+				ErrInvalidSignature)
+			if tt.code == http.StatusCreated {
+				assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestVerifyResponse_MissingSignature(t *testing.T) {
+	res := &http.Response{Header: http.Header{}}
+	assert.ErrorIs(t, VerifyResponse(res, []byte(`{}`), keyring.New(keyring.Key{Secret: "secret"})),
+		ErrMissingSignature)
+}
+
+func TestIntegrityKeyring(t *testing.T) {
+	current := keyring.Key{ID: "2", Secret: "new"}
+	previous := keyring.Key{ID: "1", Secret: "old"}
+	legacy := keyring.Key{Secret: "legacy"}
+	keys := keyring.New(current, previous, legacy)
+
+	tests := []struct {
+		name     string
+		method   string
+		sign     *keyring.Key // not signed if nil
+		code     int
+		response keyring.Key // key of the response signature
+	}{
+		{name: "signed with primary key", method: http.MethodPost, sign: &current, code: http.StatusOK, response: current},
+		{name: "signed with previous key", method: http.MethodPost, sign: &previous, code: http.StatusOK, response: previous},
+		{name: "signed with key without id", method: http.MethodPost, sign: &legacy, code: http.StatusOK, response: legacy},
+		{name: "unknown key id", method: http.MethodPost, sign: &keyring.Key{ID: "3", Secret: "new"}, code: http.StatusBadRequest, response: current},
+		{name: "secret of other key", method: http.MethodPost, sign: &keyring.Key{ID: "1", Secret: "new"}, code: http.StatusBadRequest, response: current},
+		{name: "unsigned get", method: http.MethodGet, code: http.StatusBadRequest, response: current},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := IntegrityKeyring(keys)(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"status":"ok"}`))
+				}))
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			req, err := http.NewRequest(tt.method, ts.URL, bytes.NewReader([]byte(`[]`)))
+			require.NoError(t, err)
+			if tt.sign != nil {
+				require.NoError(t, SignRequest(req, *tt.sign))
+			}
+
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			// проверяем параметры ответа
+			assert.Equal(t, tt.code, res.StatusCode)
+			assert.Equal(t, tt.response.ID, res.Header.Get(KeyIDHeader))
+			assert.NoError(t, VerifyResponse(res, body, keyring.New(tt.response)))
+			assert.NoError(t, VerifyResponse(res, body, keys))
+		})
+	}
+}
+
+func TestIntegrity_PublicPaths(t *testing.T) {
+	const key = "secret"
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		sign   bool
+		code   int
+		signed bool // response is signed
+	}{
+		{name: "unsigned get of public path", method: http.MethodGet, path: "/metrics", code: http.StatusOK},
+		{name: "unsigned head of public path", method: http.MethodHead, path: "/", code: http.StatusOK},
+		{name: "signed get of public path", method: http.MethodGet, path: "/metrics", sign: true, code: http.StatusOK, signed: true},
+		{name: "unsigned get of data path", method: http.MethodGet, path: "/values", code: http.StatusBadRequest, signed: true},
+		{name: "unsigned get of public path prefix", method: http.MethodGet, path: "/metrics/x", code: http.StatusBadRequest, signed: true},
+		{name: "unsigned post to public path", method: http.MethodPost, path: "/", code: http.StatusBadRequest, signed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Integrity(key, "/", "/metrics")(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`{"status":"ok"}`))
+				}))
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			require.NoError(t, err)
+			if tt.sign {
+				require.NoError(t, SignRequest(req, keyring.Key{Secret: key}))
+			}
+
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			// проверяем параметры ответа
+			assert.Equal(t, tt.code, res.StatusCode)
+			if tt.signed {
+				assert.NoError(t, VerifyResponse(res, body, keyring.New(keyring.Key{Secret: key})))
+			} else {
+				assert.Empty(t, res.Header.Get(IntegrityHeader))
+			}
+		})
+	}
+}
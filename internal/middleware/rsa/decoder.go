@@ -3,26 +3,77 @@ package rsamiddleware
 import (
 	"bytes"
 	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 )
 
-type decoder struct {
+// errUnexpectedPlaintext возвращается, если тело в устаревшем формате
+// расшифровано, но не похоже на тело запроса: PKCS #1 v1.5 не проверяет
+// целостность, и расшифровка чужим ключом может пройти проверку дополнения
+var errUnexpectedPlaintext = errors.New("unexpected plaintext")
+
+type privateKey struct {
+	id   string
 	priv *rsa.PrivateKey
 }
 
-// Decoder возвращает middleware, расшифровывающее тело запроса,
-// если Content-Type — application/octet-stream.
-func Decoder(privKeyPath string) (func(http.Handler) http.Handler, error) {
-	priv, err := loadPrivateKey(privKeyPath)
-	if err != nil {
+// Decrypter расшифровывает тело запроса, если Content-Type —
+// application/octet-stream. Тело в формате конверта (задан заголовок
+// EnvelopeHeader) расшифровывается через openEnvelope ключом с
+// идентификатором из конверта, иначе — целиком через RSA PKCS #1 v1.5
+// (устаревший режим). Конверты версии 1 и устаревший формат не содержат
+// идентификатора ключа, поэтому расшифровываются перебором ключей; в
+// устаревшем режиме ключ подходит, только если расшифрованное тело начинается
+// с заголовка gzip (при Content-Encoding: gzip) или является JSON.
+//
+// Закрытые ключи перечитываются из файлов методом Reload, что позволяет
+// сменить ключ без перезапуска: сначала на сервер добавляется новый ключ,
+// затем агенты переходят на новый открытый ключ, затем старый ключ удаляется.
+type Decrypter struct {
+	mu    sync.RWMutex
+	paths []string
+	keys  []privateKey
+}
+
+// NewDecrypter загружает закрытые ключи из файлов privKeyPaths
+func NewDecrypter(privKeyPaths ...string) (*Decrypter, error) {
+	if len(privKeyPaths) == 0 {
+		return nil, errors.New("no private keys")
+	}
+	d := &Decrypter{paths: privKeyPaths}
+	if err := d.Reload(); err != nil {
 		return nil, err
 	}
-	d := &decoder{priv: priv}
-	return d.decryptBodyMiddleware, nil
+	return d, nil
 }
 
-func (d *decoder) decryptBodyMiddleware(next http.Handler) http.Handler {
+// Reload перечитывает файлы закрытых ключей; при ошибке прежние ключи
+// сохраняются
+func (d *Decrypter) Reload() error {
+	keys := make([]privateKey, 0, len(d.paths))
+	for _, path := range d.paths {
+		priv, err := loadPrivateKey(path)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		id, err := KeyID(&priv.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		keys = append(keys, privateKey{id: id, priv: priv})
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keys = keys
+	return nil
+}
+
+func (d *Decrypter) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		if req.Header.Get("Content-Type") != "application/octet-stream" {
 			next.ServeHTTP(w, req)
@@ -36,7 +87,8 @@ func (d *decoder) decryptBodyMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		decrypted, err := rsa.DecryptPKCS1v15(nil, d.priv, encrypted)
+		gzipped := strings.Contains(req.Header.Get("Content-Encoding"), "gzip")
+		decrypted, err := d.decrypt(len(req.Header.Get(EnvelopeHeader)) > 0, gzipped, encrypted)
 		if err != nil {
 			http.Error(w, "decryption failed", http.StatusBadRequest)
 			return
@@ -45,7 +97,69 @@ func (d *decoder) decryptBodyMiddleware(next http.Handler) http.Handler {
 		req.Body = io.NopCloser(bytes.NewReader(decrypted))
 		req.ContentLength = int64(len(decrypted))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Del(EnvelopeHeader)
 
 		next.ServeHTTP(w, req)
 	})
 }
+
+func (d *Decrypter) decrypt(envelope, gzipped bool, encrypted []byte) ([]byte, error) {
+	d.mu.RLock()
+	keys := d.keys
+	d.mu.RUnlock()
+
+	open := func(priv *rsa.PrivateKey) ([]byte, error) {
+		decrypted, err := rsa.DecryptPKCS1v15(nil, priv, encrypted)
+		if err != nil {
+			return nil, err
+		}
+		if !validPlaintext(gzipped, decrypted) {
+			return nil, errUnexpectedPlaintext
+		}
+		return decrypted, nil
+	}
+	if envelope {
+		_, keyID, _, _, err := parseEnvelope(encrypted)
+		if err != nil {
+			return nil, err
+		}
+		if len(keyID) > 0 {
+			for _, key := range keys {
+				if key.id == keyID {
+					return openEnvelope(key.priv, encrypted)
+				}
+			}
+			return nil, fmt.Errorf("%w: %q", errUnknownKeyID, keyID)
+		}
+		open = func(priv *rsa.PrivateKey) ([]byte, error) {
+			return openEnvelope(priv, encrypted)
+		}
+	}
+
+	var err error
+	for _, key := range keys {
+		var decrypted []byte
+		if decrypted, err = open(key.priv); err == nil {
+			return decrypted, nil
+		}
+	}
+	return nil, err
+}
+
+// validPlaintext проверяет тело, расшифрованное в устаревшем режиме
+func validPlaintext(gzipped bool, plain []byte) bool {
+	if gzipped {
+		return len(plain) >= 2 && plain[0] == 0x1f && plain[1] == 0x8b
+	}
+	return json.Valid(plain)
+}
+
+// Decoder возвращает middleware, расшифровывающее тело запроса ключом из
+// файла privKeyPath, см. Decrypter
+func Decoder(privKeyPath string) (func(http.Handler) http.Handler, error) {
+	d, err := NewDecrypter(privKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return d.Handler, nil
+}
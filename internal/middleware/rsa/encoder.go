@@ -2,27 +2,46 @@ package rsamiddleware
 
 import (
 	"bytes"
-	"crypto/rand"
 	"crypto/rsa"
 	"io"
 	"net/http"
+	"strconv"
+	"sync"
 )
 
-type encoder struct {
-	pub *rsa.PublicKey
+// Encrypter шифрует тело запроса гибридной схемой RSA-OAEP + AES-GCM (см.
+// sealEnvelope), что снимает ограничение RSA на размер сообщения. Открытый
+// ключ перечитывается из файла методом Reload.
+type Encrypter struct {
+	mu   sync.RWMutex
+	path string
+	pub  *rsa.PublicKey
 }
 
-// Encoder возвращает функцию, шифрующую тело запроса с использованием RSA.
-func Encoder(pubKeyPath string) (func(*http.Request) error, error) {
-	pub, err := loadPublicKey(pubKeyPath)
-	if err != nil {
+// NewEncrypter загружает открытый ключ из файла pubKeyPath
+func NewEncrypter(pubKeyPath string) (*Encrypter, error) {
+	e := &Encrypter{path: pubKeyPath}
+	if err := e.Reload(); err != nil {
 		return nil, err
 	}
-	e := &encoder{pub: pub}
-	return e.encryptBody, nil
+	return e, nil
+}
+
+// Reload перечитывает файл открытого ключа; при ошибке прежний ключ
+// сохраняется
+func (e *Encrypter) Reload() error {
+	pub, err := loadPublicKey(e.path)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pub = pub
+	return nil
 }
 
-func (e *encoder) encryptBody(req *http.Request) error {
+// Encrypt заменяет тело запроса конвертом
+func (e *Encrypter) Encrypt(req *http.Request) error {
 	if req.Body == nil {
 		return nil
 	}
@@ -33,7 +52,10 @@ func (e *encoder) encryptBody(req *http.Request) error {
 		return err
 	}
 
-	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, e.pub, plain)
+	e.mu.RLock()
+	pub := e.pub
+	e.mu.RUnlock()
+	encrypted, err := sealEnvelope(pub, plain)
 	if err != nil {
 		return err
 	}
@@ -41,5 +63,16 @@ func (e *encoder) encryptBody(req *http.Request) error {
 	req.Body = io.NopCloser(bytes.NewReader(encrypted))
 	req.ContentLength = int64(len(encrypted))
 	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(EnvelopeHeader, strconv.Itoa(int(envelopeVersion2)))
 	return nil
 }
+
+// Encoder возвращает функцию, шифрующую тело запроса ключом из файла
+// pubKeyPath, см. Encrypter
+func Encoder(pubKeyPath string) (func(*http.Request) error, error) {
+	e, err := NewEncrypter(pubKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return e.Encrypt, nil
+}
@@ -0,0 +1,176 @@
+package rsamiddleware
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Конверт (envelope) позволяет шифровать сообщения произвольной длины:
+// тело шифруется AES-256-GCM случайным сессионным ключом, а сам сессионный
+// ключ шифруется открытым ключом RSA-OAEP (SHA-256).
+//
+// Формат конверта версии 2:
+//
+//	version (1 байт) | len(keyID) (1 байт) | keyID |
+//	len(wrappedKey) (2 байта, big endian) | wrappedKey |
+//	nonce (12 байт) | ciphertext с тегом GCM
+//
+// где keyID — идентификатор открытого ключа (см. KeyID), по которому
+// получатель с несколькими закрытыми ключами выбирает нужный. Конверт
+// версии 1 не содержит len(keyID) и keyID и расшифровывается перебором
+// ключей.
+//
+// Заголовок конверта (всё до nonce) используется в качестве
+// дополнительных аутентифицируемых данных GCM.
+
+// EnvelopeHeader — HTTP-заголовок с версией конверта; при его отсутствии
+// тело считается зашифрованным целиком через RSA PKCS #1 v1.5 (устаревший
+// режим, поддерживается только для расшифровки на время миграции агентов)
+const EnvelopeHeader = "X-Encryption-Envelope"
+
+const (
+	envelopeVersion1 byte = 1
+	envelopeVersion2 byte = 2
+	sessionKeySize        = 32 // AES-256
+	keyIDSize             = 8  // байт отпечатка ключа в KeyID
+)
+
+var (
+	errEnvelopeTooShort           = errors.New("envelope too short")
+	errUnsupportedEnvelopeVersion = errors.New("unsupported envelope version")
+	errUnknownKeyID               = errors.New("unknown key id")
+)
+
+// KeyID возвращает идентификатор ключа — начало SHA-256 открытого ключа в
+// формате PKIX в шестнадцатеричном виде; идентификатор одинаков для
+// открытого ключа агента и закрытого ключа сервера
+func KeyID(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:keyIDSize]), nil
+}
+
+// sealEnvelope шифрует plain в конверт текущей версии
+func sealEnvelope(pub *rsa.PublicKey, plain []byte) ([]byte, error) {
+	keyID, err := KeyID(pub)
+	if err != nil {
+		return nil, fmt.Errorf("key id: %w", err)
+	}
+
+	sessionKey := make([]byte, sessionKeySize)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return nil, fmt.Errorf("session key: %w", err)
+	}
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, sessionKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrap session key: %w", err)
+	}
+
+	gcm, err := newGCM(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+
+	header := make([]byte, 0, 4+len(keyID)+len(wrappedKey))
+	header = append(header, envelopeVersion2, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrappedKey)))
+	header = append(header, wrappedKey...)
+
+	result := make([]byte, 0,
+		len(header)+len(nonce)+len(plain)+gcm.Overhead())
+	result = append(result, header...)
+	result = append(result, nonce...)
+	return gcm.Seal(result, nonce, plain, header), nil
+}
+
+// parseEnvelope разбирает конверт на аутентифицируемый заголовок,
+// идентификатор ключа (пустой для версии 1), зашифрованный сессионный ключ и
+// остаток с nonce и ciphertext
+func parseEnvelope(envelope []byte) (header []byte, keyID string,
+	wrappedKey []byte, rest []byte, err error,
+) {
+	if len(envelope) < 1 {
+		return nil, "", nil, nil, errEnvelopeTooShort
+	}
+	offset := 1
+	switch envelope[0] {
+	case envelopeVersion1:
+	case envelopeVersion2:
+		if len(envelope) < offset+1 {
+			return nil, "", nil, nil, errEnvelopeTooShort
+		}
+		keyIDEnd := offset + 1 + int(envelope[offset])
+		if len(envelope) < keyIDEnd {
+			return nil, "", nil, nil, errEnvelopeTooShort
+		}
+		keyID = string(envelope[offset+1 : keyIDEnd])
+		offset = keyIDEnd
+	default:
+		return nil, "", nil, nil, fmt.Errorf("%w: %v",
+			errUnsupportedEnvelopeVersion, envelope[0])
+	}
+	if len(envelope) < offset+2 {
+		return nil, "", nil, nil, errEnvelopeTooShort
+	}
+	wrappedKeyEnd := offset + 2 + int(binary.BigEndian.Uint16(envelope[offset:]))
+	if len(envelope) < wrappedKeyEnd {
+		return nil, "", nil, nil, errEnvelopeTooShort
+	}
+	return envelope[:wrappedKeyEnd], keyID, envelope[offset+2 : wrappedKeyEnd],
+		envelope[wrappedKeyEnd:], nil
+}
+
+// openEnvelope расшифровывает конверт, созданный sealEnvelope
+func openEnvelope(priv *rsa.PrivateKey, envelope []byte) ([]byte, error) {
+	header, _, wrappedKey, rest, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, priv, wrappedKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap session key: %w", err)
+	}
+
+	gcm, err := newGCM(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize() {
+		return nil, errEnvelopeTooShort
+	}
+	nonce, ciphertext := rest[:gcm.NonceSize()], rest[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("gcm: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,277 @@
+package rsamiddleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeys generates rsa key pair and stores it in temporary directory
+func writeKeys(t *testing.T) (privKeyPath string, pubKeyPath string, priv *rsa.PrivateKey) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	privKeyPath = filepath.Join(dir, "private.pem")
+	privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(privKeyPath, pem.EncodeToMemory(
+		&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}), 0o600))
+
+	pubKeyPath = filepath.Join(dir, "public.pem")
+	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pubKeyPath, pem.EncodeToMemory(
+		&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0o600))
+
+	return privKeyPath, pubKeyPath, priv
+}
+
+// echoServer returns server, which decrypts request and responds with its body
+func echoServer(t *testing.T, privKeyPath string) *httptest.Server {
+	decoder, err := Decoder(privKeyPath)
+	require.NoError(t, err)
+	ts := httptest.NewServer(decoder(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(w, r.Body)
+		})))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	privKeyPath, pubKeyPath, _ := writeKeys(t)
+	ts := echoServer(t, privKeyPath)
+	encoder, err := Encoder(pubKeyPath)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty body", size: 0},
+		{name: "small body", size: 100},
+		{name: "body larger than rsa key", size: 64 * 1024},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := make([]byte, tt.size)
+			_, err := rand.Read(plain)
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader(plain))
+			require.NoError(t, err)
+			require.NoError(t, encoder(req))
+			assert.Equal(t, "application/octet-stream", req.Header.Get("Content-Type"))
+			assert.Equal(t, "2", req.Header.Get(EnvelopeHeader))
+
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, plain, body)
+		})
+	}
+}
+
+func TestDecoderLegacy(t *testing.T) {
+	privKeyPath, _, priv := writeKeys(t)
+	ts := echoServer(t, privKeyPath)
+
+	plain := []byte(`[{"id":"foo","type":"gauge","value":1.23}]`)
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, plain)
+	require.NoError(t, err)
+
+	res, err := ts.Client().Post(ts.URL, "application/octet-stream",
+		bytes.NewReader(encrypted))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, plain, body)
+}
+
+func TestDecrypter_LegacyPlaintext(t *testing.T) {
+	otherPrivKeyPath, _, other := writeKeys(t)
+	privKeyPath, _, priv := writeKeys(t)
+
+	decrypter, err := NewDecrypter(otherPrivKeyPath, privKeyPath)
+	require.NoError(t, err)
+	ts := httptest.NewServer(decrypter.Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(w, r.Body)
+		})))
+	defer ts.Close()
+
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err = gzipWriter.Write([]byte(`[{"id":"foo","type":"gauge","value":1.23}]`))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	tests := []struct {
+		name     string
+		pub      *rsa.PublicKey
+		plain    []byte
+		encoding string
+		status   int
+	}{
+		{
+			name:     "gzip by second key",
+			pub:      &priv.PublicKey,
+			plain:    compressed.Bytes(),
+			encoding: "gzip",
+			status:   http.StatusOK,
+		},
+		{
+			name:   "json by second key",
+			pub:    &priv.PublicKey,
+			plain:  []byte(`{"id":"foo","type":"counter","delta":1}`),
+			status: http.StatusOK,
+		},
+		{
+			// the same result as a decryption by a wrong key, which passed
+			// the padding check
+			name:     "not gzip",
+			pub:      &other.PublicKey,
+			plain:    []byte("garbage"),
+			encoding: "gzip",
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:   "not json",
+			pub:    &other.PublicKey,
+			plain:  []byte("garbage"),
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, tt.pub, tt.plain)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader(encrypted))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/octet-stream")
+			if len(tt.encoding) > 0 {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			// проверяем параметры ответа
+			assert.Equal(t, tt.status, res.StatusCode)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.plain, body)
+			}
+		})
+	}
+}
+
+func TestDecoderTamperedEnvelope(t *testing.T) {
+	_, _, priv := writeKeys(t)
+	envelope, err := sealEnvelope(&priv.PublicKey, []byte("some data"))
+	require.NoError(t, err)
+
+	tampered := bytes.Clone(envelope)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = openEnvelope(priv, tampered)
+	assert.Error(t, err)
+
+	unsupported := bytes.Clone(envelope)
+	unsupported[0] = 42
+	_, err = openEnvelope(priv, unsupported)
+	assert.ErrorIs(t, err, errUnsupportedEnvelopeVersion)
+
+	_, err = openEnvelope(priv, envelope[:2])
+	assert.ErrorIs(t, err, errEnvelopeTooShort)
+}
+
+func TestDecrypter_Rotation(t *testing.T) {
+	oldPrivKeyPath, oldPubKeyPath, _ := writeKeys(t)
+	newPrivKeyPath, newPubKeyPath, _ := writeKeys(t)
+	otherPrivKeyPath, otherPubKeyPath, _ := writeKeys(t)
+
+	decrypter, err := NewDecrypter(oldPrivKeyPath, newPrivKeyPath)
+	require.NoError(t, err)
+	ts := httptest.NewServer(decrypter.Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(w, r.Body)
+		})))
+	defer ts.Close()
+
+	// agent's public key file is replaced and reloaded
+	pubKeyPath := filepath.Join(t.TempDir(), "public.pem")
+	copyFile := func(src string, dst string) {
+		data, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, data, 0o600))
+	}
+	copyFile(oldPubKeyPath, pubKeyPath)
+	encrypter, err := NewEncrypter(pubKeyPath)
+	require.NoError(t, err)
+
+	post := func() int {
+		req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader([]byte("data")))
+		require.NoError(t, err)
+		require.NoError(t, encrypter.Encrypt(req))
+		res, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		if res.StatusCode == http.StatusOK {
+			assert.Equal(t, "data", string(body))
+		}
+		return res.StatusCode
+	}
+
+	// проверяем параметры ответа
+	assert.Equal(t, http.StatusOK, post())
+	copyFile(newPubKeyPath, pubKeyPath)
+	require.NoError(t, encrypter.Reload())
+	assert.Equal(t, http.StatusOK, post())
+	copyFile(otherPubKeyPath, pubKeyPath)
+	require.NoError(t, encrypter.Reload())
+	assert.Equal(t, http.StatusBadRequest, post())
+
+	// broken file keeps previous key
+	require.NoError(t, os.WriteFile(pubKeyPath, []byte("broken"), 0o600))
+	assert.Error(t, encrypter.Reload())
+	assert.Equal(t, http.StatusBadRequest, post())
+
+	// server accepts new key after reload
+	decrypter.paths = append(decrypter.paths, otherPrivKeyPath)
+	require.NoError(t, decrypter.Reload())
+	assert.Equal(t, http.StatusOK, post())
+}
+
+func TestKeyID(t *testing.T) {
+	_, pubKeyPath, priv := writeKeys(t)
+	pub, err := loadPublicKey(pubKeyPath)
+	require.NoError(t, err)
+
+	pubID, err := KeyID(pub)
+	require.NoError(t, err)
+	privID, err := KeyID(&priv.PublicKey)
+	require.NoError(t, err)
+	assert.Equal(t, pubID, privID)
+	assert.Len(t, pubID, 2*keyIDSize)
+}
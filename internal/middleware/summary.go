@@ -23,6 +23,11 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseStatusCode = statusCode
 }
 
+// Unwrap allows http.ResponseController to reach the original writer
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func Summary(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
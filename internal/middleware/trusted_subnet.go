@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// RealIPHeader contains agent address, which should belong to trusted subnet
+const RealIPHeader = "X-Real-IP"
+
+// TrustedSubnet returns middleware, which rejects requests with
+// http.StatusForbidden if the address from X-Real-IP header is missing or
+// doesn't belong to the subnet given in CIDR notation; all requests are
+// accepted if cidr is empty
+func TrustedSubnet(cidr string) (func(http.Handler) http.Handler, error) {
+	if len(cidr) == 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}, nil
+	}
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("trusted subnet: %w", err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(r.Header.Get(RealIPHeader))
+			if ip == nil || !subnet.Contains(ip) {
+				http.Error(w, "untrusted client address", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedSubnet(t *testing.T) {
+	tests := []struct {
+		name   string
+		cidr   string
+		realIP string
+		code   int
+	}{
+		{name: "no subnet, no header", cidr: "", realIP: "", code: http.StatusOK},
+		{name: "inside subnet", cidr: "192.168.1.0/24", realIP: "192.168.1.15", code: http.StatusOK},
+		{name: "outside subnet", cidr: "192.168.1.0/24", realIP: "192.168.2.15", code: http.StatusForbidden},
+		{name: "missing header", cidr: "192.168.1.0/24", realIP: "", code: http.StatusForbidden},
+		{name: "invalid header", cidr: "192.168.1.0/24", realIP: "foo", code: http.StatusForbidden},
+		{name: "ipv6 inside subnet", cidr: "fd00::/8", realIP: "fd00::1", code: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trustedSubnet, err := TrustedSubnet(tt.cidr)
+			require.NoError(t, err)
+			handler := trustedSubnet(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", http.NoBody)
+			if len(tt.realIP) > 0 {
+				req.Header.Set(RealIPHeader, tt.realIP)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.code, rec.Code)
+		})
+	}
+
+	_, err := TrustedSubnet("192.168.1.0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Заголовки, которыми агент сообщает серверу о себе в каждом пакете метрик
+const (
+	AgentIDHeader       = "X-Agent-ID"       // идентификатор агента, создается при первом запуске
+	AgentHostnameHeader = "X-Agent-Hostname" // имя хоста, на котором запущен агент
+)
+
+// Метки, которыми агент помечает каждую отправляемую метрику
+const (
+	AgentIDLabel       = "agent_id"
+	AgentHostnameLabel = "hostname"
+)
+
+// Agent описывает агента в ответе на `GET /agents`
+type Agent struct {
+	ID       string    `json:"id"`        // идентификатор агента
+	Hostname string    `json:"hostname"`  // имя хоста агента
+	LastSeen time.Time `json:"last_seen"` // время последнего отчета агента
+	Metrics  []Metric  `json:"metrics"`   // метрики, отправленные агентом, без значений
+}
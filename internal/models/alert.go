@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// AlertRule описывает правило в файле правил алертов (JSON или YAML)
+type AlertRule struct {
+	Name string `json:"name" yaml:"name"`                   // уникальное имя правила
+	Expr string `json:"expr" yaml:"expr"`                   // условие, например `gauge HeapAlloc > 500MB for 2m`
+	For  string `json:"for,omitempty" yaml:"for,omitempty"` // необязательная длительность, если не задана в expr, например 1m
+}
+
+// Alert описывает состояние правила в ответе на `GET /alerts` и в уведомлении,
+// отправляемом на webhook
+type Alert struct {
+	Name        string     `json:"name"`                   // имя правила
+	Expr        string     `json:"expr"`                   // условие правила
+	State       string     `json:"state"`                  // inactive, pending, firing или resolved
+	Value       *float64   `json:"value,omitempty"`        // последнее вычисленное значение, если известно
+	ActiveSince *time.Time `json:"active_since,omitempty"` // с какого момента выполняется условие
+	FiredAt     *time.Time `json:"fired_at,omitempty"`     // время последнего срабатывания
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`  // время последнего разрешения
+}
+
+// AlertNotification — тело запроса, отправляемого на webhook при срабатывании
+// или разрешении алертов
+type AlertNotification struct {
+	Alerts []Alert `json:"alerts"`
+}
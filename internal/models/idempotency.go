@@ -0,0 +1,9 @@
+package models
+
+// Заголовки идемпотентной отправки пакета метрик в `POST /updates/`
+const (
+	// ключ пакета, одинаковый для всех повторных отправок пакета
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// "true" в ответе на повторную отправку уже примененного пакета
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
@@ -4,12 +4,18 @@ package models
 // сервером в JSON-формате, а именно:
 //   - в качестве запроса в `POST /update`
 //   - в качестве запроса и ответа в `POST /value`, причем в запросе
-//     `POST /value` заполняются поля ID и MType.
+//     `POST /value` заполняются поля ID, MType и, при необходимости, Labels.
 //
-// Примечание: в ответе от сервера в Delta передается аккумулированное значение
+// Примечание: в ответе от сервера в Delta и полях гистограммы передается
+// аккумулированное значение
 type Metric struct {
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	ID      string            `json:"id"`                // имя метрики
+	MType   string            `json:"type"`              // параметр, принимающий значение gauge, counter или histogram
+	Labels  map[string]string `json:"labels,omitempty"`  // необязательные метки, например host или service; входят в идентификатор метрики
+	Delta   *int64            `json:"delta,omitempty"`   // значение метрики в случае передачи counter
+	Value   *float64          `json:"value,omitempty"`   // значение метрики в случае передачи gauge
+	Buckets []float64         `json:"buckets,omitempty"` // верхние границы корзин в случае передачи histogram
+	Counts  []uint64          `json:"counts,omitempty"`  // количество наблюдений в каждой корзине и в +Inf в случае передачи histogram
+	Count   *uint64           `json:"count,omitempty"`   // общее количество наблюдений в случае передачи histogram
+	Sum     *float64          `json:"sum,omitempty"`     // сумма наблюдений в случае передачи histogram
 }
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// Sample описывает точку истории метрики в ответе на `GET /history/...`
+type Sample struct {
+	Time  time.Time `json:"time"`  // начало интервала
+	Value float64   `json:"value"` // среднее значение gauge или последнее значение counter за интервал
+}
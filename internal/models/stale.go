@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// StaleMetric описывает метрику в ответе на `GET /stale`: метрику с текущим
+// значением и время её последнего обновления
+type StaleMetric struct {
+	Metric
+	LastUpdate *time.Time `json:"last_update,omitempty"` // отсутствует, если метрика не обновлялась с запуска сервера
+}
@@ -0,0 +1,55 @@
+// Package notifier delivers alert notifications
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/handlers/adapters"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// Webhook posts models.AlertNotification as JSON to the configured url
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Notify sends alerts, which became firing or resolved; any response status
+// other than 2xx is an error
+func (w *Webhook) Notify(ctx context.Context, alerts []entities.Alert) error {
+	body, err := json.Marshal(models.AlertNotification{
+		Alerts: adapters.ConvertEntityAlerts(alerts),
+	})
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook: unexpected status %v", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	var received models.AlertNotification
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	alerts := []entities.Alert{{
+		Rule:     entities.AlertRule{Name: "heap", Expr: "gauge HeapAlloc > 1"},
+		State:    entities.AlertFiring,
+		Value:    2,
+		HasValue: true,
+	}}
+	webhook := NewWebhook(ts.URL)
+	require.NoError(t, webhook.Notify(context.Background(), alerts))
+	require.Len(t, received.Alerts, 1)
+	assert.Equal(t, "heap", received.Alerts[0].Name)
+	assert.Equal(t, "firing", received.Alerts[0].State)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, webhook.Notify(context.Background(), alerts))
+}
@@ -0,0 +1,92 @@
+package filestorage
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// AgentRecord contains agent info and keys of metrics reported by the agent
+type AgentRecord struct {
+	Hostname  string                          `json:"hostname"`
+	LastSeen  time.Time                       `json:"last_seen"`
+	Gauge     map[entities.MetricKey]struct{} `json:"gauge"`
+	Counter   map[entities.MetricKey]struct{} `json:"counter"`
+	Histogram map[entities.MetricKey]struct{} `json:"histogram"`
+}
+
+func newAgentRecord() *AgentRecord {
+	return &AgentRecord{
+		Gauge:     make(map[entities.MetricKey]struct{}),
+		Counter:   make(map[entities.MetricKey]struct{}),
+		Histogram: make(map[entities.MetricKey]struct{}),
+	}
+}
+
+// update sets agent info and adds metrics to the ones reported by the agent
+func (r *AgentRecord) update(agent entities.Agent, metrics []entities.Metric) {
+	// record loaded from file may miss some maps
+	r.Gauge = nonNil(r.Gauge)
+	r.Counter = nonNil(r.Counter)
+	r.Histogram = nonNil(r.Histogram)
+	r.Hostname = agent.Hostname
+	r.LastSeen = agent.LastSeen
+	for _, metric := range metrics {
+		switch metric.Type {
+		case entities.MetricTypeGauge:
+			r.Gauge[metric.Key()] = struct{}{}
+		case entities.MetricTypeCounter:
+			r.Counter[metric.Key()] = struct{}{}
+		case entities.MetricTypeHistogram:
+			r.Histogram[metric.Key()] = struct{}{}
+		}
+	}
+}
+
+// forget removes deleted metric from the ones reported by the agent
+func (r *AgentRecord) forget(metricType entities.MetricType, key entities.MetricKey) {
+	switch metricType {
+	case entities.MetricTypeGauge:
+		delete(r.Gauge, key)
+	case entities.MetricTypeCounter:
+		delete(r.Counter, key)
+	case entities.MetricTypeHistogram:
+		delete(r.Histogram, key)
+	}
+}
+
+func nonNil(m map[entities.MetricKey]struct{}) map[entities.MetricKey]struct{} {
+	if m == nil {
+		return make(map[entities.MetricKey]struct{})
+	}
+	return m
+}
+
+// entity returns agent with metrics sorted by type, name and labels
+func (r *AgentRecord) entity(id string) entities.Agent {
+	result := entities.Agent{
+		ID:       id,
+		Hostname: r.Hostname,
+		LastSeen: r.LastSeen,
+		Metrics:  make([]entities.Metric, 0, len(r.Gauge)+len(r.Counter)+len(r.Histogram)),
+	}
+	for _, group := range []struct {
+		type_ entities.MetricType
+		keys  map[entities.MetricKey]struct{}
+	}{
+		{entities.MetricTypeGauge, r.Gauge},
+		{entities.MetricTypeCounter, r.Counter},
+		{entities.MetricTypeHistogram, r.Histogram},
+	} {
+		for _, key := range slices.SortedFunc(maps.Keys(group.keys), entities.MetricKey.Compare) {
+			result.Metrics = append(result.Metrics, entities.Metric{
+				Type:   group.type_,
+				Name:   key.Name,
+				Labels: key.LabelSet(),
+			})
+		}
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package filestorage
+
+import (
+	"context"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// DeleteMetric deletes metric and returns its last value
+func (s *FileStorage) DeleteMetric(ctx context.Context, metric entities.Metric,
+) (*entities.Metric, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result, exists := s.deleteMetric(metric)
+	if !exists {
+		return nil, entities.NewMetricNameNotFoundError(metric.Name)
+	}
+	s.storeMetricsOnChangeIfRequired()
+	return result, nil
+}
+
+// DeleteMetrics deletes metrics and returns last values of deleted ones;
+// unknown metrics are skipped
+func (s *FileStorage) DeleteMetrics(ctx context.Context, metrics []entities.Metric,
+) ([]entities.Metric, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]entities.Metric, 0)
+	for _, metric := range metrics {
+		if deleted, exists := s.deleteMetric(metric); exists {
+			result = append(result, *deleted)
+		}
+	}
+	if len(result) > 0 {
+		s.storeMetricsOnChangeIfRequired()
+	}
+	return result, nil
+}
+
+// ResetCounter sets counter value to zero
+func (s *FileStorage) ResetCounter(ctx context.Context, metric entities.Metric,
+) (*entities.Metric, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := metric.Key()
+	if _, exists := s.CounterMap[key]; !exists {
+		return nil, entities.NewMetricNameNotFoundError(metric.Name)
+	}
+	s.CounterMap[key] = 0
+	s.storeMetricsOnChangeIfRequired()
+
+	result := entities.Metric{
+		Type:   entities.MetricTypeCounter,
+		Name:   metric.Name,
+		Labels: metric.Labels,
+	}
+	return &result, nil
+}
+
+// deleteMetric should be called under lock
+func (s *FileStorage) deleteMetric(metric entities.Metric) (*entities.Metric, bool) {
+	key := metric.Key()
+	result := entities.Metric{
+		Type:   metric.Type,
+		Name:   metric.Name,
+		Labels: metric.Labels,
+	}
+	switch metric.Type {
+	case entities.MetricTypeGauge:
+		value, exists := s.GaugeMap[key]
+		if !exists {
+			return nil, false
+		}
+		delete(s.GaugeMap, key)
+		delete(s.gaugeUpdated(), key)
+		result.Value = value
+	case entities.MetricTypeCounter:
+		delta, exists := s.CounterMap[key]
+		if !exists {
+			return nil, false
+		}
+		delete(s.CounterMap, key)
+		delete(s.counterUpdated(), key)
+		result.Delta = delta
+	case entities.MetricTypeHistogram:
+		histogram, exists := s.HistogramMap[key]
+		if !exists {
+			return nil, false
+		}
+		delete(s.HistogramMap, key)
+		delete(s.histogramUpdated(), key)
+		result.Histogram = histogram
+	default:
+		return nil, false
+	}
+	for _, record := range s.AgentMap {
+		record.forget(metric.Type, key)
+	}
+	return &result, true
+}
@@ -0,0 +1,18 @@
+package memstorage
+
+import "github.com/PiskarevSA/go-advanced/internal/entities"
+
+// mergeHistogram adds observations of the metric to the stored histogram
+func mergeHistogram(histogramMap map[entities.MetricKey]entities.Histogram,
+	metric entities.Metric,
+) (entities.Histogram, error) {
+	stored, exists := histogramMap[metric.Key()]
+	if !exists {
+		return metric.Histogram.Clone(), nil
+	}
+	merged, err := stored.Merge(metric.Histogram)
+	if err != nil {
+		return entities.Histogram{}, entities.NewMetricValueIsNotValidError(err)
+	}
+	return merged, nil
+}
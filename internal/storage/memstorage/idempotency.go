@@ -0,0 +1,65 @@
+package memstorage
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// IdempotencyRecord is the result of the applied batch, returned again when
+// the batch is replayed
+type IdempotencyRecord struct {
+	Fingerprint string            `json:"fingerprint"`
+	Result      []entities.Metric `json:"result"`
+	Created     time.Time         `json:"created"`
+}
+
+// UpdateMetricsOnce updates metrics the same way as UpdateMetrics, unless the
+// batch with the same idempotency key is already applied; then the original
+// result is returned and replayed is true
+func (s *MemStorage) UpdateMetricsOnce(ctx context.Context, idempotency entities.Idempotency,
+	metrics []entities.Metric,
+) (result []entities.Metric, replayed bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if record, exists := s.idempotencyMap()[idempotency.Key]; exists {
+		if record.Fingerprint != idempotency.Fingerprint {
+			return nil, false, entities.ErrIdempotencyKeyReused
+		}
+		return record.Result, true, nil
+	}
+	result, err = s.updateMetrics(metrics)
+	if err != nil {
+		return nil, false, err
+	}
+	s.IdempotencyMap[idempotency.Key] = &IdempotencyRecord{
+		Fingerprint: idempotency.Fingerprint,
+		Result:      result,
+		Created:     time.Now(),
+	}
+	return result, false, nil
+}
+
+// ExpireIdempotencyKeys forgets batches applied before the time, so their
+// replays are applied again
+func (s *MemStorage) ExpireIdempotencyKeys(ctx context.Context, before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	maps.DeleteFunc(s.idempotencyMap(), func(_ string, record *IdempotencyRecord) bool {
+		return record.Created.Before(before)
+	})
+	return nil
+}
+
+// idempotencyMap returns IdempotencyMap, creating it if required, e.g. if it's
+// missing in the loaded file
+func (s *MemStorage) idempotencyMap() map[string]*IdempotencyRecord {
+	if s.IdempotencyMap == nil {
+		s.IdempotencyMap = make(map[string]*IdempotencyRecord)
+	}
+	return s.IdempotencyMap
+}
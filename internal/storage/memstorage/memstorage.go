@@ -3,7 +3,10 @@ package memstorage
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/entities"
 )
@@ -11,14 +14,28 @@ import (
 type MemStorage struct {
 	mutex sync.RWMutex
 
-	GaugeMap   map[entities.MetricName]entities.Gauge   `json:"gauge"`
-	CounterMap map[entities.MetricName]entities.Counter `json:"counter"`
+	GaugeMap     map[entities.MetricKey]entities.Gauge     `json:"gauge"`
+	CounterMap   map[entities.MetricKey]entities.Counter   `json:"counter"`
+	HistogramMap map[entities.MetricKey]entities.Histogram `json:"histogram"`
+	AgentMap     map[string]*AgentRecord                   `json:"agents"`
+	// time of the last update of every metric
+	GaugeUpdated     map[entities.MetricKey]time.Time `json:"gauge_updated"`
+	CounterUpdated   map[entities.MetricKey]time.Time `json:"counter_updated"`
+	HistogramUpdated map[entities.MetricKey]time.Time `json:"histogram_updated"`
+	// results of applied batches by idempotency key
+	IdempotencyMap map[string]*IdempotencyRecord `json:"idempotency"`
 }
 
 func New() *MemStorage {
 	return &MemStorage{
-		GaugeMap:   make(map[entities.MetricName]entities.Gauge),
-		CounterMap: make(map[entities.MetricName]entities.Counter),
+		GaugeMap:     make(map[entities.MetricKey]entities.Gauge),
+		CounterMap:   make(map[entities.MetricKey]entities.Counter),
+		HistogramMap: make(map[entities.MetricKey]entities.Histogram),
+		AgentMap:     make(map[string]*AgentRecord),
+
+		GaugeUpdated:     make(map[entities.MetricKey]time.Time),
+		CounterUpdated:   make(map[entities.MetricKey]time.Time),
+		HistogramUpdated: make(map[entities.MetricKey]time.Time),
 	}
 }
 
@@ -29,27 +46,41 @@ func (s *MemStorage) GetMetric(ctx context.Context, metric entities.Metric,
 
 	switch metric.Type {
 	case entities.MetricTypeGauge:
-		value, exists := s.GaugeMap[metric.Name]
+		value, exists := s.GaugeMap[metric.Key()]
 		if !exists {
 			return nil, entities.NewMetricNameNotFoundError(metric.Name)
 		}
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: value,
-			Delta: 0,
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  value,
+			Delta:  0,
 		}
 		return &result, nil
 	case entities.MetricTypeCounter:
-		delta, exists := s.CounterMap[metric.Name]
+		delta, exists := s.CounterMap[metric.Key()]
 		if !exists {
 			return nil, entities.NewMetricNameNotFoundError(metric.Name)
 		}
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: 0,
-			Delta: delta,
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  0,
+			Delta:  delta,
+		}
+		return &result, nil
+	case entities.MetricTypeHistogram:
+		histogram, exists := s.HistogramMap[metric.Key()]
+		if !exists {
+			return nil, entities.NewMetricNameNotFoundError(metric.Name)
+		}
+		result := entities.Metric{
+			Type:      metric.Type,
+			Name:      metric.Name,
+			Labels:    metric.Labels,
+			Histogram: histogram,
 		}
 		return &result, nil
 	}
@@ -64,23 +95,42 @@ func (s *MemStorage) UpdateMetric(ctx context.Context, metric entities.Metric,
 
 	switch metric.Type {
 	case entities.MetricTypeGauge:
-		s.GaugeMap[metric.Name] = metric.Value
+		s.GaugeMap[metric.Key()] = metric.Value
+		s.gaugeUpdated()[metric.Key()] = time.Now()
 
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: s.GaugeMap[metric.Name],
-			Delta: 0,
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  s.GaugeMap[metric.Key()],
+			Delta:  0,
 		}
 		return &result, nil
 	case entities.MetricTypeCounter:
-		s.CounterMap[metric.Name] += metric.Delta
+		s.CounterMap[metric.Key()] += metric.Delta
+		s.counterUpdated()[metric.Key()] = time.Now()
 
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: 0,
-			Delta: s.CounterMap[metric.Name],
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  0,
+			Delta:  s.CounterMap[metric.Key()],
+		}
+		return &result, nil
+	case entities.MetricTypeHistogram:
+		histogram, err := mergeHistogram(s.HistogramMap, metric)
+		if err != nil {
+			return nil, err
+		}
+		s.HistogramMap[metric.Key()] = histogram
+		s.histogramUpdated()[metric.Key()] = time.Now()
+
+		result := entities.Metric{
+			Type:      metric.Type,
+			Name:      metric.Name,
+			Labels:    metric.Labels,
+			Histogram: histogram,
 		}
 		return &result, nil
 	}
@@ -93,37 +143,74 @@ func (s *MemStorage) UpdateMetrics(ctx context.Context, metrics []entities.Metri
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	NewGaugeMap := make(map[entities.MetricName]entities.Gauge)
+	result, err := s.updateMetrics(metrics)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// updateMetrics applies all metrics or none of them; the caller must hold
+// the mutex
+func (s *MemStorage) updateMetrics(metrics []entities.Metric) ([]entities.Metric, error) {
+	NewGaugeMap := make(map[entities.MetricKey]entities.Gauge)
 	for k, v := range s.GaugeMap {
 		NewGaugeMap[k] = v
 	}
-	NewCounterMap := make(map[entities.MetricName]entities.Counter)
+	NewCounterMap := make(map[entities.MetricKey]entities.Counter)
 	for k, v := range s.CounterMap {
 		NewCounterMap[k] = v
 	}
+	NewHistogramMap := make(map[entities.MetricKey]entities.Histogram)
+	for k, v := range s.HistogramMap {
+		NewHistogramMap[k] = v
+	}
+	NewGaugeUpdated := maps.Clone(s.gaugeUpdated())
+	NewCounterUpdated := maps.Clone(s.counterUpdated())
+	NewHistogramUpdated := maps.Clone(s.histogramUpdated())
+	now := time.Now()
 
 	result := make([]entities.Metric, 0)
 
 	for i, metric := range metrics {
 		switch metric.Type {
 		case entities.MetricTypeGauge:
-			NewGaugeMap[metric.Name] = metric.Value
+			NewGaugeMap[metric.Key()] = metric.Value
+			NewGaugeUpdated[metric.Key()] = now
 
 			entityMetric := entities.Metric{
-				Type:  metric.Type,
-				Name:  metric.Name,
-				Value: NewGaugeMap[metric.Name],
-				Delta: 0,
+				Type:   metric.Type,
+				Name:   metric.Name,
+				Labels: metric.Labels,
+				Value:  NewGaugeMap[metric.Key()],
+				Delta:  0,
 			}
 			result = append(result, entityMetric)
 		case entities.MetricTypeCounter:
-			NewCounterMap[metric.Name] += metric.Delta
+			NewCounterMap[metric.Key()] += metric.Delta
+			NewCounterUpdated[metric.Key()] = now
 
 			entityMetric := entities.Metric{
-				Type:  metric.Type,
-				Name:  metric.Name,
-				Value: 0,
-				Delta: NewCounterMap[metric.Name],
+				Type:   metric.Type,
+				Name:   metric.Name,
+				Labels: metric.Labels,
+				Value:  0,
+				Delta:  NewCounterMap[metric.Key()],
+			}
+			result = append(result, entityMetric)
+		case entities.MetricTypeHistogram:
+			histogram, err := mergeHistogram(NewHistogramMap, metric)
+			if err != nil {
+				return nil, fmt.Errorf("metric[%v]: %w", i, err)
+			}
+			NewHistogramMap[metric.Key()] = histogram
+			NewHistogramUpdated[metric.Key()] = now
+
+			entityMetric := entities.Metric{
+				Type:      metric.Type,
+				Name:      metric.Name,
+				Labels:    metric.Labels,
+				Histogram: histogram,
 			}
 			result = append(result, entityMetric)
 		default:
@@ -135,12 +222,17 @@ func (s *MemStorage) UpdateMetrics(ctx context.Context, metrics []entities.Metri
 
 	s.GaugeMap = NewGaugeMap
 	s.CounterMap = NewCounterMap
+	s.HistogramMap = NewHistogramMap
+	s.GaugeUpdated = NewGaugeUpdated
+	s.CounterUpdated = NewCounterUpdated
+	s.HistogramUpdated = NewHistogramUpdated
 	return result, nil
 }
 
 func (s *MemStorage) GetMetricsByTypes(ctx context.Context,
-	gauge map[entities.MetricName]entities.Gauge,
-	counter map[entities.MetricName]entities.Counter,
+	gauge map[entities.MetricKey]entities.Gauge,
+	counter map[entities.MetricKey]entities.Counter,
+	histogram map[entities.MetricKey]entities.Histogram,
 ) error {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -152,9 +244,120 @@ func (s *MemStorage) GetMetricsByTypes(ctx context.Context,
 	for k, v := range s.CounterMap {
 		counter[k] = v
 	}
+
+	for k, v := range s.HistogramMap {
+		histogram[k] = v
+	}
+	return nil
+}
+
+// FindMetrics returns the page of metrics selected and sorted by filter
+func (s *MemStorage) FindMetrics(ctx context.Context, filter entities.MetricFilter,
+) ([]entities.Metric, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	metrics := make([]entities.Metric, 0,
+		len(s.GaugeMap)+len(s.CounterMap)+len(s.HistogramMap))
+	for k, v := range s.GaugeMap {
+		metrics = append(metrics, entities.Metric{
+			Type:      entities.MetricTypeGauge,
+			Name:      k.Name,
+			Labels:    k.LabelSet(),
+			Value:     v,
+			UpdatedAt: s.GaugeUpdated[k],
+		})
+	}
+	for k, v := range s.CounterMap {
+		metrics = append(metrics, entities.Metric{
+			Type:      entities.MetricTypeCounter,
+			Name:      k.Name,
+			Labels:    k.LabelSet(),
+			Delta:     v,
+			UpdatedAt: s.CounterUpdated[k],
+		})
+	}
+	for k, v := range s.HistogramMap {
+		metrics = append(metrics, entities.Metric{
+			Type:      entities.MetricTypeHistogram,
+			Name:      k.Name,
+			Labels:    k.LabelSet(),
+			Histogram: v,
+			UpdatedAt: s.HistogramUpdated[k],
+		})
+	}
+	return filter.Apply(metrics), nil
+}
+
+// GetSamples returns ErrHistoryNotSupported: only the last values are kept,
+// the history is stored by pgstorage
+func (s *MemStorage) GetSamples(ctx context.Context, metric entities.Metric,
+	from time.Time, to time.Time,
+) ([]entities.Sample, error) {
+	return nil, fmt.Errorf("%w by memory storage", entities.ErrHistoryNotSupported)
+}
+
+// CompactSamples does nothing, as there is no history to compact
+func (s *MemStorage) CompactSamples(ctx context.Context, now time.Time,
+	retention entities.SampleRetention,
+) error {
 	return nil
 }
 
+// gaugeUpdated returns GaugeUpdated, creating it if required, e.g. if it's
+// missing in the file written by the previous version
+func (s *MemStorage) gaugeUpdated() map[entities.MetricKey]time.Time {
+	if s.GaugeUpdated == nil {
+		s.GaugeUpdated = make(map[entities.MetricKey]time.Time)
+	}
+	return s.GaugeUpdated
+}
+
+// counterUpdated returns CounterUpdated, creating it if required
+func (s *MemStorage) counterUpdated() map[entities.MetricKey]time.Time {
+	if s.CounterUpdated == nil {
+		s.CounterUpdated = make(map[entities.MetricKey]time.Time)
+	}
+	return s.CounterUpdated
+}
+
+// histogramUpdated returns HistogramUpdated, creating it if required
+func (s *MemStorage) histogramUpdated() map[entities.MetricKey]time.Time {
+	if s.HistogramUpdated == nil {
+		s.HistogramUpdated = make(map[entities.MetricKey]time.Time)
+	}
+	return s.HistogramUpdated
+}
+
+func (s *MemStorage) UpdateAgent(ctx context.Context, agent entities.Agent,
+	metrics []entities.Metric,
+) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.AgentMap == nil {
+		s.AgentMap = make(map[string]*AgentRecord)
+	}
+	record, exists := s.AgentMap[agent.ID]
+	if !exists {
+		record = newAgentRecord()
+		s.AgentMap[agent.ID] = record
+	}
+	record.update(agent, metrics)
+	return nil
+}
+
+func (s *MemStorage) GetAgents(ctx context.Context) ([]entities.Agent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]entities.Agent, 0, len(s.AgentMap))
+	for _, id := range slices.Sorted(maps.Keys(s.AgentMap)) {
+		result = append(result, s.AgentMap[id].entity(id))
+	}
+	return result, nil
+}
+
 func (s *MemStorage) Ping(ctx context.Context) error { return nil }
 
 func (s *MemStorage) Close(ctx context.Context) error { return nil }
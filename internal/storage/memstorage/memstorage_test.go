@@ -3,20 +3,30 @@ package memstorage
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/entities"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func filledMemStorage() *MemStorage {
 	return &MemStorage{
-		GaugeMap: map[entities.MetricName]entities.Gauge{
-			"Gauge1": 1.11,
-			"Gauge2": 2.22,
+		GaugeMap: map[entities.MetricKey]entities.Gauge{
+			{Name: "Gauge1"}: 1.11,
+			{Name: "Gauge2"}: 2.22,
 		},
-		CounterMap: map[entities.MetricName]entities.Counter{
-			"Counter1": 111,
-			"Counter2": 222,
+		CounterMap: map[entities.MetricKey]entities.Counter{
+			{Name: "Counter1"}: 111,
+			{Name: "Counter2"}: 222,
+		},
+		HistogramMap: map[entities.MetricKey]entities.Histogram{
+			{Name: "Histogram1"}: {
+				Bounds: []float64{0.5, 1},
+				Counts: []uint64{1, 2, 3},
+				Count:  6,
+				Sum:    7.5,
+			},
 		},
 	}
 }
@@ -172,6 +182,26 @@ func TestMemStorage_UpdateMetric(t *testing.T) {
 				argError: nil,
 			},
 		},
+		{
+			name: "add counter with labels",
+			given: given{
+				argMetric: entities.Metric{
+					Type:   entities.MetricTypeCounter,
+					Name:   "Counter2",
+					Labels: entities.Labels{"host": "a"},
+					Delta:  5,
+				},
+			},
+			want: want{
+				argResponse: &entities.Metric{
+					Type:   entities.MetricTypeCounter,
+					Name:   "Counter2",
+					Labels: entities.Labels{"host": "a"},
+					Delta:  5,
+				},
+				argError: nil,
+			},
+		},
 		{
 			name: "increase counter",
 			given: given{
@@ -190,6 +220,54 @@ func TestMemStorage_UpdateMetric(t *testing.T) {
 				argError: nil,
 			},
 		},
+		{
+			name: "merge histogram",
+			given: given{
+				argMetric: entities.Metric{
+					Type: entities.MetricTypeHistogram,
+					Name: "Histogram1",
+					Histogram: entities.Histogram{
+						Bounds: []float64{0.5, 1},
+						Counts: []uint64{1, 0, 0},
+						Count:  1,
+						Sum:    0.25,
+					},
+				},
+			},
+			want: want{
+				argResponse: &entities.Metric{
+					Type: entities.MetricTypeHistogram,
+					Name: "Histogram1",
+					Histogram: entities.Histogram{
+						Bounds: []float64{0.5, 1},
+						Counts: []uint64{2, 2, 3},
+						Count:  7,
+						Sum:    7.75,
+					},
+				},
+				argError: nil,
+			},
+		},
+		{
+			name: "merge histogram with different bounds",
+			given: given{
+				argMetric: entities.Metric{
+					Type: entities.MetricTypeHistogram,
+					Name: "Histogram1",
+					Histogram: entities.Histogram{
+						Bounds: []float64{1},
+						Counts: []uint64{1, 0},
+						Count:  1,
+						Sum:    0.25,
+					},
+				},
+			},
+			want: want{
+				argResponse: nil,
+				argError: entities.NewMetricValueIsNotValidError(
+					entities.ErrHistogramBoundsMismatch),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -283,3 +361,165 @@ func TestMemStorage_UpdateMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestMemStorage_Agents(t *testing.T) {
+	s := filledMemStorage()
+	ctx := context.Background()
+	firstSeen := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	lastSeen := firstSeen.Add(time.Minute)
+
+	err := s.UpdateAgent(ctx, entities.Agent{ID: "a1", Hostname: "old", LastSeen: firstSeen},
+		[]entities.Metric{
+			{Type: entities.MetricTypeCounter, Name: "Counter1"},
+			{Type: entities.MetricTypeGauge, Name: "Gauge1"},
+		})
+	assert.NoError(t, err)
+	err = s.UpdateAgent(ctx, entities.Agent{ID: "a1", Hostname: "new", LastSeen: lastSeen},
+		[]entities.Metric{
+			{Type: entities.MetricTypeGauge, Name: "Gauge1", Labels: entities.Labels{"host": "a"}},
+			{Type: entities.MetricTypeGauge, Name: "Gauge1"},
+		})
+	assert.NoError(t, err)
+
+	agents, err := s.GetAgents(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []entities.Agent{
+		{
+			ID:       "a1",
+			Hostname: "new",
+			LastSeen: lastSeen,
+			Metrics: []entities.Metric{
+				{Type: entities.MetricTypeGauge, Name: "Gauge1"},
+				{Type: entities.MetricTypeGauge, Name: "Gauge1", Labels: entities.Labels{"host": "a"}},
+				{Type: entities.MetricTypeCounter, Name: "Counter1"},
+			},
+		},
+	}, agents)
+}
+
+func TestMemStorage_Samples(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	counter := entities.Metric{Type: entities.MetricTypeCounter, Name: "Counter1", Delta: 2}
+	_, err := s.UpdateMetric(ctx, counter)
+	require.NoError(t, err)
+
+	// only the last values are kept, the history is stored by pgstorage
+	now := time.Now()
+	_, err = s.GetSamples(ctx, counter, now.Add(-time.Hour), now)
+	assert.ErrorIs(t, err, entities.ErrHistoryNotSupported)
+	assert.NoError(t, s.CompactSamples(ctx, now, entities.SampleRetention{Raw: time.Hour}))
+}
+
+func TestMemStorage_DeleteAndReset(t *testing.T) {
+	s := filledMemStorage()
+	ctx := context.Background()
+	gauge := entities.Metric{Type: entities.MetricTypeGauge, Name: "Gauge1"}
+	counter := entities.Metric{Type: entities.MetricTypeCounter, Name: "Counter1"}
+
+	err := s.UpdateAgent(ctx, entities.Agent{ID: "a1"}, []entities.Metric{gauge, counter})
+	assert.NoError(t, err)
+
+	deleted, err := s.DeleteMetric(ctx, gauge)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.MetricTypeGauge, deleted.Type)
+	_, err = s.GetMetric(ctx, gauge)
+	assert.Error(t, err)
+	_, err = s.DeleteMetric(ctx, gauge)
+	var notFound *entities.MetricNameNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+
+	agents, err := s.GetAgents(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []entities.Metric{counter}, agents[0].Metrics)
+
+	reset, err := s.ResetCounter(ctx, counter)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.Counter(0), reset.Delta)
+	got, err := s.GetMetric(ctx, counter)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.Counter(0), got.Delta)
+	_, err = s.ResetCounter(ctx, entities.Metric{Name: "unknown"})
+	assert.ErrorAs(t, err, &notFound)
+
+	deletedMetrics, err := s.DeleteMetrics(ctx, []entities.Metric{gauge, counter})
+	assert.NoError(t, err)
+	assert.Equal(t, []entities.Metric{counter}, deletedMetrics)
+}
+
+func TestMemStorage_UpdatedAt(t *testing.T) {
+	s := filledMemStorage()
+	ctx := context.Background()
+	gauge := entities.Metric{Type: entities.MetricTypeGauge, Name: "Gauge1", Value: 1}
+	histogram := entities.Metric{Type: entities.MetricTypeHistogram, Name: "Histogram1",
+		Histogram: entities.Histogram{Bounds: []float64{0.5, 1}, Counts: []uint64{1, 0, 0},
+			Count: 1, Sum: 0.1}}
+
+	// update time of restored metrics is unknown
+	metrics, err := s.FindMetrics(ctx, entities.MetricFilter{})
+	require.NoError(t, err)
+	for _, metric := range metrics {
+		assert.Zero(t, metric.UpdatedAt)
+	}
+
+	before := time.Now()
+	_, err = s.UpdateMetric(ctx, gauge)
+	require.NoError(t, err)
+	_, err = s.UpdateMetrics(ctx, []entities.Metric{histogram})
+	require.NoError(t, err)
+	metrics, err = s.FindMetrics(ctx, entities.MetricFilter{
+		SortBy: entities.SortByName,
+		Types:  []entities.MetricType{entities.MetricTypeGauge, entities.MetricTypeHistogram},
+	})
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+	assert.False(t, metrics[0].UpdatedAt.Before(before)) // Gauge1
+	assert.Zero(t, metrics[1].UpdatedAt)                 // Gauge2
+	assert.False(t, metrics[2].UpdatedAt.Before(before)) // Histogram1
+
+	// update time is deleted with the metric
+	_, err = s.DeleteMetric(ctx, gauge)
+	require.NoError(t, err)
+	assert.NotContains(t, s.GaugeUpdated, gauge.Key())
+}
+
+func TestMemStorage_UpdateMetricsOnce(t *testing.T) {
+	s := New()
+	ctx := context.Background()
+	metrics := []entities.Metric{
+		{Type: entities.MetricTypeCounter, Name: "Counter1", Delta: 2},
+	}
+	idempotency, err := entities.NewIdempotency("k1", metrics)
+	require.NoError(t, err)
+
+	result, replayed, err := s.UpdateMetricsOnce(ctx, idempotency, metrics)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, entities.Counter(2), result[0].Delta)
+
+	// replay returns the original result even after later updates and
+	// doesn't count delta twice
+	_, err = s.UpdateMetric(ctx, entities.Metric{
+		Type: entities.MetricTypeCounter, Name: "Counter1", Delta: 3})
+	require.NoError(t, err)
+	result, replayed, err = s.UpdateMetricsOnce(ctx, idempotency, metrics)
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, entities.Counter(2), result[0].Delta)
+	assert.Equal(t, entities.Counter(5), s.CounterMap[metrics[0].Key()])
+
+	// the same key with another batch is rejected
+	other, err := entities.NewIdempotency("k1", []entities.Metric{
+		{Type: entities.MetricTypeCounter, Name: "Counter1", Delta: 3},
+	})
+	require.NoError(t, err)
+	_, _, err = s.UpdateMetricsOnce(ctx, other, metrics)
+	assert.ErrorIs(t, err, entities.ErrIdempotencyKeyReused)
+
+	// expired key is forgotten, so the batch is applied again
+	require.NoError(t, s.ExpireIdempotencyKeys(ctx, time.Now().Add(time.Second)))
+	result, replayed, err = s.UpdateMetricsOnce(ctx, idempotency, metrics)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, entities.Counter(7), result[0].Delta)
+}
@@ -0,0 +1,124 @@
+package pgstorage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// metric types are stored in agent_metric.type and sample.type columns by names
+var (
+	metricTypeNames = map[entities.MetricType]string{
+		entities.MetricTypeGauge:     "gauge",
+		entities.MetricTypeCounter:   "counter",
+		entities.MetricTypeHistogram: "histogram",
+	}
+	metricTypesByName = map[string]entities.MetricType{
+		"gauge":     entities.MetricTypeGauge,
+		"counter":   entities.MetricTypeCounter,
+		"histogram": entities.MetricTypeHistogram,
+	}
+)
+
+func (s *PgStorage) UpdateAgent(ctx context.Context, agent entities.Agent,
+	metrics []entities.Metric,
+) error {
+	doQueries := func(tx pgx.Tx) error {
+		query := `
+			insert into agent (id, hostname, last_seen)
+			values ($1, $2, $3)
+			on conflict(id)
+			do update set
+			  hostname = excluded.hostname,
+			  last_seen = excluded.last_seen`
+		if _, err := tx.Exec(ctx, query, agent.ID, agent.Hostname, agent.LastSeen); err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+
+		query = `
+			insert into agent_metric (agent_id, type, name, labels)
+			values ($1, $2, $3, $4)
+			on conflict do nothing`
+		batch := &pgx.Batch{}
+		for _, metric := range metrics {
+			typeName, ok := metricTypeNames[metric.Type]
+			if !ok {
+				continue
+			}
+			key := metric.Key()
+			batch.Queue(query, agent.ID, typeName, key.Name, key.Labels)
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+		return nil
+	}
+	return doTransactionWithRetries(ctx, s.pool, doQueries)
+}
+
+func (s *PgStorage) GetAgents(ctx context.Context) ([]entities.Agent, error) {
+	var result []entities.Agent
+	doQueries := func(tx pgx.Tx) error {
+		result = make([]entities.Agent, 0)
+		indexes := make(map[string]int) // agent id -> index in result
+
+		agentRows, err := tx.Query(ctx,
+			"select id, hostname, last_seen from agent order by id")
+		if err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+		agents, err := pgx.CollectRows(agentRows, func(row pgx.CollectableRow) (entities.Agent, error) {
+			var agent entities.Agent
+			err := row.Scan(&agent.ID, &agent.Hostname, &agent.LastSeen)
+			return agent, err
+		})
+		if err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+		for i, agent := range agents {
+			agent.Metrics = make([]entities.Metric, 0)
+			indexes[agent.ID] = i
+			result = append(result, agent)
+		}
+
+		query := `
+			select agent_id, type, name, labels from agent_metric
+			order by agent_id,
+			  case type when 'gauge' then 1 when 'counter' then 2 else 3 end,
+			  name, labels`
+		metricRows, err := tx.Query(ctx, query)
+		if err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+		defer metricRows.Close()
+		for metricRows.Next() {
+			var agentID, typeName string
+			var key entities.MetricKey
+			if err := metricRows.Scan(&agentID, &typeName, &key.Name, &key.Labels); err != nil {
+				return entities.NewInternalError("sql query error", err)
+			}
+			i, ok := indexes[agentID]
+			if !ok {
+				continue
+			}
+			metricType, ok := metricTypesByName[typeName]
+			if !ok {
+				return entities.NewInternalError(
+					fmt.Sprintf("unexpected metric type in agent_metric: %v", typeName), nil)
+			}
+			result[i].Metrics = append(result[i].Metrics, entities.Metric{
+				Type:   metricType,
+				Name:   key.Name,
+				Labels: key.LabelSet(),
+			})
+		}
+		if metricRows.Err() != nil {
+			return entities.NewInternalError("sql query error", metricRows.Err())
+		}
+		return nil
+	}
+	err := doTransactionWithRetries(ctx, s.pool, doQueries)
+	return result, err
+}
@@ -0,0 +1,96 @@
+package pgstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// resolutions of sample aggregates in seconds
+const (
+	minuteResolution = 60
+	hourResolution   = 3600
+)
+
+// CompactSamples rolls raw samples into 1 minute aggregates and 1 minute
+// aggregates into 1 hour aggregates, then deletes raw samples and 1 minute
+// aggregates older than retention; zero retention means keep forever.
+// Only complete intervals are aggregated, the last aggregated interval is
+// recomputed on the next call, so the call is idempotent.
+func (s *PgStorage) CompactSamples(ctx context.Context, now time.Time,
+	retention entities.SampleRetention,
+) error {
+	doQueries := func(tx pgx.Tx) error {
+		query := `
+			insert into sample_aggregate
+			  (resolution, ts, type, name, labels, min, max, avg, last, sum, count)
+			select
+			  $1::integer,
+			  to_timestamp(floor(extract(epoch from ts) / $1::integer) * $1::integer) as bucket,
+			  type, name, labels,
+			  min(value), max(value), avg(value),
+			  (array_agg(value order by ts desc))[1],
+			  sum(delta), count(*)
+			from sample
+			where ts >= coalesce(
+			    (select max(ts) from sample_aggregate where resolution = $1::integer),
+			    '-infinity')
+			  and ts < $2
+			group by bucket, type, name, labels
+			on conflict(resolution, type, name, labels, ts)
+			do update set
+			  min = excluded.min, max = excluded.max, avg = excluded.avg,
+			  last = excluded.last, sum = excluded.sum, count = excluded.count`
+		if _, err := tx.Exec(ctx, query, minuteResolution,
+			now.Truncate(time.Minute)); err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+
+		query = `
+			insert into sample_aggregate
+			  (resolution, ts, type, name, labels, min, max, avg, last, sum, count)
+			select
+			  $1::integer,
+			  to_timestamp(floor(extract(epoch from ts) / $1::integer) * $1::integer) as bucket,
+			  type, name, labels,
+			  min(min), max(max), sum(avg * count) / sum(count),
+			  (array_agg(last order by ts desc))[1],
+			  sum(sum), sum(count)
+			from sample_aggregate
+			where resolution = $2::integer
+			  and ts >= coalesce(
+			    (select max(ts) from sample_aggregate where resolution = $1::integer),
+			    '-infinity')
+			  and ts < $3
+			group by bucket, type, name, labels
+			on conflict(resolution, type, name, labels, ts)
+			do update set
+			  min = excluded.min, max = excluded.max, avg = excluded.avg,
+			  last = excluded.last, sum = excluded.sum, count = excluded.count`
+		if _, err := tx.Exec(ctx, query, hourResolution, minuteResolution,
+			now.Truncate(time.Hour)); err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+
+		// cutoffs are truncated to hours, so that the history is either raw or
+		// aggregated within every hour
+		if retention.Raw > 0 {
+			query = "delete from sample where ts < $1"
+			cutoff := now.Add(-retention.Raw).Truncate(time.Hour)
+			if _, err := tx.Exec(ctx, query, cutoff); err != nil {
+				return entities.NewInternalError("sql query error", err)
+			}
+		}
+		if retention.Minute > 0 {
+			query = "delete from sample_aggregate where resolution = $1 and ts < $2"
+			cutoff := now.Add(-retention.Minute).Truncate(time.Hour)
+			if _, err := tx.Exec(ctx, query, minuteResolution, cutoff); err != nil {
+				return entities.NewInternalError("sql query error", err)
+			}
+		}
+		return nil
+	}
+	return doTransactionWithRetries(ctx, s.pool, doQueries)
+}
@@ -0,0 +1,138 @@
+package pgstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// DeleteMetric deletes metric with its history and returns its last value
+func (s *PgStorage) DeleteMetric(ctx context.Context, metric entities.Metric,
+) (*entities.Metric, error) {
+	var result *entities.Metric
+
+	doQueries := func(tx pgx.Tx) error {
+		var err error
+		result, err = deleteMetric(ctx, tx, metric)
+		return err
+	}
+
+	err := doTransactionWithRetries(ctx, s.pool, doQueries)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, entities.NewMetricNameNotFoundError(metric.Name)
+	} else if err != nil {
+		return nil, entities.NewInternalError("sql query error", err)
+	}
+	return result, nil
+}
+
+// DeleteMetrics deletes metrics with their history and returns last values of
+// deleted ones; unknown metrics are skipped
+func (s *PgStorage) DeleteMetrics(ctx context.Context, metrics []entities.Metric,
+) ([]entities.Metric, error) {
+	var result []entities.Metric
+
+	doQueries := func(tx pgx.Tx) error {
+		result = make([]entities.Metric, 0)
+		for i, metric := range metrics {
+			deleted, err := deleteMetric(ctx, tx, metric)
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			} else if err != nil {
+				return entities.NewInternalError(
+					fmt.Sprintf("metric[%v]: sql query error", i), err)
+			}
+			result = append(result, *deleted)
+		}
+		return nil
+	}
+
+	err := doTransactionWithRetries(ctx, s.pool, doQueries)
+	return result, err
+}
+
+// ResetCounter sets counter value to zero
+func (s *PgStorage) ResetCounter(ctx context.Context, metric entities.Metric,
+) (*entities.Metric, error) {
+	metric.Type = entities.MetricTypeCounter
+	key := metric.Key()
+
+	doQueries := func(tx pgx.Tx) error {
+		query := `
+			select value from counter
+			where name = $1 and labels = $2
+			for update`
+		var value entities.Counter
+		if err := tx.QueryRow(ctx, query, key.Name, key.Labels).Scan(&value); err != nil {
+			return err
+		}
+
+		query = "update counter set value = 0 where name = $1 and labels = $2"
+		if _, err := tx.Exec(ctx, query, key.Name, key.Labels); err != nil {
+			return err
+		}
+		metric.Delta = -value
+		return insertSample(ctx, tx, metric, 0)
+	}
+
+	err := doTransactionWithRetries(ctx, s.pool, doQueries)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, entities.NewMetricNameNotFoundError(metric.Name)
+	} else if err != nil {
+		return nil, entities.NewInternalError("sql query error", err)
+	}
+
+	result := entities.Metric{
+		Type:   metric.Type,
+		Name:   metric.Name,
+		Labels: metric.Labels,
+	}
+	return &result, nil
+}
+
+// deleteMetric deletes metric, its history and agent references; returns
+// pgx.ErrNoRows if metric is unknown
+func deleteMetric(ctx context.Context, tx pgx.Tx, metric entities.Metric,
+) (*entities.Metric, error) {
+	key := metric.Key()
+	result := entities.Metric{
+		Type:   metric.Type,
+		Name:   metric.Name,
+		Labels: metric.Labels,
+	}
+	var err error
+	switch metric.Type {
+	case entities.MetricTypeGauge:
+		query := "delete from gauge where name = $1 and labels = $2 returning value"
+		err = tx.QueryRow(ctx, query, key.Name, key.Labels).Scan(&result.Value)
+	case entities.MetricTypeCounter:
+		query := "delete from counter where name = $1 and labels = $2 returning value"
+		err = tx.QueryRow(ctx, query, key.Name, key.Labels).Scan(&result.Delta)
+	case entities.MetricTypeHistogram:
+		query := `
+			delete from histogram where name = $1 and labels = $2
+			returning bounds, counts, count, sum`
+		result.Histogram, err = scanHistogram(tx.QueryRow(ctx, query, key.Name, key.Labels))
+	default:
+		return nil, entities.NewInternalError(
+			"unexpected internal metric type: "+metric.Type.String(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	typeName := metricTypeNames[metric.Type]
+	for _, query := range []string{
+		"delete from sample where type = $1 and name = $2 and labels = $3",
+		"delete from sample_aggregate where type = $1 and name = $2 and labels = $3",
+		"delete from agent_metric where type = $1 and name = $2 and labels = $3",
+	} {
+		if _, err := tx.Exec(ctx, query, typeName, key.Name, key.Labels); err != nil {
+			return nil, err
+		}
+	}
+	return &result, nil
+}
@@ -0,0 +1,130 @@
+package pgstorage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// name and labels are compared bytewise, as strings.Compare does, rather than
+// by the database default collation
+const (
+	nameColumn   = `name collate "C"`
+	labelsColumn = `labels collate "C"`
+)
+
+// sortColumns are columns of the metrics union for every sort key, in the same
+// order as entities.MetricFilter.Compare uses
+var sortColumns = map[entities.MetricSortKey][]string{
+	entities.SortByType:  {"type", nameColumn, labelsColumn},
+	entities.SortByName:  {nameColumn, labelsColumn, "type"},
+	entities.SortByValue: {"sort_value", "type", nameColumn, labelsColumn},
+}
+
+// FindMetrics returns the page of metrics selected and sorted by filter; types
+// and name prefix are filtered by postgres, name regexp is evaluated in Go, as
+// in other storages, since postgres regular expressions have different syntax
+func (s *PgStorage) FindMetrics(ctx context.Context, filter entities.MetricFilter,
+) ([]entities.Metric, error) {
+	direction := "asc"
+	if filter.Descending {
+		direction = "desc"
+	}
+	columns, ok := sortColumns[filter.SortBy]
+	if !ok {
+		return nil, entities.ErrInvalidMetricFilter
+	}
+	orderBy := make([]string, 0, len(columns))
+	for _, column := range columns {
+		orderBy = append(orderBy, column+" "+direction)
+	}
+	// type is the entities.MetricType value, so the order is the same as in
+	// other storages
+	query := fmt.Sprintf(`
+		select type, name, labels, gauge, delta, bounds, counts, count, sum,
+		  updated_at
+		from (
+		  select $1::integer as type, name, labels, value as sort_value,
+		    value as gauge, 0::bigint as delta,
+		    '{}'::double precision[] as bounds, '{}'::bigint[] as counts,
+		    0::bigint as count, 0::double precision as sum, updated_at
+		  from gauge
+		  union all
+		  select $2::integer, name, labels, value::double precision,
+		    0, value, '{}', '{}', 0, 0, updated_at
+		  from counter
+		  union all
+		  select $3::integer, name, labels, count::double precision,
+		    0, 0, bounds, counts, count, sum, updated_at
+		  from histogram
+		) m
+		where (cardinality($4::integer[]) = 0 or type = any($4::integer[]))
+		  and left(name, length($5)) = $5
+		order by %s
+		limit $6 offset $7`, strings.Join(orderBy, ", "))
+
+	types := make([]int32, 0, len(filter.Types))
+	for _, t := range filter.Types {
+		types = append(types, int32(t))
+	}
+	var limit *int // null means no limit
+	offset := filter.Offset
+	if filter.Limit > 0 {
+		limit = &filter.Limit
+	}
+	// the page is selected after matching the regexp
+	if filter.NameRegexp != nil {
+		limit = nil
+		offset = 0
+	}
+
+	var result []entities.Metric
+	doQueries := func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query,
+			int32(entities.MetricTypeGauge), int32(entities.MetricTypeCounter),
+			int32(entities.MetricTypeHistogram), types, filter.NamePrefix,
+			limit, offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		result = make([]entities.Metric, 0)
+		for rows.Next() {
+			var (
+				metricType int32
+				key        entities.MetricKey
+				metric     entities.Metric
+				counts     []int64
+				count      int64
+			)
+			if err := rows.Scan(&metricType, &key.Name, &key.Labels, &metric.Value,
+				&metric.Delta, &metric.Histogram.Bounds, &counts, &count,
+				&metric.Histogram.Sum, &metric.UpdatedAt); err != nil {
+				return err
+			}
+			metric.Type = entities.MetricType(metricType)
+			metric.Name = key.Name
+			metric.Labels = key.LabelSet()
+			if metric.Type == entities.MetricTypeHistogram {
+				metric.Histogram.Counts = countsFromInt64(counts)
+				metric.Histogram.Count = uint64(count)
+			} else {
+				metric.Histogram = entities.Histogram{}
+			}
+			result = append(result, metric)
+		}
+		return rows.Err()
+	}
+
+	if err := doTransactionWithRetries(ctx, s.pool, doQueries); err != nil {
+		return nil, entities.NewInternalError("sql query error", err)
+	}
+	if filter.NameRegexp != nil {
+		result = filter.Apply(result)
+	}
+	return result, nil
+}
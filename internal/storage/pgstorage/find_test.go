@@ -0,0 +1,90 @@
+package pgstorage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage connects to the database from TEST_DATABASE_DSN, the test is
+// skipped if it's not set
+func newTestStorage(t *testing.T) *PgStorage {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if len(dsn) == 0 {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	s, err := New(context.Background(), dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close(context.Background()) })
+	return s
+}
+
+func TestPgStorage_FindMetricsOrder(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	// names and labels, which are ordered differently by bytes and by
+	// linguistic collations
+	prefix := fmt.Sprintf("find%d", time.Now().UnixNano())
+	var metrics []entities.Metric
+	for i, suffix := range []string{"a", "B", "_c", "Z", "b"} {
+		name := entities.MetricName(prefix + suffix)
+		metrics = append(metrics,
+			entities.Metric{Type: entities.MetricTypeGauge, Name: name,
+				Value: entities.Gauge(i)},
+			entities.Metric{Type: entities.MetricTypeCounter, Name: name,
+				Labels: entities.Labels{"host": "a"}, Delta: entities.Counter(i)},
+			entities.Metric{Type: entities.MetricTypeCounter, Name: name,
+				Labels: entities.Labels{"host": "B"}, Delta: entities.Counter(i)},
+		)
+	}
+	_, err := s.UpdateMetrics(ctx, metrics)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = s.DeleteMetrics(context.Background(), metrics) })
+
+	keys := func(metrics []entities.Metric) []string {
+		result := make([]string, 0, len(metrics))
+		for _, metric := range metrics {
+			result = append(result, fmt.Sprintf("%v %v", metric.Type, metric.Key()))
+		}
+		return result
+	}
+
+	for _, sortBy := range []entities.MetricSortKey{
+		entities.SortByType, entities.SortByName, entities.SortByValue,
+	} {
+		for _, descending := range []bool{false, true} {
+			filter := entities.MetricFilter{
+				NamePrefix: prefix,
+				SortBy:     sortBy,
+				Descending: descending,
+			}
+			t.Run(fmt.Sprintf("sort %v descending %v", sortBy, descending), func(t *testing.T) {
+				found, err := s.FindMetrics(ctx, filter)
+				require.NoError(t, err)
+				require.Len(t, found, len(metrics))
+				want := slices.Clone(found)
+				slices.SortFunc(want, filter.Compare)
+				assert.Equal(t, keys(want), keys(found))
+
+				// pages are the same as in other storages
+				var pages []entities.Metric
+				filter.Limit = 4
+				for filter.Offset = 0; filter.Offset < len(metrics); filter.Offset += filter.Limit {
+					page, err := s.FindMetrics(ctx, filter)
+					require.NoError(t, err)
+					assert.Equal(t, keys(filter.Apply(found)), keys(page))
+					pages = append(pages, page...)
+				}
+				assert.Equal(t, keys(want), keys(pages))
+			})
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package pgstorage
+
+import (
+	"context"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// histogram counts are stored as bigint[], because postgres has no unsigned
+// types
+func countsToInt64(counts []uint64) []int64 {
+	result := make([]int64, len(counts))
+	for i, c := range counts {
+		result[i] = int64(c)
+	}
+	return result
+}
+
+func countsFromInt64(counts []int64) []uint64 {
+	result := make([]uint64, len(counts))
+	for i, c := range counts {
+		result[i] = uint64(c)
+	}
+	return result
+}
+
+// scanHistogram reads histogram from row with columns: bounds, counts, count, sum
+func scanHistogram(row pgx.Row) (entities.Histogram, error) {
+	var result entities.Histogram
+	var counts []int64
+	var count int64
+	if err := row.Scan(&result.Bounds, &counts, &count, &result.Sum); err != nil {
+		return entities.Histogram{}, err
+	}
+	result.Counts = countsFromInt64(counts)
+	result.Count = uint64(count)
+	return result, nil
+}
+
+// selectHistogram returns stored histogram or pgx.ErrNoRows
+func selectHistogram(ctx context.Context, tx pgx.Tx, key entities.MetricKey,
+) (entities.Histogram, error) {
+	query := `
+		select bounds, counts, count, sum from histogram
+		where name = $1 and labels = $2`
+	return scanHistogram(tx.QueryRow(ctx, query, key.Name, key.Labels))
+}
+
+// upsertHistogram adds observations of the metric to the stored histogram
+// and returns the result
+func upsertHistogram(ctx context.Context, tx pgx.Tx, metric entities.Metric,
+) (entities.Histogram, error) {
+	bounds := metric.Histogram.Bounds
+	if bounds == nil {
+		// bounds column is not null
+		bounds = []float64{}
+	}
+
+	// make sure the row exists, so it can be locked by concurrent transactions
+	insertQuery := `
+		insert into histogram (name, labels, bounds, counts, count, sum)
+		values ($1, $2, $3, $4, 0, 0)
+		on conflict(name, labels) do nothing`
+	key := metric.Key()
+	emptyCounts := make([]int64, len(metric.Histogram.Counts))
+	if _, err := tx.Exec(ctx, insertQuery, key.Name, key.Labels, bounds,
+		emptyCounts); err != nil {
+		return entities.Histogram{}, entities.NewInternalError("sql query error", err)
+	}
+
+	selectQuery := `
+		select bounds, counts, count, sum from histogram
+		where name = $1 and labels = $2
+		for update`
+	stored, err := scanHistogram(tx.QueryRow(ctx, selectQuery, key.Name, key.Labels))
+	if err != nil {
+		return entities.Histogram{}, entities.NewInternalError("sql query error", err)
+	}
+	merged, err := stored.Merge(metric.Histogram)
+	if err != nil {
+		return entities.Histogram{}, entities.NewMetricValueIsNotValidError(err)
+	}
+
+	updateQuery := `
+		update histogram set
+		  counts = $3,
+		  count = $4,
+		  sum = $5,
+		  updated_at = now()
+		where name = $1 and labels = $2
+		returning bounds, counts, count, sum`
+	result, err := scanHistogram(tx.QueryRow(ctx, updateQuery, key.Name, key.Labels,
+		countsToInt64(merged.Counts), int64(merged.Count), merged.Sum))
+	if err != nil {
+		return entities.Histogram{}, entities.NewInternalError("sql query error", err)
+	}
+	return result, nil
+}
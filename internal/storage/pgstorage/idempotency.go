@@ -0,0 +1,81 @@
+package pgstorage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// UpdateMetricsOnce updates metrics the same way as UpdateMetrics, unless the
+// batch with the same idempotency key is already applied; then the original
+// result is returned and replayed is true. The key is inserted in the same
+// transaction as metrics, so concurrent replays wait for the first one.
+func (s *PgStorage) UpdateMetricsOnce(ctx context.Context, idempotency entities.Idempotency,
+	metrics []entities.Metric,
+) (result []entities.Metric, replayed bool, err error) {
+	doQueries := func(tx pgx.Tx) error {
+		query := `
+			insert into idempotency_key (key, fingerprint)
+			values ($1, $2)
+			on conflict do nothing`
+		tag, err := tx.Exec(ctx, query, idempotency.Key, idempotency.Fingerprint)
+		if err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			// the batch is already applied
+			query = `select fingerprint, result from idempotency_key where key = $1`
+			var fingerprint string
+			var data []byte
+			row := tx.QueryRow(ctx, query, idempotency.Key)
+			if err := row.Scan(&fingerprint, &data); err != nil {
+				return entities.NewInternalError("sql query error", err)
+			}
+			if fingerprint != idempotency.Fingerprint {
+				return entities.ErrIdempotencyKeyReused
+			}
+			if err := json.Unmarshal(data, &result); err != nil {
+				return entities.NewInternalError("decode idempotent result", err)
+			}
+			replayed = true
+			return nil
+		}
+
+		result, err = updateMetrics(ctx, tx, metrics)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			return entities.NewInternalError("encode idempotent result", err)
+		}
+		query = `update idempotency_key set result = $2 where key = $1`
+		if _, err := tx.Exec(ctx, query, idempotency.Key, data); err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+		replayed = false
+		return nil
+	}
+	err = doTransactionWithRetries(ctx, s.pool, doQueries)
+	if err != nil {
+		return nil, false, err
+	}
+	return result, replayed, nil
+}
+
+// ExpireIdempotencyKeys forgets batches applied before the time, so their
+// replays are applied again
+func (s *PgStorage) ExpireIdempotencyKeys(ctx context.Context, before time.Time) error {
+	doQueries := func(tx pgx.Tx) error {
+		query := `delete from idempotency_key where created < $1`
+		if _, err := tx.Exec(ctx, query, before); err != nil {
+			return entities.NewInternalError("sql query error", err)
+		}
+		return nil
+	}
+	return doTransactionWithRetries(ctx, s.pool, doQueries)
+}
@@ -36,11 +36,11 @@ func (s *PgStorage) GetMetric(ctx context.Context, metric entities.Metric,
 ) (*entities.Metric, error) {
 	switch metric.Type {
 	case entities.MetricTypeGauge:
-		query := "select value from gauge where name = $1"
+		query := "select value from gauge where name = $1 and labels = $2"
 		var value entities.Gauge
 
 		doQueries := func(tx pgx.Tx) error {
-			row := tx.QueryRow(ctx, query, metric.Name)
+			row := tx.QueryRow(ctx, query, metric.Name, metric.Labels.String())
 			return row.Scan(&value)
 		}
 
@@ -52,18 +52,19 @@ func (s *PgStorage) GetMetric(ctx context.Context, metric entities.Metric,
 		}
 
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: value,
-			Delta: 0,
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  value,
+			Delta:  0,
 		}
 		return &result, nil
 	case entities.MetricTypeCounter:
-		query := "select value from counter where name = $1"
+		query := "select value from counter where name = $1 and labels = $2"
 		var value entities.Counter
 
 		doQueries := func(tx pgx.Tx) error {
-			row := tx.QueryRow(ctx, query, metric.Name)
+			row := tx.QueryRow(ctx, query, metric.Name, metric.Labels.String())
 			return row.Scan(&value)
 		}
 
@@ -75,10 +76,34 @@ func (s *PgStorage) GetMetric(ctx context.Context, metric entities.Metric,
 		}
 
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: 0,
-			Delta: value,
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  0,
+			Delta:  value,
+		}
+		return &result, nil
+	case entities.MetricTypeHistogram:
+		var histogram entities.Histogram
+
+		doQueries := func(tx pgx.Tx) error {
+			var err error
+			histogram, err = selectHistogram(ctx, tx, metric.Key())
+			return err
+		}
+
+		err := doTransactionWithRetries(ctx, s.pool, doQueries)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, entities.NewMetricNameNotFoundError(metric.Name)
+		} else if err != nil {
+			return nil, entities.NewInternalError("sql query error", err)
+		}
+
+		result := entities.Metric{
+			Type:      metric.Type,
+			Name:      metric.Name,
+			Labels:    metric.Labels,
+			Histogram: histogram,
 		}
 		return &result, nil
 	}
@@ -91,17 +116,22 @@ func (s *PgStorage) UpdateMetric(ctx context.Context, metric entities.Metric,
 	switch metric.Type {
 	case entities.MetricTypeGauge:
 		query := `
-			insert into gauge (name, value)
-			values ($1, $2)
-			on conflict(name)
+			insert into gauge (name, labels, value)
+			values ($1, $2, $3)
+			on conflict(name, labels)
 			do update set
-			  value = excluded.value
+			  value = excluded.value,
+			  updated_at = now()
 			returning value`
 		var value entities.Gauge
 
 		doQueries := func(tx pgx.Tx) error {
-			row := tx.QueryRow(ctx, query, metric.Name, metric.Value)
-			return row.Scan(&value)
+			row := tx.QueryRow(ctx, query, metric.Name, metric.Labels.String(),
+				metric.Value)
+			if err := row.Scan(&value); err != nil {
+				return err
+			}
+			return insertSample(ctx, tx, metric, float64(value))
 		}
 
 		err := doTransactionWithRetries(ctx, s.pool, doQueries)
@@ -110,25 +140,31 @@ func (s *PgStorage) UpdateMetric(ctx context.Context, metric entities.Metric,
 		}
 
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: value,
-			Delta: 0,
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  value,
+			Delta:  0,
 		}
 		return &result, nil
 	case entities.MetricTypeCounter:
 		query := `
-			insert into counter (name, value)
-			values ($1, $2)
-			on conflict(name)
+			insert into counter (name, labels, value)
+			values ($1, $2, $3)
+			on conflict(name, labels)
 			do update set
-			  value = counter.value + excluded.value
+			  value = counter.value + excluded.value,
+			  updated_at = now()
 			returning value`
 		var value entities.Counter
 
 		doQueries := func(tx pgx.Tx) error {
-			row := tx.QueryRow(ctx, query, metric.Name, metric.Delta)
-			return row.Scan(&value)
+			row := tx.QueryRow(ctx, query, metric.Name, metric.Labels.String(),
+				metric.Delta)
+			if err := row.Scan(&value); err != nil {
+				return err
+			}
+			return insertSample(ctx, tx, metric, float64(value))
 		}
 
 		err := doTransactionWithRetries(ctx, s.pool, doQueries)
@@ -137,10 +173,32 @@ func (s *PgStorage) UpdateMetric(ctx context.Context, metric entities.Metric,
 		}
 
 		result := entities.Metric{
-			Type:  metric.Type,
-			Name:  metric.Name,
-			Value: 0,
-			Delta: value,
+			Type:   metric.Type,
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  0,
+			Delta:  value,
+		}
+		return &result, nil
+	case entities.MetricTypeHistogram:
+		var histogram entities.Histogram
+
+		doQueries := func(tx pgx.Tx) error {
+			var err error
+			histogram, err = upsertHistogram(ctx, tx, metric)
+			return err
+		}
+
+		err := doTransactionWithRetries(ctx, s.pool, doQueries)
+		if err != nil {
+			return nil, err
+		}
+
+		result := entities.Metric{
+			Type:      metric.Type,
+			Name:      metric.Name,
+			Labels:    metric.Labels,
+			Histogram: histogram,
 		}
 		return &result, nil
 	}
@@ -151,80 +209,118 @@ func (s *PgStorage) UpdateMetric(ctx context.Context, metric entities.Metric,
 func (s *PgStorage) UpdateMetrics(ctx context.Context, metrics []entities.Metric,
 ) ([]entities.Metric, error) {
 	var result []entities.Metric
-	var err error
 	doQueries := func(tx pgx.Tx) error {
-		result = make([]entities.Metric, 0)
-		for i, metric := range metrics {
-			switch metric.Type {
-			case entities.MetricTypeGauge:
-				query := `
-				insert into gauge (name, value)
-				values ($1, $2)
-				on conflict(name)
-				do update set
-				  value = excluded.value
-				returning value`
-				var value entities.Gauge
-				row := tx.QueryRow(ctx, query, metric.Name, metric.Value)
-				err = row.Scan(&value)
-				if err != nil {
-					return entities.NewInternalError(
-						fmt.Sprintf("metric[%v]: sql query error", i), err)
-				}
+		var err error
+		result, err = updateMetrics(ctx, tx, metrics)
+		return err
+	}
+	err := doTransactionWithRetries(ctx, s.pool, doQueries)
+	return result, err
+}
 
-				updatedMetric := entities.Metric{
-					Type:  metric.Type,
-					Name:  metric.Name,
-					Value: value,
-					Delta: 0,
-				}
-				result = append(result, updatedMetric)
-
-			case entities.MetricTypeCounter:
-				query := `
-				insert into counter (name, value)
-				values ($1, $2)
-				on conflict(name)
-				do update set
-				value = counter.value + excluded.value
-				returning value`
-				var value entities.Counter
-				row := tx.QueryRow(ctx, query, metric.Name, metric.Delta)
-				err = row.Scan(&value)
-				if err != nil {
-					return entities.NewInternalError(
-						fmt.Sprintf("metric[%v]: sql query error", i), err)
-				}
+// updateMetrics applies metrics within the transaction
+func updateMetrics(ctx context.Context, tx pgx.Tx, metrics []entities.Metric,
+) ([]entities.Metric, error) {
+	result := make([]entities.Metric, 0)
+	var err error
+	for i, metric := range metrics {
+		switch metric.Type {
+		case entities.MetricTypeGauge:
+			query := `
+			insert into gauge (name, labels, value)
+			values ($1, $2, $3)
+			on conflict(name, labels)
+			do update set
+			  value = excluded.value,
+			  updated_at = now()
+			returning value`
+			var value entities.Gauge
+			row := tx.QueryRow(ctx, query, metric.Name, metric.Labels.String(),
+				metric.Value)
+			err = row.Scan(&value)
+			if err != nil {
+				return nil, entities.NewInternalError(
+					fmt.Sprintf("metric[%v]: sql query error", i), err)
+			}
+			err = insertSample(ctx, tx, metric, float64(value))
+			if err != nil {
+				return nil, entities.NewInternalError(
+					fmt.Sprintf("metric[%v]: sql query error", i), err)
+			}
 
-				updatedMetric := entities.Metric{
-					Type:  metric.Type,
-					Name:  metric.Name,
-					Value: 0,
-					Delta: value,
-				}
-				result = append(result, updatedMetric)
-			default:
-				return entities.NewInternalError(
-					fmt.Sprintf("metric[%v]: unexpected internal metric type: %v",
-						i, metric.Type.String()), nil)
+			updatedMetric := entities.Metric{
+				Type:   metric.Type,
+				Name:   metric.Name,
+				Labels: metric.Labels,
+				Value:  value,
+				Delta:  0,
+			}
+			result = append(result, updatedMetric)
+
+		case entities.MetricTypeCounter:
+			query := `
+			insert into counter (name, labels, value)
+			values ($1, $2, $3)
+			on conflict(name, labels)
+			do update set
+			value = counter.value + excluded.value,
+			updated_at = now()
+			returning value`
+			var value entities.Counter
+			row := tx.QueryRow(ctx, query, metric.Name, metric.Labels.String(),
+				metric.Delta)
+			err = row.Scan(&value)
+			if err != nil {
+				return nil, entities.NewInternalError(
+					fmt.Sprintf("metric[%v]: sql query error", i), err)
+			}
+			err = insertSample(ctx, tx, metric, float64(value))
+			if err != nil {
+				return nil, entities.NewInternalError(
+					fmt.Sprintf("metric[%v]: sql query error", i), err)
+			}
+
+			updatedMetric := entities.Metric{
+				Type:   metric.Type,
+				Name:   metric.Name,
+				Labels: metric.Labels,
+				Value:  0,
+				Delta:  value,
 			}
+			result = append(result, updatedMetric)
+		case entities.MetricTypeHistogram:
+			histogram, err := upsertHistogram(ctx, tx, metric)
+			if err != nil {
+				return nil, fmt.Errorf("metric[%v]: %w", i, err)
+			}
+
+			updatedMetric := entities.Metric{
+				Type:      metric.Type,
+				Name:      metric.Name,
+				Labels:    metric.Labels,
+				Histogram: histogram,
+			}
+			result = append(result, updatedMetric)
+		default:
+			return nil, entities.NewInternalError(
+				fmt.Sprintf("metric[%v]: unexpected internal metric type: %v",
+					i, metric.Type.String()), nil)
 		}
-		return nil
 	}
-	err = doTransactionWithRetries(ctx, s.pool, doQueries)
-	return result, err
+	return result, nil
 }
 
 func (s *PgStorage) GetMetricsByTypes(ctx context.Context,
-	gauge map[entities.MetricName]entities.Gauge,
-	counter map[entities.MetricName]entities.Counter,
+	gauge map[entities.MetricKey]entities.Gauge,
+	counter map[entities.MetricKey]entities.Counter,
+	histogram map[entities.MetricKey]entities.Histogram,
 ) error {
 	doQueries := func(tx pgx.Tx) error {
 		var err error
 
 		func() {
-			query := "select name, value from gauge"
-			var name entities.MetricName
+			query := "select name, labels, value from gauge"
+			var key entities.MetricKey
 			var gaugeValue entities.Gauge
 			var rows pgx.Rows
 			rows, err = tx.Query(ctx, query)
@@ -235,11 +331,11 @@ func (s *PgStorage) GetMetricsByTypes(ctx context.Context,
 			defer rows.Close()
 
 			for rows.Next() {
-				if err = rows.Scan(&name, &gaugeValue); err != nil {
+				if err = rows.Scan(&key.Name, &key.Labels, &gaugeValue); err != nil {
 					err = entities.NewInternalError("sql query error", err)
 					return
 				}
-				gauge[name] = gaugeValue
+				gauge[key] = gaugeValue
 			}
 			if rows.Err() != nil {
 				err = entities.NewInternalError("sql query error", rows.Err())
@@ -251,8 +347,8 @@ func (s *PgStorage) GetMetricsByTypes(ctx context.Context,
 		}
 
 		func() {
-			query := "select name, value from counter"
-			var name entities.MetricName
+			query := "select name, labels, value from counter"
+			var key entities.MetricKey
 			var counterValue entities.Counter
 			var rows pgx.Rows
 			rows, err = tx.Query(ctx, query)
@@ -263,11 +359,45 @@ func (s *PgStorage) GetMetricsByTypes(ctx context.Context,
 			defer rows.Close()
 
 			for rows.Next() {
-				if err = rows.Scan(&name, &counterValue); err != nil {
+				if err = rows.Scan(&key.Name, &key.Labels, &counterValue); err != nil {
+					err = entities.NewInternalError("sql query error", err)
+					return
+				}
+				counter[key] = counterValue
+			}
+			if rows.Err() != nil {
+				err = entities.NewInternalError("sql query error", rows.Err())
+				return
+			}
+		}()
+		if err != nil {
+			return err
+		}
+
+		func() {
+			query := "select name, labels, bounds, counts, count, sum from histogram"
+			var key entities.MetricKey
+			var rows pgx.Rows
+			rows, err = tx.Query(ctx, query)
+			if err != nil {
+				err = entities.NewInternalError("sql query error", err)
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				// scan into new slices on every row
+				var histogramValue entities.Histogram
+				var counts []int64
+				var count int64
+				if err = rows.Scan(&key.Name, &key.Labels, &histogramValue.Bounds, &counts,
+					&count, &histogramValue.Sum); err != nil {
 					err = entities.NewInternalError("sql query error", err)
 					return
 				}
-				counter[name] = counterValue
+				histogramValue.Counts = countsFromInt64(counts)
+				histogramValue.Count = uint64(count)
+				histogram[key] = histogramValue
 			}
 			if rows.Err() != nil {
 				err = entities.NewInternalError("sql query error", rows.Err())
@@ -0,0 +1,90 @@
+package pgstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/jackc/pgx/v5"
+)
+
+// insertSample appends current value of gauge or counter to the history; the
+// delta of counter is stored too to sum up deltas on compaction
+func insertSample(ctx context.Context, tx pgx.Tx, metric entities.Metric,
+	value float64,
+) error {
+	query := `
+		insert into sample (type, name, labels, value, delta)
+		values ($1, $2, $3, $4, $5)`
+	key := metric.Key()
+	_, err := tx.Exec(ctx, query, metricTypeNames[metric.Type], key.Name, key.Labels,
+		value, float64(metric.Delta))
+	return err
+}
+
+// aggregateColumns are sample_aggregate columns used as history values
+var aggregateColumns = map[entities.MetricType]string{
+	entities.MetricTypeGauge:   "avg",
+	entities.MetricTypeCounter: "last",
+}
+
+// GetSamples returns history of gauge or counter within [from, to): raw samples
+// and, where they are already deleted by CompactSamples, 1 minute aggregates
+// and then 1 hour aggregates
+func (s *PgStorage) GetSamples(ctx context.Context, metric entities.Metric,
+	from time.Time, to time.Time,
+) ([]entities.Sample, error) {
+	column, ok := aggregateColumns[metric.Type]
+	if !ok {
+		return nil, entities.ErrHistoryNotSupported
+	}
+	query := fmt.Sprintf(`
+		select ts, value from sample
+		where type = $1 and name = $2 and labels = $3 and ts >= $4 and ts < $5
+		union all
+		select ts, %[1]s from sample_aggregate
+		where resolution = $6 and type = $1 and name = $2 and labels = $3
+		  and ts >= $4 and ts < $5
+		  and ts < coalesce(
+		    (select min(ts) from sample
+		     where type = $1 and name = $2 and labels = $3),
+		    'infinity')
+		union all
+		select ts, %[1]s from sample_aggregate
+		where resolution = $7 and type = $1 and name = $2 and labels = $3
+		  and ts >= $4 and ts < $5
+		  and ts < coalesce(
+		    (select min(ts) from sample_aggregate
+		     where resolution = $6 and type = $1 and name = $2 and labels = $3),
+		    (select min(ts) from sample
+		     where type = $1 and name = $2 and labels = $3),
+		    'infinity')
+		order by ts`, column)
+	key := metric.Key()
+	var result []entities.Sample
+
+	doQueries := func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, metricTypeNames[metric.Type], key.Name,
+			key.Labels, from, to, minuteResolution, hourResolution)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		result = make([]entities.Sample, 0)
+		for rows.Next() {
+			var sample entities.Sample
+			if err := rows.Scan(&sample.Time, &sample.Value); err != nil {
+				return err
+			}
+			result = append(result, sample)
+		}
+		return rows.Err()
+	}
+
+	if err := doTransactionWithRetries(ctx, s.pool, doQueries); err != nil {
+		return nil, entities.NewInternalError("sql query error", err)
+	}
+	return result, nil
+}
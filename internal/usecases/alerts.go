@@ -0,0 +1,192 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+type alertNotifier interface {
+	Notify(ctx context.Context, alerts []entities.Alert) error
+}
+
+// alertRecord is the state of a rule between evaluations
+type alertRecord struct {
+	alert entities.Alert
+	// previous counter observation, used for rate
+	prevValue    float64
+	prevTime     time.Time
+	hasPrevValue bool
+}
+
+// maxUnsentAlerts limits notifications kept while the notifier fails, the
+// oldest ones are dropped
+const maxUnsentAlerts = 1000
+
+// alerter evaluates alert rules against the storage and keeps their states
+type alerter struct {
+	mutex    sync.Mutex
+	records  []alertRecord
+	notifier alertNotifier // may be nil
+	// transitions which the notifier failed to deliver, they are sent again
+	// with the next ones
+	unsent []entities.Alert
+}
+
+// WithAlerts enables evaluation of the rules by EvaluateAlerts; notifier, if
+// not nil, is notified about alerts which became firing or resolved
+func (m *MetricsUsecase) WithAlerts(rules []entities.AlertRule, notifier alertNotifier,
+) *MetricsUsecase {
+	records := make([]alertRecord, 0, len(rules))
+	for _, rule := range rules {
+		records = append(records, alertRecord{
+			alert: entities.Alert{Rule: rule, State: entities.AlertInactive},
+		})
+	}
+	m.alerter = &alerter{
+		records:  records,
+		notifier: notifier,
+	}
+	return m
+}
+
+// ListAlerts returns states of all alert rules in the order they were
+// configured; empty if alerting is disabled
+func (m *MetricsUsecase) ListAlerts() []entities.Alert {
+	if m.alerter == nil {
+		return []entities.Alert{}
+	}
+	m.alerter.mutex.Lock()
+	defer m.alerter.mutex.Unlock()
+	result := make([]entities.Alert, 0, len(m.alerter.records))
+	for _, record := range m.alerter.records {
+		result = append(result, record.alert)
+	}
+	return result
+}
+
+// EvaluateAlerts evaluates all alert rules at the moment now and notifies
+// about alerts which became firing or resolved. A rule, which failed to
+// evaluate, keeps its state and doesn't prevent evaluation of the others;
+// notifications, which failed to deliver, are sent again on the next call.
+func (m *MetricsUsecase) EvaluateAlerts(ctx context.Context, now time.Time) error {
+	if m.alerter == nil {
+		return nil
+	}
+	var errs []error
+	m.alerter.mutex.Lock()
+	for i := range m.alerter.records {
+		record := &m.alerter.records[i]
+		value, hasValue, err := m.evaluateAlertRule(ctx, record, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", record.alert.Rule.Name, err))
+			continue
+		}
+		if record.transit(value, hasValue, now) && m.alerter.notifier != nil {
+			m.alerter.unsent = append(m.alerter.unsent, record.alert)
+		}
+	}
+	notifications := m.alerter.unsent
+	m.alerter.unsent = nil
+	m.alerter.mutex.Unlock()
+
+	if len(notifications) > 0 {
+		if err := m.alerter.notifier.Notify(ctx, notifications); err != nil {
+			errs = append(errs, fmt.Errorf("notify: %w", err))
+			m.alerter.keepUnsent(notifications)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// keepUnsent puts failed notifications before the ones collected meanwhile
+func (a *alerter) keepUnsent(notifications []entities.Alert) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.unsent = append(notifications, a.unsent...)
+	if dropped := len(a.unsent) - maxUnsentAlerts; dropped > 0 {
+		slog.Warn("[alerts] unsent notifications dropped", "count", dropped)
+		a.unsent = a.unsent[dropped:]
+	}
+}
+
+// evaluateAlertRule returns the value of the rule operand; hasValue is false
+// if the metric is unknown or rate can't be computed yet
+func (m *MetricsUsecase) evaluateAlertRule(ctx context.Context, record *alertRecord,
+	now time.Time,
+) (value float64, hasValue bool, err error) {
+	condition := record.alert.Rule.Condition
+	metric, err := m.storage.GetMetric(ctx, condition.Metric)
+	if err != nil {
+		var metricNameNotFoundError *entities.MetricNameNotFoundError
+		if errors.As(err, &metricNameNotFoundError) {
+			record.hasPrevValue = false
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	switch metric.Type {
+	case entities.MetricTypeGauge:
+		value = float64(metric.Value)
+	case entities.MetricTypeCounter:
+		value = float64(metric.Delta)
+	}
+	if condition.Function != entities.AlertRate {
+		return value, true, nil
+	}
+
+	prevValue, prevTime, hasPrevValue := record.prevValue, record.prevTime, record.hasPrevValue
+	record.prevValue, record.prevTime, record.hasPrevValue = value, now, true
+	seconds := now.Sub(prevTime).Seconds()
+	if !hasPrevValue || seconds <= 0 {
+		return 0, false, nil
+	}
+	increase := value - prevValue
+	if increase < 0 {
+		// counter was reset, count from zero
+		increase = value
+	}
+	return increase / seconds, true, nil
+}
+
+// transit updates alert state with evaluated value and reports whether the
+// alert became firing or resolved
+func (r *alertRecord) transit(value float64, hasValue bool, now time.Time) bool {
+	alert := &r.alert
+	alert.Value, alert.HasValue = value, hasValue
+	holds := hasValue && alert.Rule.Condition.Holds(value)
+
+	if !holds {
+		switch alert.State {
+		case entities.AlertPending:
+			alert.State = entities.AlertInactive
+			alert.ActiveSince = time.Time{}
+		case entities.AlertFiring:
+			alert.State = entities.AlertResolved
+			alert.ActiveSince = time.Time{}
+			alert.ResolvedAt = now
+			slog.Info("[alerts] resolved", "rule", alert.Rule.Name)
+			return true
+		}
+		return false
+	}
+
+	if alert.State == entities.AlertInactive || alert.State == entities.AlertResolved {
+		alert.State = entities.AlertPending
+		alert.ActiveSince = now
+	}
+	if alert.State == entities.AlertPending && now.Sub(alert.ActiveSince) >= alert.Rule.For {
+		alert.State = entities.AlertFiring
+		alert.FiredAt = now
+		alert.ResolvedAt = time.Time{}
+		slog.Info("[alerts] firing", "rule", alert.Rule.Name, "value", value)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notifierFunc func(ctx context.Context, alerts []entities.Alert) error
+
+func (f notifierFunc) Notify(ctx context.Context, alerts []entities.Alert) error {
+	return f(ctx, alerts)
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	gaugeRule, err := entities.NewAlertRule("heap", "gauge HeapAlloc > 500MB for 2m", 0)
+	require.NoError(t, err)
+	rateRule, err := entities.NewAlertRule("stuck", "rate(counter PollCount) == 0", 0)
+	require.NoError(t, err)
+
+	values := map[entities.MetricName]entities.Metric{}
+	storage := &mockStorage{
+		GetMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+			result, ok := values[metric.Name]
+			if !ok {
+				return nil, entities.NewMetricNameNotFoundError(metric.Name)
+			}
+			return &result, nil
+		},
+	}
+	var notified [][]entities.Alert
+	notifier := notifierFunc(func(ctx context.Context, alerts []entities.Alert) error {
+		notified = append(notified, alerts)
+		return nil
+	})
+	usecase := NewMetricsUsecase(storage).
+		WithAlerts([]entities.AlertRule{gaugeRule, rateRule}, notifier)
+	states := func() []entities.AlertState {
+		var result []entities.AlertState
+		for _, alert := range usecase.ListAlerts() {
+			result = append(result, alert.State)
+		}
+		return result
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	setValues := func(heap entities.Gauge, poll entities.Counter) {
+		values["HeapAlloc"] = entities.Metric{
+			Type: entities.MetricTypeGauge, Name: "HeapAlloc", Value: heap,
+		}
+		values["PollCount"] = entities.Metric{
+			Type: entities.MetricTypeCounter, Name: "PollCount", Delta: poll,
+		}
+	}
+
+	// metrics are unknown yet
+	require.NoError(t, usecase.EvaluateAlerts(context.Background(), at(0)))
+	assert.Equal(t, []entities.AlertState{entities.AlertInactive, entities.AlertInactive}, states())
+
+	// heap is over the threshold, rate is not known yet
+	setValues(600<<20, 10)
+	require.NoError(t, usecase.EvaluateAlerts(context.Background(), at(time.Minute)))
+	assert.Equal(t, []entities.AlertState{entities.AlertPending, entities.AlertInactive}, states())
+	assert.Empty(t, notified)
+
+	// counter is stuck, heap is still pending
+	require.NoError(t, usecase.EvaluateAlerts(context.Background(), at(2*time.Minute)))
+	assert.Equal(t, []entities.AlertState{entities.AlertPending, entities.AlertFiring}, states())
+	require.Len(t, notified, 1)
+	assert.Equal(t, "stuck", notified[0][0].Rule.Name)
+
+	// heap fires after 2 minutes, counter increases
+	setValues(600<<20, 20)
+	require.NoError(t, usecase.EvaluateAlerts(context.Background(), at(3*time.Minute)))
+	assert.Equal(t, []entities.AlertState{entities.AlertFiring, entities.AlertResolved}, states())
+	require.Len(t, notified, 2)
+	require.Len(t, notified[1], 2)
+	assert.Equal(t, at(3*time.Minute), notified[1][0].FiredAt)
+	assert.Equal(t, at(3*time.Minute), notified[1][1].ResolvedAt)
+	assert.InDelta(t, 10.0/60, notified[1][1].Value, 1e-9)
+
+	// heap is back to normal
+	setValues(100<<20, 30)
+	require.NoError(t, usecase.EvaluateAlerts(context.Background(), at(4*time.Minute)))
+	assert.Equal(t, []entities.AlertState{entities.AlertResolved, entities.AlertResolved}, states())
+	assert.Len(t, notified, 3)
+}
+
+func TestEvaluateAlerts_Failures(t *testing.T) {
+	heapRule, err := entities.NewAlertRule("heap", "gauge HeapAlloc > 500MB", 0)
+	require.NoError(t, err)
+	brokenRule, err := entities.NewAlertRule("broken", "gauge Broken > 0", 0)
+	require.NoError(t, err)
+	storage := &mockStorage{
+		GetMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+			if metric.Name == "Broken" {
+				return nil, errors.New("connection lost")
+			}
+			return &entities.Metric{
+				Type: entities.MetricTypeGauge, Name: "HeapAlloc", Value: 600 << 20,
+			}, nil
+		},
+	}
+	notifyErr := errors.New("webhook is down")
+	var notified [][]entities.Alert
+	notifier := notifierFunc(func(ctx context.Context, alerts []entities.Alert) error {
+		notified = append(notified, alerts)
+		return notifyErr
+	})
+	// the failing rule goes first, so it must not prevent evaluation of others
+	usecase := NewMetricsUsecase(storage).
+		WithAlerts([]entities.AlertRule{brokenRule, heapRule}, notifier)
+
+	err = usecase.EvaluateAlerts(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "rule broken: connection lost")
+	assert.ErrorIs(t, err, notifyErr)
+	require.Len(t, notified, 1)
+	require.Len(t, notified[0], 1)
+	assert.Equal(t, "heap", notified[0][0].Rule.Name)
+	assert.Equal(t, entities.AlertFiring, usecase.ListAlerts()[1].State)
+
+	// state doesn't change, but failed notification is sent again
+	notifyErr = nil
+	err = usecase.EvaluateAlerts(context.Background(), time.Now())
+	assert.ErrorContains(t, err, "rule broken")
+	require.Len(t, notified, 2)
+	require.Len(t, notified[1], 1)
+	assert.Equal(t, "heap", notified[1][0].Rule.Name)
+
+	// delivered notification is not sent any more
+	_ = usecase.EvaluateAlerts(context.Background(), time.Now())
+	assert.Len(t, notified, 2)
+}
+
+func TestEvaluateAlertsDisabled(t *testing.T) {
+	usecase := NewMetricsUsecase(&mockStorage{})
+	require.NoError(t, usecase.EvaluateAlerts(context.Background(), time.Now()))
+	assert.Empty(t, usecase.ListAlerts())
+}
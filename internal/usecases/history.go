@@ -0,0 +1,71 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// maxHistoryPoints limits the number of points in the history response, to
+// protect server from queries like step=1ns for a whole year
+const maxHistoryPoints = 11000
+
+// GetHistory returns history of gauge or counter within [from, to), downsampled
+// to points with given step. Every point is timestamped by the beginning of its
+// interval; gauge point is the average of samples within the interval, counter
+// point is the last accumulated value. Intervals without samples are omitted.
+func (m *MetricsUsecase) GetHistory(ctx context.Context, metric entities.Metric,
+	from time.Time, to time.Time, step time.Duration,
+) ([]entities.Sample, error) {
+	if metric.Type != entities.MetricTypeGauge && metric.Type != entities.MetricTypeCounter {
+		return nil, fmt.Errorf("%w for %v, only for gauge and counter",
+			entities.ErrHistoryNotSupported, metric.Type)
+	}
+	if step <= 0 || !from.Before(to) || to.Sub(from)/step >= maxHistoryPoints {
+		return nil, entities.ErrInvalidHistoryQuery
+	}
+
+	samples, err := m.storage.GetSamples(ctx, metric, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return downsample(samples, metric.Type, from, step), nil
+}
+
+// downsample groups time-ordered samples by intervals [from+k*step, from+(k+1)*step)
+func downsample(samples []entities.Sample, metricType entities.MetricType,
+	from time.Time, step time.Duration,
+) []entities.Sample {
+	result := make([]entities.Sample, 0)
+	var (
+		current entities.Sample // point of the current interval
+		count   int             // samples in the current interval
+	)
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		if metricType == entities.MetricTypeGauge {
+			current.Value /= float64(count)
+		}
+		result = append(result, current)
+	}
+	for _, sample := range samples {
+		start := from.Add(sample.Time.Sub(from) / step * step)
+		if count == 0 || !start.Equal(current.Time) {
+			flush()
+			current = entities.Sample{Time: start}
+			count = 0
+		}
+		if metricType == entities.MetricTypeGauge {
+			current.Value += sample.Value
+		} else {
+			current.Value = sample.Value
+		}
+		count++
+	}
+	flush()
+	return result
+}
@@ -0,0 +1,124 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHistory(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return from.Add(d) }
+	samples := []entities.Sample{
+		{Time: at(10 * time.Second), Value: 1},
+		{Time: at(50 * time.Second), Value: 3},
+		{Time: at(3*time.Minute + 5*time.Second), Value: 10},
+	}
+
+	type given struct {
+		metric entities.Metric
+		to     time.Time
+		step   time.Duration
+	}
+	type want struct {
+		points    []entities.Sample
+		err       error
+		callCount int
+	}
+	tests := []struct {
+		name  string
+		given given
+		want  want
+	}{
+		{
+			name: "gauge: average within interval",
+			given: given{
+				metric: entities.Metric{Type: entities.MetricTypeGauge, Name: "foo"},
+				to:     at(time.Hour),
+				step:   time.Minute,
+			},
+			want: want{
+				points: []entities.Sample{
+					{Time: at(0), Value: 2},
+					{Time: at(3 * time.Minute), Value: 10},
+				},
+				callCount: 1,
+			},
+		},
+		{
+			name: "counter: last value within interval",
+			given: given{
+				metric: entities.Metric{Type: entities.MetricTypeCounter, Name: "bar"},
+				to:     at(time.Hour),
+				step:   2 * time.Minute,
+			},
+			want: want{
+				points: []entities.Sample{
+					{Time: at(0), Value: 3},
+					{Time: at(2 * time.Minute), Value: 10},
+				},
+				callCount: 1,
+			},
+		},
+		{
+			name: "histogram is not supported",
+			given: given{
+				metric: entities.Metric{Type: entities.MetricTypeHistogram, Name: "baz"},
+				to:     at(time.Hour),
+				step:   time.Minute,
+			},
+			want: want{err: entities.ErrHistoryNotSupported},
+		},
+		{
+			name: "non-positive step",
+			given: given{
+				metric: entities.Metric{Type: entities.MetricTypeGauge, Name: "foo"},
+				to:     at(time.Hour),
+			},
+			want: want{err: entities.ErrInvalidHistoryQuery},
+		},
+		{
+			name: "empty range",
+			given: given{
+				metric: entities.Metric{Type: entities.MetricTypeGauge, Name: "foo"},
+				to:     from,
+				step:   time.Minute,
+			},
+			want: want{err: entities.ErrInvalidHistoryQuery},
+		},
+		{
+			name: "too many points",
+			given: given{
+				metric: entities.Metric{Type: entities.MetricTypeGauge, Name: "foo"},
+				to:     at(24 * time.Hour),
+				step:   time.Second,
+			},
+			want: want{err: entities.ErrInvalidHistoryQuery},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{
+				GetSamplesFunc: func(ctx context.Context, metric entities.Metric,
+					f time.Time, to time.Time,
+				) ([]entities.Sample, error) {
+					require.Equal(t, tt.given.metric, metric)
+					require.Equal(t, from, f)
+					require.Equal(t, tt.given.to, to)
+					return samples, nil
+				},
+			}
+			usecase := NewMetricsUsecase(storage)
+			points, err := usecase.GetHistory(context.Background(), tt.given.metric,
+				from, tt.given.to, tt.given.step)
+			assert.ErrorIs(t, err, tt.want.err)
+			assert.Equal(t, tt.want.points, points)
+			assert.Equal(t, tt.want.callCount, len(storage.calls.GetSamples))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package usecases
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// subscriptionBuffer is the number of updates, which may be queued for a
+// subscriber; the subscriber is dropped if it doesn't keep up
+const subscriptionBuffer = 64
+
+type subscription struct {
+	filter  entities.MetricFilter
+	updates chan []entities.Metric
+}
+
+// hub delivers updated metrics to subscribers without blocking the writers
+type hub struct {
+	mutex       sync.Mutex
+	subscribers map[*subscription]struct{}
+	closed      bool
+}
+
+func newHub() *hub {
+	return &hub{
+		subscribers: make(map[*subscription]struct{}),
+	}
+}
+
+// subscribe returns channel of updated metrics matching filter and function to
+// unsubscribe; the channel is closed on unsubscribe, if the subscriber is too
+// slow or if the hub is closed
+func (h *hub) subscribe(filter entities.MetricFilter) (<-chan []entities.Metric, func()) {
+	sub := &subscription{
+		filter:  filter,
+		updates: make(chan []entities.Metric, subscriptionBuffer),
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		close(sub.updates)
+	} else {
+		h.subscribers[sub] = struct{}{}
+	}
+	return sub.updates, func() { h.unsubscribe(sub) }
+}
+
+func (h *hub) unsubscribe(sub *subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.remove(sub)
+}
+
+// publish sends matching metrics to every subscriber
+func (h *hub) publish(metrics []entities.Metric) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for sub := range h.subscribers {
+		matching := make([]entities.Metric, 0, len(metrics))
+		for _, metric := range metrics {
+			if sub.filter.Match(metric) {
+				matching = append(matching, metric)
+			}
+		}
+		if len(matching) == 0 {
+			continue
+		}
+		select {
+		case sub.updates <- matching:
+		default:
+			slog.Warn("[hub] slow subscriber dropped")
+			h.remove(sub)
+		}
+	}
+}
+
+// close drops all subscribers and rejects new ones
+func (h *hub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for sub := range h.subscribers {
+		h.remove(sub)
+	}
+	h.closed = true
+}
+
+// remove should be called under lock
+func (h *hub) remove(sub *subscription) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.updates)
+	}
+}
@@ -0,0 +1,74 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe(t *testing.T) {
+	storage := &mockStorage{
+		UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric,
+		) ([]entities.Metric, error) {
+			return metrics, nil
+		},
+	}
+	usecase := NewMetricsUsecase(storage)
+	gauge := entities.Metric{Type: entities.MetricTypeGauge, Name: "Alloc", Value: 1}
+	counter := entities.Metric{Type: entities.MetricTypeCounter, Name: "PollCount", Delta: 1}
+
+	all, unsubscribeAll := usecase.Subscribe(entities.MetricFilter{})
+	defer unsubscribeAll()
+	counters, unsubscribeCounters := usecase.Subscribe(entities.MetricFilter{
+		Types: []entities.MetricType{entities.MetricTypeCounter},
+	})
+
+	_, err := usecase.UpdateMetrics(context.Background(), []entities.Metric{gauge, counter})
+	require.NoError(t, err)
+	assert.Equal(t, []entities.Metric{gauge, counter}, <-all)
+	assert.Equal(t, []entities.Metric{counter}, <-counters)
+
+	// metrics not matching the filter are not delivered
+	_, err = usecase.UpdateMetrics(context.Background(), []entities.Metric{gauge})
+	require.NoError(t, err)
+	assert.Equal(t, []entities.Metric{gauge}, <-all)
+	assert.Empty(t, counters)
+
+	unsubscribeCounters()
+	_, ok := <-counters
+	assert.False(t, ok)
+}
+
+func TestSubscribe_SlowSubscriberDropped(t *testing.T) {
+	h := newHub()
+	slow, unsubscribe := h.subscribe(entities.MetricFilter{})
+	defer unsubscribe()
+	metrics := []entities.Metric{{Type: entities.MetricTypeGauge, Name: "Alloc"}}
+
+	// publishing never blocks
+	for range subscriptionBuffer + 1 {
+		h.publish(metrics)
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+	assert.Empty(t, h.subscribers)
+}
+
+func TestSubscribe_Close(t *testing.T) {
+	h := newHub()
+	updates, _ := h.subscribe(entities.MetricFilter{})
+	h.close()
+	_, ok := <-updates
+	assert.False(t, ok)
+
+	// subscription after close is closed immediately
+	updates, _ = h.subscribe(entities.MetricFilter{})
+	_, ok = <-updates
+	assert.False(t, ok)
+}
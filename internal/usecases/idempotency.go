@@ -0,0 +1,58 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// WithIdempotencyTTL enables deduplication of batches by idempotency key: the
+// result of the applied batch is kept for ttl and returned to its replays
+func (m *MetricsUsecase) WithIdempotencyTTL(ttl time.Duration) *MetricsUsecase {
+	m.idempotencyTTL = ttl
+	return m
+}
+
+// UpdateMetricsOnce updates metrics the same way as UpdateMetrics, or
+// UpdateAgentMetrics if agent is not nil, unless the batch with the same key
+// is already applied; then the original result is returned and replayed is
+// true. Batches are not deduplicated if the key is empty or deduplication is
+// disabled.
+func (m *MetricsUsecase) UpdateMetricsOnce(ctx context.Context, key string,
+	agent *entities.Agent, metrics []entities.Metric,
+) (result []entities.Metric, replayed bool, err error) {
+	if len(key) == 0 || m.idempotencyTTL <= 0 {
+		if agent != nil {
+			result, err = m.UpdateAgentMetrics(ctx, *agent, metrics)
+		} else {
+			result, err = m.UpdateMetrics(ctx, metrics)
+		}
+		return result, false, err
+	}
+
+	idempotency, err := entities.NewIdempotency(key, metrics)
+	if err != nil {
+		return nil, false, err
+	}
+	result, replayed, err = m.storage.UpdateMetricsOnce(ctx, idempotency, metrics)
+	if err != nil {
+		return nil, false, err
+	}
+	if !replayed {
+		m.hub.publish(result)
+	}
+	if agent != nil {
+		m.updateAgent(ctx, *agent, metrics)
+	}
+	return result, replayed, nil
+}
+
+// ExpireIdempotencyKeys forgets batches applied earlier than configured ttl
+// before now
+func (m *MetricsUsecase) ExpireIdempotencyKeys(ctx context.Context, now time.Time) error {
+	if m.idempotencyTTL <= 0 {
+		return nil
+	}
+	return m.storage.ExpireIdempotencyKeys(ctx, now.Add(-m.idempotencyTTL))
+}
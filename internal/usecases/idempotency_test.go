@@ -0,0 +1,114 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateMetricsOnce(t *testing.T) {
+	metrics := []entities.Metric{
+		{Type: entities.MetricTypeCounter, Name: "PollCount", Delta: 1},
+	}
+	applied := map[string]bool{}
+	storage := &mockStorage{
+		UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric,
+		) ([]entities.Metric, error) {
+			return metrics, nil
+		},
+		UpdateMetricsOnceFunc: func(ctx context.Context, idempotency entities.Idempotency,
+			metrics []entities.Metric,
+		) ([]entities.Metric, bool, error) {
+			replayed := applied[idempotency.Key]
+			applied[idempotency.Key] = true
+			return metrics, replayed, nil
+		},
+		UpdateAgentFunc: func(ctx context.Context, agent entities.Agent,
+			metrics []entities.Metric,
+		) error {
+			return nil
+		},
+		ExpireIdempotencyKeysFunc: func(ctx context.Context, before time.Time) error {
+			return nil
+		},
+	}
+	ctx := context.Background()
+
+	// deduplication is disabled
+	usecase := NewMetricsUsecase(storage)
+	_, replayed, err := usecase.UpdateMetricsOnce(ctx, "k1", nil, metrics)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Len(t, storage.calls.UpdateMetrics, 1)
+	assert.Empty(t, storage.calls.UpdateMetricsOnce)
+	require.NoError(t, usecase.ExpireIdempotencyKeys(ctx, time.Now()))
+	assert.Empty(t, storage.calls.ExpireIdempotencyKeys)
+
+	usecase = NewMetricsUsecase(storage).WithIdempotencyTTL(time.Hour)
+	updates, unsubscribe := usecase.Subscribe(entities.MetricFilter{})
+	defer unsubscribe()
+	agent := &entities.Agent{ID: "a1"}
+
+	_, replayed, err = usecase.UpdateMetricsOnce(ctx, "k1", agent, metrics)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, metrics, <-updates)
+
+	// replay is not published, but the agent is still seen
+	_, replayed, err = usecase.UpdateMetricsOnce(ctx, "k1", agent, metrics)
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Empty(t, updates)
+	assert.Len(t, storage.calls.UpdateAgent, 2)
+
+	// batch without key is not deduplicated
+	_, _, err = usecase.UpdateMetricsOnce(ctx, "", nil, metrics)
+	require.NoError(t, err)
+	assert.Len(t, storage.calls.UpdateMetrics, 2)
+
+	now := time.Now()
+	require.NoError(t, usecase.ExpireIdempotencyKeys(ctx, now))
+	require.Len(t, storage.calls.ExpireIdempotencyKeys, 1)
+	assert.Equal(t, now.Add(-time.Hour), storage.calls.ExpireIdempotencyKeys[0].Before)
+}
+
+// metrics are already applied when the agent record fails, so the batch must
+// not be reported as failed, otherwise the agent resends it
+func TestUpdateAgentMetrics_UpdateAgentError(t *testing.T) {
+	metrics := []entities.Metric{
+		{Type: entities.MetricTypeCounter, Name: "PollCount", Delta: 1},
+	}
+	storage := &mockStorage{
+		UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric,
+		) ([]entities.Metric, error) {
+			return metrics, nil
+		},
+		UpdateMetricsOnceFunc: func(ctx context.Context, idempotency entities.Idempotency,
+			metrics []entities.Metric,
+		) ([]entities.Metric, bool, error) {
+			return metrics, false, nil
+		},
+		UpdateAgentFunc: func(ctx context.Context, agent entities.Agent,
+			metrics []entities.Metric,
+		) error {
+			return errors.New("connection lost")
+		},
+	}
+	ctx := context.Background()
+	usecase := NewMetricsUsecase(storage).WithIdempotencyTTL(time.Hour)
+	agent := entities.Agent{ID: "a1"}
+
+	result, err := usecase.UpdateAgentMetrics(ctx, agent, metrics)
+	require.NoError(t, err)
+	assert.Equal(t, metrics, result)
+
+	result, _, err = usecase.UpdateMetricsOnce(ctx, "k1", &agent, metrics)
+	require.NoError(t, err)
+	assert.Equal(t, metrics, result)
+	assert.Len(t, storage.calls.UpdateAgent, 2)
+}
@@ -3,7 +3,10 @@ package usecases
 import (
 	"context"
 	"fmt"
-	"sort"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/entities"
 )
@@ -12,8 +15,20 @@ type storage interface {
 	GetMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 	UpdateMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 	UpdateMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
-	GetMetricsByTypes(ctx context.Context, gauge map[entities.MetricName]entities.Gauge,
-		counter map[entities.MetricName]entities.Counter) error
+	UpdateMetricsOnce(ctx context.Context, idempotency entities.Idempotency,
+		metrics []entities.Metric) ([]entities.Metric, bool, error)
+	ExpireIdempotencyKeys(ctx context.Context, before time.Time) error
+	DeleteMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	DeleteMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+	ResetCounter(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+	GetMetricsByTypes(ctx context.Context, gauge map[entities.MetricKey]entities.Gauge,
+		counter map[entities.MetricKey]entities.Counter,
+		histogram map[entities.MetricKey]entities.Histogram) error
+	FindMetrics(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error)
+	UpdateAgent(ctx context.Context, agent entities.Agent, metrics []entities.Metric) error
+	GetAgents(ctx context.Context) ([]entities.Agent, error)
+	GetSamples(ctx context.Context, metric entities.Metric, from time.Time,
+		to time.Time) ([]entities.Sample, error)
 	Ping(ctx context.Context) error
 	Close(ctx context.Context) error
 }
@@ -29,33 +44,32 @@ type IteratableDump struct {
 	index int
 }
 
-func NewIteratableDump(gauge map[entities.MetricName]entities.Gauge,
-	counter map[entities.MetricName]entities.Counter,
+func NewIteratableDump(gauge map[entities.MetricKey]entities.Gauge,
+	counter map[entities.MetricKey]entities.Counter,
+	histogram map[entities.MetricKey]entities.Histogram,
 ) *IteratableDump {
-	gaugeKeys := make([]string, 0, len(gauge))
-	for k := range gauge {
-		gaugeKeys = append(gaugeKeys, string(k))
-	}
-	sort.Strings(gaugeKeys)
-
-	counterKeys := make([]string, 0, len(counter))
-	for k := range counter {
-		counterKeys = append(counterKeys, string(k))
-	}
-	sort.Strings(counterKeys)
+	gaugeKeys := slices.SortedFunc(maps.Keys(gauge), entities.MetricKey.Compare)
+	counterKeys := slices.SortedFunc(maps.Keys(counter), entities.MetricKey.Compare)
+	histogramKeys := slices.SortedFunc(maps.Keys(histogram), entities.MetricKey.Compare)
 
 	result := IteratableDump{
-		rows: make([]DumpRow, 0, len(gaugeKeys)+len(counterKeys)),
+		rows: make([]DumpRow, 0,
+			len(gaugeKeys)+len(counterKeys)+len(histogramKeys)),
 	}
 
 	for _, k := range gaugeKeys {
 		result.rows = append(result.rows,
-			DumpRow{"gauge", k, fmt.Sprint(gauge[entities.MetricName(k)])})
+			DumpRow{"gauge", k.String(), fmt.Sprint(gauge[k])})
 	}
 
 	for _, k := range counterKeys {
 		result.rows = append(result.rows,
-			DumpRow{"counter", k, fmt.Sprint(counter[entities.MetricName(k)])})
+			DumpRow{"counter", k.String(), fmt.Sprint(counter[k])})
+	}
+
+	for _, k := range histogramKeys {
+		result.rows = append(result.rows,
+			DumpRow{"histogram", k.String(), histogram[k].String()})
 	}
 
 	return &result
@@ -75,11 +89,20 @@ func (d *IteratableDump) NextMetric() (
 // MetricsUsecase contains use cases, related to metrics creating, reading and updating
 type MetricsUsecase struct {
 	storage storage
+	hub     *hub
+	alerter *alerter // nil if alerting is disabled
+
+	started    time.Time
+	staleAfter time.Duration // stale detection is disabled if 0
+	// deduplication of batches by idempotency key is disabled if 0
+	idempotencyTTL time.Duration
 }
 
 func NewMetricsUsecase(storage storage) *MetricsUsecase {
 	return &MetricsUsecase{
 		storage: storage,
+		hub:     newHub(),
+		started: time.Now(),
 	}
 }
 
@@ -90,25 +113,161 @@ func (m *MetricsUsecase) GetMetric(ctx context.Context, metric entities.Metric,
 
 func (m *MetricsUsecase) UpdateMetric(ctx context.Context, metric entities.Metric,
 ) (*entities.Metric, error) {
-	return m.storage.UpdateMetric(ctx, metric)
+	result, err := m.storage.UpdateMetric(ctx, metric)
+	if err != nil {
+		return nil, err
+	}
+	m.hub.publish([]entities.Metric{*result})
+	return result, nil
 }
 
 func (m *MetricsUsecase) UpdateMetrics(ctx context.Context, metrics []entities.Metric,
 ) ([]entities.Metric, error) {
-	return m.storage.UpdateMetrics(ctx, metrics)
+	result, err := m.storage.UpdateMetrics(ctx, metrics)
+	if err != nil {
+		return nil, err
+	}
+	m.hub.publish(result)
+	return result, nil
+}
+
+// DeleteMetric deletes metric with its history and returns its last value
+func (m *MetricsUsecase) DeleteMetric(ctx context.Context, metric entities.Metric,
+) (*entities.Metric, error) {
+	result, err := m.storage.DeleteMetric(ctx, metric)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteMetrics deletes metrics with their history and returns last values of
+// deleted ones; unknown metrics are skipped
+func (m *MetricsUsecase) DeleteMetrics(ctx context.Context, metrics []entities.Metric,
+) ([]entities.Metric, error) {
+	result, err := m.storage.DeleteMetrics(ctx, metrics)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ResetCounter sets counter value to zero
+func (m *MetricsUsecase) ResetCounter(ctx context.Context, metric entities.Metric,
+) (*entities.Metric, error) {
+	return m.storage.ResetCounter(ctx, metric)
+}
+
+// UpdateAgentMetrics updates metrics the same way as UpdateMetrics and records
+// that they are reported by the agent, which is seen now
+func (m *MetricsUsecase) UpdateAgentMetrics(ctx context.Context, agent entities.Agent,
+	metrics []entities.Metric,
+) ([]entities.Metric, error) {
+	result, err := m.UpdateMetrics(ctx, metrics)
+	if err != nil {
+		return nil, err
+	}
+	m.updateAgent(ctx, agent, metrics)
+	return result, nil
+}
+
+// updateAgent records that the agent is seen now; metrics are already applied
+// at this point, so failure is only logged: the error would make the agent
+// send the batch once again and counters would be added twice
+func (m *MetricsUsecase) updateAgent(ctx context.Context, agent entities.Agent,
+	metrics []entities.Metric,
+) {
+	agent.LastSeen = time.Now().UTC()
+	if err := m.storage.UpdateAgent(ctx, agent, metrics); err != nil {
+		slog.Error("[agents] storage.UpdateAgent() error",
+			"agentID", agent.ID,
+			"error", err)
+	}
+}
+
+// ListAgents returns all known agents sorted by id
+func (m *MetricsUsecase) ListAgents(ctx context.Context) ([]entities.Agent, error) {
+	return m.storage.GetAgents(ctx)
 }
 
 func (m *MetricsUsecase) DumpIterator(ctx context.Context) (func() (type_ string, name string, value string, exists bool), error) {
-	gauge := make(map[entities.MetricName]entities.Gauge)
-	counter := make(map[entities.MetricName]entities.Counter)
-	if err := m.storage.GetMetricsByTypes(ctx, gauge, counter); err != nil {
+	gauge := make(map[entities.MetricKey]entities.Gauge)
+	counter := make(map[entities.MetricKey]entities.Counter)
+	histogram := make(map[entities.MetricKey]entities.Histogram)
+	if err := m.storage.GetMetricsByTypes(ctx, gauge, counter, histogram); err != nil {
 		return nil, err
 	}
 
-	iteratableDump := NewIteratableDump(gauge, counter)
+	iteratableDump := NewIteratableDump(gauge, counter, histogram)
 	return iteratableDump.NextMetric, nil
 }
 
+// ListMetrics returns all known metrics: gauges first, then counters, then
+// histograms, each group sorted by name and labels
+func (m *MetricsUsecase) ListMetrics(ctx context.Context) ([]entities.Metric, error) {
+	gauge := make(map[entities.MetricKey]entities.Gauge)
+	counter := make(map[entities.MetricKey]entities.Counter)
+	histogram := make(map[entities.MetricKey]entities.Histogram)
+	if err := m.storage.GetMetricsByTypes(ctx, gauge, counter, histogram); err != nil {
+		return nil, err
+	}
+
+	gaugeKeys := slices.SortedFunc(maps.Keys(gauge), entities.MetricKey.Compare)
+	counterKeys := slices.SortedFunc(maps.Keys(counter), entities.MetricKey.Compare)
+	histogramKeys := slices.SortedFunc(maps.Keys(histogram), entities.MetricKey.Compare)
+
+	result := make([]entities.Metric, 0,
+		len(gaugeKeys)+len(counterKeys)+len(histogramKeys))
+	for _, k := range gaugeKeys {
+		result = append(result, entities.Metric{
+			Type:   entities.MetricTypeGauge,
+			Name:   k.Name,
+			Labels: k.LabelSet(),
+			Value:  gauge[k],
+		})
+	}
+	for _, k := range counterKeys {
+		result = append(result, entities.Metric{
+			Type:   entities.MetricTypeCounter,
+			Name:   k.Name,
+			Labels: k.LabelSet(),
+			Delta:  counter[k],
+		})
+	}
+	for _, k := range histogramKeys {
+		result = append(result, entities.Metric{
+			Type:      entities.MetricTypeHistogram,
+			Name:      k.Name,
+			Labels:    k.LabelSet(),
+			Histogram: histogram[k],
+		})
+	}
+	return result, nil
+}
+
+// FindMetrics returns the page of metrics selected and sorted by filter
+func (m *MetricsUsecase) FindMetrics(ctx context.Context, filter entities.MetricFilter,
+) ([]entities.Metric, error) {
+	if filter.Offset < 0 || filter.Limit < 0 {
+		return nil, entities.ErrInvalidMetricFilter
+	}
+	return m.storage.FindMetrics(ctx, filter)
+}
+
+// Subscribe returns channel of updated metrics matching filter, sorting and
+// pagination are ignored; call unsubscribe when updates are not needed anymore.
+// The channel is closed if the subscriber doesn't keep up with updates or on
+// CloseSubscriptions.
+func (m *MetricsUsecase) Subscribe(filter entities.MetricFilter,
+) (updates <-chan []entities.Metric, unsubscribe func()) {
+	return m.hub.subscribe(filter)
+}
+
+// CloseSubscriptions closes all subscriptions, e.g. on server shutdown
+func (m *MetricsUsecase) CloseSubscriptions() {
+	m.hub.close()
+}
+
 func (m *MetricsUsecase) Ping(ctx context.Context) error {
 	return m.storage.Ping(ctx)
 }
@@ -15,18 +15,23 @@ func BenchmarkMetricsUsecase(b *testing.B) {
 	usecase := NewMetricsUsecase(&storage)
 
 	metricsCount := 100
-	staticGauge := make(map[entities.MetricName]entities.Gauge)
+	staticGauge := make(map[entities.MetricKey]entities.Gauge)
 	for i := range metricsCount {
-		staticGauge["gauge_"+entities.MetricName(strconv.Itoa(i))] = entities.Gauge(i)
+		staticGauge[entities.MetricKey{
+			Name: "gauge_" + entities.MetricName(strconv.Itoa(i)),
+		}] = entities.Gauge(i)
 	}
-	staticCounter := make(map[entities.MetricName]entities.Counter)
+	staticCounter := make(map[entities.MetricKey]entities.Counter)
 	for i := range metricsCount {
-		staticCounter["counter_"+entities.MetricName(strconv.Itoa(i))] = entities.Counter(i)
+		staticCounter[entities.MetricKey{
+			Name: "counter_" + entities.MetricName(strconv.Itoa(i)),
+		}] = entities.Counter(i)
 	}
 
 	storage.GetMetricsByTypesFunc = func(ctx context.Context,
-		gauge map[entities.MetricName]entities.Gauge,
-		counter map[entities.MetricName]entities.Counter,
+		gauge map[entities.MetricKey]entities.Gauge,
+		counter map[entities.MetricKey]entities.Counter,
+		histogram map[entities.MetricKey]entities.Histogram,
 	) error {
 		maps.Copy(gauge, staticGauge)
 		maps.Copy(counter, staticCounter)
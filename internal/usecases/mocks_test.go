@@ -7,6 +7,7 @@ package usecases
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/PiskarevSA/go-advanced/internal/entities"
 )
@@ -24,21 +25,48 @@ var _ storage = &mockStorage{}
 //			CloseFunc: func(ctx context.Context) error {
 //				panic("mock out the Close method")
 //			},
+//			DeleteMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+//				panic("mock out the DeleteMetric method")
+//			},
+//			DeleteMetricsFunc: func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
+//				panic("mock out the DeleteMetrics method")
+//			},
+//			ExpireIdempotencyKeysFunc: func(ctx context.Context, before time.Time) error {
+//				panic("mock out the ExpireIdempotencyKeys method")
+//			},
+//			FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error) {
+//				panic("mock out the FindMetrics method")
+//			},
+//			GetAgentsFunc: func(ctx context.Context) ([]entities.Agent, error) {
+//				panic("mock out the GetAgents method")
+//			},
 //			GetMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 //				panic("mock out the GetMetric method")
 //			},
-//			GetMetricsByTypesFunc: func(ctx context.Context, gauge map[entities.MetricName]entities.Gauge, counter map[entities.MetricName]entities.Counter) error {
+//			GetMetricsByTypesFunc: func(ctx context.Context, gauge map[entities.MetricKey]entities.Gauge, counter map[entities.MetricKey]entities.Counter, histogram map[entities.MetricKey]entities.Histogram) error {
 //				panic("mock out the GetMetricsByTypes method")
 //			},
+//			GetSamplesFunc: func(ctx context.Context, metric entities.Metric, from time.Time, to time.Time) ([]entities.Sample, error) {
+//				panic("mock out the GetSamples method")
+//			},
 //			PingFunc: func(ctx context.Context) error {
 //				panic("mock out the Ping method")
 //			},
+//			ResetCounterFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+//				panic("mock out the ResetCounter method")
+//			},
+//			UpdateAgentFunc: func(ctx context.Context, agent entities.Agent, metrics []entities.Metric) error {
+//				panic("mock out the UpdateAgent method")
+//			},
 //			UpdateMetricFunc: func(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 //				panic("mock out the UpdateMetric method")
 //			},
 //			UpdateMetricsFunc: func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
 //				panic("mock out the UpdateMetrics method")
 //			},
+//			UpdateMetricsOnceFunc: func(ctx context.Context, idempotency entities.Idempotency, metrics []entities.Metric) ([]entities.Metric, bool, error) {
+//				panic("mock out the UpdateMetricsOnce method")
+//			},
 //		}
 //
 //		// use mockedstorage in code that requires storage
@@ -49,21 +77,48 @@ type mockStorage struct {
 	// CloseFunc mocks the Close method.
 	CloseFunc func(ctx context.Context) error
 
+	// DeleteMetricFunc mocks the DeleteMetric method.
+	DeleteMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+
+	// DeleteMetricsFunc mocks the DeleteMetrics method.
+	DeleteMetricsFunc func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
+
+	// ExpireIdempotencyKeysFunc mocks the ExpireIdempotencyKeys method.
+	ExpireIdempotencyKeysFunc func(ctx context.Context, before time.Time) error
+
+	// FindMetricsFunc mocks the FindMetrics method.
+	FindMetricsFunc func(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error)
+
+	// GetAgentsFunc mocks the GetAgents method.
+	GetAgentsFunc func(ctx context.Context) ([]entities.Agent, error)
+
 	// GetMetricFunc mocks the GetMetric method.
 	GetMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 
 	// GetMetricsByTypesFunc mocks the GetMetricsByTypes method.
-	GetMetricsByTypesFunc func(ctx context.Context, gauge map[entities.MetricName]entities.Gauge, counter map[entities.MetricName]entities.Counter) error
+	GetMetricsByTypesFunc func(ctx context.Context, gauge map[entities.MetricKey]entities.Gauge, counter map[entities.MetricKey]entities.Counter, histogram map[entities.MetricKey]entities.Histogram) error
+
+	// GetSamplesFunc mocks the GetSamples method.
+	GetSamplesFunc func(ctx context.Context, metric entities.Metric, from time.Time, to time.Time) ([]entities.Sample, error)
 
 	// PingFunc mocks the Ping method.
 	PingFunc func(ctx context.Context) error
 
+	// ResetCounterFunc mocks the ResetCounter method.
+	ResetCounterFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
+
+	// UpdateAgentFunc mocks the UpdateAgent method.
+	UpdateAgentFunc func(ctx context.Context, agent entities.Agent, metrics []entities.Metric) error
+
 	// UpdateMetricFunc mocks the UpdateMetric method.
 	UpdateMetricFunc func(ctx context.Context, metric entities.Metric) (*entities.Metric, error)
 
 	// UpdateMetricsFunc mocks the UpdateMetrics method.
 	UpdateMetricsFunc func(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error)
 
+	// UpdateMetricsOnceFunc mocks the UpdateMetricsOnce method.
+	UpdateMetricsOnceFunc func(ctx context.Context, idempotency entities.Idempotency, metrics []entities.Metric) ([]entities.Metric, bool, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// Close holds details about calls to the Close method.
@@ -71,6 +126,39 @@ type mockStorage struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// DeleteMetric holds details about calls to the DeleteMetric method.
+		DeleteMetric []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+		}
+		// DeleteMetrics holds details about calls to the DeleteMetrics method.
+		DeleteMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
+		// ExpireIdempotencyKeys holds details about calls to the ExpireIdempotencyKeys method.
+		ExpireIdempotencyKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Before is the before argument value.
+			Before time.Time
+		}
+		// FindMetrics holds details about calls to the FindMetrics method.
+		FindMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Filter is the filter argument value.
+			Filter entities.MetricFilter
+		}
+		// GetAgents holds details about calls to the GetAgents method.
+		GetAgents []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 		// GetMetric holds details about calls to the GetMetric method.
 		GetMetric []struct {
 			// Ctx is the ctx argument value.
@@ -83,15 +171,44 @@ type mockStorage struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 			// Gauge is the gauge argument value.
-			Gauge map[entities.MetricName]entities.Gauge
+			Gauge map[entities.MetricKey]entities.Gauge
 			// Counter is the counter argument value.
-			Counter map[entities.MetricName]entities.Counter
+			Counter map[entities.MetricKey]entities.Counter
+			// Histogram is the histogram argument value.
+			Histogram map[entities.MetricKey]entities.Histogram
+		}
+		// GetSamples holds details about calls to the GetSamples method.
+		GetSamples []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+			// From is the from argument value.
+			From time.Time
+			// To is the to argument value.
+			To time.Time
 		}
 		// Ping holds details about calls to the Ping method.
 		Ping []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// ResetCounter holds details about calls to the ResetCounter method.
+		ResetCounter []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Metric is the metric argument value.
+			Metric entities.Metric
+		}
+		// UpdateAgent holds details about calls to the UpdateAgent method.
+		UpdateAgent []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Agent is the agent argument value.
+			Agent entities.Agent
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
 		// UpdateMetric holds details about calls to the UpdateMetric method.
 		UpdateMetric []struct {
 			// Ctx is the ctx argument value.
@@ -106,13 +223,31 @@ type mockStorage struct {
 			// Metrics is the metrics argument value.
 			Metrics []entities.Metric
 		}
+		// UpdateMetricsOnce holds details about calls to the UpdateMetricsOnce method.
+		UpdateMetricsOnce []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Idempotency is the idempotency argument value.
+			Idempotency entities.Idempotency
+			// Metrics is the metrics argument value.
+			Metrics []entities.Metric
+		}
 	}
-	lockClose             sync.RWMutex
-	lockGetMetric         sync.RWMutex
-	lockGetMetricsByTypes sync.RWMutex
-	lockPing              sync.RWMutex
-	lockUpdateMetric      sync.RWMutex
-	lockUpdateMetrics     sync.RWMutex
+	lockClose                 sync.RWMutex
+	lockDeleteMetric          sync.RWMutex
+	lockDeleteMetrics         sync.RWMutex
+	lockExpireIdempotencyKeys sync.RWMutex
+	lockFindMetrics           sync.RWMutex
+	lockGetAgents             sync.RWMutex
+	lockGetMetric             sync.RWMutex
+	lockGetMetricsByTypes     sync.RWMutex
+	lockGetSamples            sync.RWMutex
+	lockPing                  sync.RWMutex
+	lockResetCounter          sync.RWMutex
+	lockUpdateAgent           sync.RWMutex
+	lockUpdateMetric          sync.RWMutex
+	lockUpdateMetrics         sync.RWMutex
+	lockUpdateMetricsOnce     sync.RWMutex
 }
 
 // Close calls CloseFunc.
@@ -147,6 +282,182 @@ func (mock *mockStorage) CloseCalls() []struct {
 	return calls
 }
 
+// DeleteMetric calls DeleteMetricFunc.
+func (mock *mockStorage) DeleteMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+	if mock.DeleteMetricFunc == nil {
+		panic("mockStorage.DeleteMetricFunc: method is nil but storage.DeleteMetric was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+	}
+	mock.lockDeleteMetric.Lock()
+	mock.calls.DeleteMetric = append(mock.calls.DeleteMetric, callInfo)
+	mock.lockDeleteMetric.Unlock()
+	return mock.DeleteMetricFunc(ctx, metric)
+}
+
+// DeleteMetricCalls gets all the calls that were made to DeleteMetric.
+// Check the length with:
+//
+//	len(mockedstorage.DeleteMetricCalls())
+func (mock *mockStorage) DeleteMetricCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}
+	mock.lockDeleteMetric.RLock()
+	calls = mock.calls.DeleteMetric
+	mock.lockDeleteMetric.RUnlock()
+	return calls
+}
+
+// DeleteMetrics calls DeleteMetricsFunc.
+func (mock *mockStorage) DeleteMetrics(ctx context.Context, metrics []entities.Metric) ([]entities.Metric, error) {
+	if mock.DeleteMetricsFunc == nil {
+		panic("mockStorage.DeleteMetricsFunc: method is nil but storage.DeleteMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Metrics: metrics,
+	}
+	mock.lockDeleteMetrics.Lock()
+	mock.calls.DeleteMetrics = append(mock.calls.DeleteMetrics, callInfo)
+	mock.lockDeleteMetrics.Unlock()
+	return mock.DeleteMetricsFunc(ctx, metrics)
+}
+
+// DeleteMetricsCalls gets all the calls that were made to DeleteMetrics.
+// Check the length with:
+//
+//	len(mockedstorage.DeleteMetricsCalls())
+func (mock *mockStorage) DeleteMetricsCalls() []struct {
+	Ctx     context.Context
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Metrics []entities.Metric
+	}
+	mock.lockDeleteMetrics.RLock()
+	calls = mock.calls.DeleteMetrics
+	mock.lockDeleteMetrics.RUnlock()
+	return calls
+}
+
+// ExpireIdempotencyKeys calls ExpireIdempotencyKeysFunc.
+func (mock *mockStorage) ExpireIdempotencyKeys(ctx context.Context, before time.Time) error {
+	if mock.ExpireIdempotencyKeysFunc == nil {
+		panic("mockStorage.ExpireIdempotencyKeysFunc: method is nil but storage.ExpireIdempotencyKeys was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Before time.Time
+	}{
+		Ctx:    ctx,
+		Before: before,
+	}
+	mock.lockExpireIdempotencyKeys.Lock()
+	mock.calls.ExpireIdempotencyKeys = append(mock.calls.ExpireIdempotencyKeys, callInfo)
+	mock.lockExpireIdempotencyKeys.Unlock()
+	return mock.ExpireIdempotencyKeysFunc(ctx, before)
+}
+
+// ExpireIdempotencyKeysCalls gets all the calls that were made to ExpireIdempotencyKeys.
+// Check the length with:
+//
+//	len(mockedstorage.ExpireIdempotencyKeysCalls())
+func (mock *mockStorage) ExpireIdempotencyKeysCalls() []struct {
+	Ctx    context.Context
+	Before time.Time
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Before time.Time
+	}
+	mock.lockExpireIdempotencyKeys.RLock()
+	calls = mock.calls.ExpireIdempotencyKeys
+	mock.lockExpireIdempotencyKeys.RUnlock()
+	return calls
+}
+
+// FindMetrics calls FindMetricsFunc.
+func (mock *mockStorage) FindMetrics(ctx context.Context, filter entities.MetricFilter) ([]entities.Metric, error) {
+	if mock.FindMetricsFunc == nil {
+		panic("mockStorage.FindMetricsFunc: method is nil but storage.FindMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Filter entities.MetricFilter
+	}{
+		Ctx:    ctx,
+		Filter: filter,
+	}
+	mock.lockFindMetrics.Lock()
+	mock.calls.FindMetrics = append(mock.calls.FindMetrics, callInfo)
+	mock.lockFindMetrics.Unlock()
+	return mock.FindMetricsFunc(ctx, filter)
+}
+
+// FindMetricsCalls gets all the calls that were made to FindMetrics.
+// Check the length with:
+//
+//	len(mockedstorage.FindMetricsCalls())
+func (mock *mockStorage) FindMetricsCalls() []struct {
+	Ctx    context.Context
+	Filter entities.MetricFilter
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Filter entities.MetricFilter
+	}
+	mock.lockFindMetrics.RLock()
+	calls = mock.calls.FindMetrics
+	mock.lockFindMetrics.RUnlock()
+	return calls
+}
+
+// GetAgents calls GetAgentsFunc.
+func (mock *mockStorage) GetAgents(ctx context.Context) ([]entities.Agent, error) {
+	if mock.GetAgentsFunc == nil {
+		panic("mockStorage.GetAgentsFunc: method is nil but storage.GetAgents was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockGetAgents.Lock()
+	mock.calls.GetAgents = append(mock.calls.GetAgents, callInfo)
+	mock.lockGetAgents.Unlock()
+	return mock.GetAgentsFunc(ctx)
+}
+
+// GetAgentsCalls gets all the calls that were made to GetAgents.
+// Check the length with:
+//
+//	len(mockedstorage.GetAgentsCalls())
+func (mock *mockStorage) GetAgentsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockGetAgents.RLock()
+	calls = mock.calls.GetAgents
+	mock.lockGetAgents.RUnlock()
+	return calls
+}
+
 // GetMetric calls GetMetricFunc.
 func (mock *mockStorage) GetMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 	if mock.GetMetricFunc == nil {
@@ -184,23 +495,25 @@ func (mock *mockStorage) GetMetricCalls() []struct {
 }
 
 // GetMetricsByTypes calls GetMetricsByTypesFunc.
-func (mock *mockStorage) GetMetricsByTypes(ctx context.Context, gauge map[entities.MetricName]entities.Gauge, counter map[entities.MetricName]entities.Counter) error {
+func (mock *mockStorage) GetMetricsByTypes(ctx context.Context, gauge map[entities.MetricKey]entities.Gauge, counter map[entities.MetricKey]entities.Counter, histogram map[entities.MetricKey]entities.Histogram) error {
 	if mock.GetMetricsByTypesFunc == nil {
 		panic("mockStorage.GetMetricsByTypesFunc: method is nil but storage.GetMetricsByTypes was just called")
 	}
 	callInfo := struct {
-		Ctx     context.Context
-		Gauge   map[entities.MetricName]entities.Gauge
-		Counter map[entities.MetricName]entities.Counter
+		Ctx       context.Context
+		Gauge     map[entities.MetricKey]entities.Gauge
+		Counter   map[entities.MetricKey]entities.Counter
+		Histogram map[entities.MetricKey]entities.Histogram
 	}{
-		Ctx:     ctx,
-		Gauge:   gauge,
-		Counter: counter,
+		Ctx:       ctx,
+		Gauge:     gauge,
+		Counter:   counter,
+		Histogram: histogram,
 	}
 	mock.lockGetMetricsByTypes.Lock()
 	mock.calls.GetMetricsByTypes = append(mock.calls.GetMetricsByTypes, callInfo)
 	mock.lockGetMetricsByTypes.Unlock()
-	return mock.GetMetricsByTypesFunc(ctx, gauge, counter)
+	return mock.GetMetricsByTypesFunc(ctx, gauge, counter, histogram)
 }
 
 // GetMetricsByTypesCalls gets all the calls that were made to GetMetricsByTypes.
@@ -208,14 +521,16 @@ func (mock *mockStorage) GetMetricsByTypes(ctx context.Context, gauge map[entiti
 //
 //	len(mockedstorage.GetMetricsByTypesCalls())
 func (mock *mockStorage) GetMetricsByTypesCalls() []struct {
-	Ctx     context.Context
-	Gauge   map[entities.MetricName]entities.Gauge
-	Counter map[entities.MetricName]entities.Counter
+	Ctx       context.Context
+	Gauge     map[entities.MetricKey]entities.Gauge
+	Counter   map[entities.MetricKey]entities.Counter
+	Histogram map[entities.MetricKey]entities.Histogram
 } {
 	var calls []struct {
-		Ctx     context.Context
-		Gauge   map[entities.MetricName]entities.Gauge
-		Counter map[entities.MetricName]entities.Counter
+		Ctx       context.Context
+		Gauge     map[entities.MetricKey]entities.Gauge
+		Counter   map[entities.MetricKey]entities.Counter
+		Histogram map[entities.MetricKey]entities.Histogram
 	}
 	mock.lockGetMetricsByTypes.RLock()
 	calls = mock.calls.GetMetricsByTypes
@@ -223,6 +538,50 @@ func (mock *mockStorage) GetMetricsByTypesCalls() []struct {
 	return calls
 }
 
+// GetSamples calls GetSamplesFunc.
+func (mock *mockStorage) GetSamples(ctx context.Context, metric entities.Metric, from time.Time, to time.Time) ([]entities.Sample, error) {
+	if mock.GetSamplesFunc == nil {
+		panic("mockStorage.GetSamplesFunc: method is nil but storage.GetSamples was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+		From   time.Time
+		To     time.Time
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+		From:   from,
+		To:     to,
+	}
+	mock.lockGetSamples.Lock()
+	mock.calls.GetSamples = append(mock.calls.GetSamples, callInfo)
+	mock.lockGetSamples.Unlock()
+	return mock.GetSamplesFunc(ctx, metric, from, to)
+}
+
+// GetSamplesCalls gets all the calls that were made to GetSamples.
+// Check the length with:
+//
+//	len(mockedstorage.GetSamplesCalls())
+func (mock *mockStorage) GetSamplesCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+	From   time.Time
+	To     time.Time
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+		From   time.Time
+		To     time.Time
+	}
+	mock.lockGetSamples.RLock()
+	calls = mock.calls.GetSamples
+	mock.lockGetSamples.RUnlock()
+	return calls
+}
+
 // Ping calls PingFunc.
 func (mock *mockStorage) Ping(ctx context.Context) error {
 	if mock.PingFunc == nil {
@@ -255,6 +614,82 @@ func (mock *mockStorage) PingCalls() []struct {
 	return calls
 }
 
+// ResetCounter calls ResetCounterFunc.
+func (mock *mockStorage) ResetCounter(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
+	if mock.ResetCounterFunc == nil {
+		panic("mockStorage.ResetCounterFunc: method is nil but storage.ResetCounter was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}{
+		Ctx:    ctx,
+		Metric: metric,
+	}
+	mock.lockResetCounter.Lock()
+	mock.calls.ResetCounter = append(mock.calls.ResetCounter, callInfo)
+	mock.lockResetCounter.Unlock()
+	return mock.ResetCounterFunc(ctx, metric)
+}
+
+// ResetCounterCalls gets all the calls that were made to ResetCounter.
+// Check the length with:
+//
+//	len(mockedstorage.ResetCounterCalls())
+func (mock *mockStorage) ResetCounterCalls() []struct {
+	Ctx    context.Context
+	Metric entities.Metric
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Metric entities.Metric
+	}
+	mock.lockResetCounter.RLock()
+	calls = mock.calls.ResetCounter
+	mock.lockResetCounter.RUnlock()
+	return calls
+}
+
+// UpdateAgent calls UpdateAgentFunc.
+func (mock *mockStorage) UpdateAgent(ctx context.Context, agent entities.Agent, metrics []entities.Metric) error {
+	if mock.UpdateAgentFunc == nil {
+		panic("mockStorage.UpdateAgentFunc: method is nil but storage.UpdateAgent was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Agent   entities.Agent
+		Metrics []entities.Metric
+	}{
+		Ctx:     ctx,
+		Agent:   agent,
+		Metrics: metrics,
+	}
+	mock.lockUpdateAgent.Lock()
+	mock.calls.UpdateAgent = append(mock.calls.UpdateAgent, callInfo)
+	mock.lockUpdateAgent.Unlock()
+	return mock.UpdateAgentFunc(ctx, agent, metrics)
+}
+
+// UpdateAgentCalls gets all the calls that were made to UpdateAgent.
+// Check the length with:
+//
+//	len(mockedstorage.UpdateAgentCalls())
+func (mock *mockStorage) UpdateAgentCalls() []struct {
+	Ctx     context.Context
+	Agent   entities.Agent
+	Metrics []entities.Metric
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Agent   entities.Agent
+		Metrics []entities.Metric
+	}
+	mock.lockUpdateAgent.RLock()
+	calls = mock.calls.UpdateAgent
+	mock.lockUpdateAgent.RUnlock()
+	return calls
+}
+
 // UpdateMetric calls UpdateMetricFunc.
 func (mock *mockStorage) UpdateMetric(ctx context.Context, metric entities.Metric) (*entities.Metric, error) {
 	if mock.UpdateMetricFunc == nil {
@@ -326,3 +761,43 @@ func (mock *mockStorage) UpdateMetricsCalls() []struct {
 	mock.lockUpdateMetrics.RUnlock()
 	return calls
 }
+
+// UpdateMetricsOnce calls UpdateMetricsOnceFunc.
+func (mock *mockStorage) UpdateMetricsOnce(ctx context.Context, idempotency entities.Idempotency, metrics []entities.Metric) ([]entities.Metric, bool, error) {
+	if mock.UpdateMetricsOnceFunc == nil {
+		panic("mockStorage.UpdateMetricsOnceFunc: method is nil but storage.UpdateMetricsOnce was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Idempotency entities.Idempotency
+		Metrics     []entities.Metric
+	}{
+		Ctx:         ctx,
+		Idempotency: idempotency,
+		Metrics:     metrics,
+	}
+	mock.lockUpdateMetricsOnce.Lock()
+	mock.calls.UpdateMetricsOnce = append(mock.calls.UpdateMetricsOnce, callInfo)
+	mock.lockUpdateMetricsOnce.Unlock()
+	return mock.UpdateMetricsOnceFunc(ctx, idempotency, metrics)
+}
+
+// UpdateMetricsOnceCalls gets all the calls that were made to UpdateMetricsOnce.
+// Check the length with:
+//
+//	len(mockedstorage.UpdateMetricsOnceCalls())
+func (mock *mockStorage) UpdateMetricsOnceCalls() []struct {
+	Ctx         context.Context
+	Idempotency entities.Idempotency
+	Metrics     []entities.Metric
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Idempotency entities.Idempotency
+		Metrics     []entities.Metric
+	}
+	mock.lockUpdateMetricsOnce.RLock()
+	calls = mock.calls.UpdateMetricsOnce
+	mock.lockUpdateMetricsOnce.RUnlock()
+	return calls
+}
@@ -0,0 +1,60 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+)
+
+// WithStaleAfter enables stale metric detection: metric is stale if it was not
+// updated within staleAfter
+func (m *MetricsUsecase) WithStaleAfter(staleAfter time.Duration) *MetricsUsecase {
+	m.staleAfter = staleAfter
+	return m
+}
+
+// ListStaleMetrics returns metrics selected by filter, which were not updated
+// within configured interval before now, in the filter order; sorting and
+// pagination are ignored. Empty if stale detection is disabled.
+func (m *MetricsUsecase) ListStaleMetrics(ctx context.Context, filter entities.MetricFilter,
+	now time.Time,
+) ([]entities.StaleMetric, error) {
+	if m.staleAfter <= 0 {
+		return []entities.StaleMetric{}, nil
+	}
+	metrics, err := m.storage.FindMetrics(ctx, entities.MetricFilter{
+		Types:      filter.Types,
+		NamePrefix: filter.NamePrefix,
+		NameRegexp: filter.NameRegexp,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.StaleMetrics(metrics, now), nil
+}
+
+// StaleMetrics returns metrics from the given ones, which were not updated
+// within configured interval before now, keeping their order. Metrics with
+// unknown update time are treated as updated at the server start. Empty if
+// stale detection is disabled.
+func (m *MetricsUsecase) StaleMetrics(metrics []entities.Metric, now time.Time,
+) []entities.StaleMetric {
+	result := []entities.StaleMetric{}
+	if m.staleAfter <= 0 {
+		return result
+	}
+	for _, metric := range metrics {
+		since := metric.UpdatedAt
+		if since.IsZero() {
+			since = m.started
+		}
+		if now.Sub(since) > m.staleAfter {
+			result = append(result, entities.StaleMetric{
+				Metric:     metric,
+				LastUpdate: metric.UpdatedAt,
+			})
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListStaleMetrics(t *testing.T) {
+	usecase := NewMetricsUsecase(nil).WithStaleAfter(time.Minute)
+	now := usecase.started.Add(2 * time.Minute)
+	fresh := entities.Metric{Type: entities.MetricTypeGauge, Name: "Fresh", Value: 1,
+		UpdatedAt: now.Add(-time.Second)}
+	frozen := entities.Metric{Type: entities.MetricTypeGauge, Name: "Frozen", Value: 2,
+		UpdatedAt: now.Add(-2 * time.Minute)}
+	restored := entities.Metric{Type: entities.MetricTypeCounter, Name: "Restored", Delta: 3}
+	usecase.storage = &mockStorage{
+		FindMetricsFunc: func(ctx context.Context, filter entities.MetricFilter,
+		) ([]entities.Metric, error) {
+			assert.Equal(t, []entities.MetricType{entities.MetricTypeGauge,
+				entities.MetricTypeCounter}, filter.Types)
+			assert.Zero(t, filter.Limit)
+			return []entities.Metric{fresh, frozen, restored}, nil
+		},
+	}
+	filter := entities.MetricFilter{
+		Types: []entities.MetricType{entities.MetricTypeGauge, entities.MetricTypeCounter},
+		Limit: 1,
+	}
+
+	// nothing is stale right after start
+	stale, err := usecase.ListStaleMetrics(context.Background(), filter,
+		usecase.started)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+
+	// metric with unknown update time is treated as updated at the start
+	stale, err = usecase.ListStaleMetrics(context.Background(), filter, now)
+	require.NoError(t, err)
+	assert.Equal(t, []entities.StaleMetric{
+		{Metric: frozen, LastUpdate: frozen.UpdatedAt},
+		{Metric: restored},
+	}, stale)
+}
+
+func TestStaleMetrics(t *testing.T) {
+	usecase := NewMetricsUsecase(&mockStorage{}).WithStaleAfter(time.Minute)
+	now := usecase.started.Add(time.Hour)
+	fresh := entities.Metric{Type: entities.MetricTypeGauge, Name: "Fresh",
+		UpdatedAt: now.Add(-time.Minute)}
+	frozen := entities.Metric{Type: entities.MetricTypeGauge, Name: "Frozen",
+		UpdatedAt: now.Add(-time.Minute - time.Second)}
+
+	stale := usecase.StaleMetrics([]entities.Metric{fresh, frozen}, now)
+	assert.Equal(t, []entities.StaleMetric{
+		{Metric: frozen, LastUpdate: frozen.UpdatedAt},
+	}, stale)
+}
+
+func TestListStaleMetricsDisabled(t *testing.T) {
+	usecase := NewMetricsUsecase(&mockStorage{})
+	stale, err := usecase.ListStaleMetrics(context.Background(), entities.MetricFilter{},
+		time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+	assert.Empty(t, usecase.StaleMetrics([]entities.Metric{{}}, time.Now().Add(time.Hour)))
+}
@@ -0,0 +1,11 @@
+-- +goose Up
+create table histogram (
+	name text not null primary key,
+	bounds double precision[] not null,
+	counts bigint[] not null,
+	count bigint not null,
+	sum double precision not null
+);
+
+-- +goose Down
+drop table histogram;
@@ -0,0 +1,28 @@
+-- +goose Up
+alter table gauge add column labels text not null default '';
+alter table gauge drop constraint gauge_pkey;
+alter table gauge add primary key (name, labels);
+
+alter table counter add column labels text not null default '';
+alter table counter drop constraint counter_pkey;
+alter table counter add primary key (name, labels);
+
+alter table histogram add column labels text not null default '';
+alter table histogram drop constraint histogram_pkey;
+alter table histogram add primary key (name, labels);
+
+-- +goose Down
+delete from gauge where labels <> '';
+alter table gauge drop constraint gauge_pkey;
+alter table gauge drop column labels;
+alter table gauge add primary key (name);
+
+delete from counter where labels <> '';
+alter table counter drop constraint counter_pkey;
+alter table counter drop column labels;
+alter table counter add primary key (name);
+
+delete from histogram where labels <> '';
+alter table histogram drop constraint histogram_pkey;
+alter table histogram drop column labels;
+alter table histogram add primary key (name);
@@ -0,0 +1,18 @@
+-- +goose Up
+create table agent (
+	id text not null primary key,
+	hostname text not null,
+	last_seen timestamptz not null
+);
+
+create table agent_metric (
+	agent_id text not null references agent (id) on delete cascade,
+	type text not null,
+	name text not null,
+	labels text not null default '',
+	primary key (agent_id, type, name, labels)
+);
+
+-- +goose Down
+drop table agent_metric;
+drop table agent;
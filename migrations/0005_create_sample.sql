@@ -0,0 +1,13 @@
+-- +goose Up
+create table sample (
+	ts timestamptz not null default now(),
+	type text not null,
+	name text not null,
+	labels text not null default '',
+	value double precision not null
+);
+
+create index sample_metric_ts_idx on sample (type, name, labels, ts);
+
+-- +goose Down
+drop table sample;
@@ -0,0 +1,24 @@
+-- +goose Up
+alter table sample add column delta double precision not null default 0;
+
+create table sample_aggregate (
+	resolution integer not null, -- interval in seconds: 60 or 3600
+	ts timestamptz not null,     -- beginning of the interval
+	type text not null,
+	name text not null,
+	labels text not null default '',
+	min double precision not null,
+	max double precision not null,
+	avg double precision not null,
+	last double precision not null,
+	sum double precision not null,
+	count bigint not null,
+	primary key (resolution, type, name, labels, ts)
+);
+
+create index sample_ts_idx on sample (ts);
+
+-- +goose Down
+drop index sample_ts_idx;
+drop table sample_aggregate;
+alter table sample drop column delta;
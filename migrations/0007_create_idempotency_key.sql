@@ -0,0 +1,13 @@
+-- +goose Up
+create table idempotency_key (
+	key text not null primary key,
+	fingerprint text not null, -- hash of the batch content
+	result jsonb,              -- updated metrics, null until the batch is applied
+	created timestamptz not null default now()
+);
+
+create index idempotency_key_created_idx on idempotency_key (created);
+
+-- +goose Down
+drop index idempotency_key_created_idx;
+drop table idempotency_key;
@@ -0,0 +1,9 @@
+-- +goose Up
+alter table gauge add column updated_at timestamptz not null default now();
+alter table counter add column updated_at timestamptz not null default now();
+alter table histogram add column updated_at timestamptz not null default now();
+
+-- +goose Down
+alter table gauge drop column updated_at;
+alter table counter drop column updated_at;
+alter table histogram drop column updated_at;
@@ -0,0 +1,442 @@
+// Package client позволяет сервисам на Go отправлять метрики на сервер сбора
+// метрик напрямую, без запуска агента рядом с сервисом.
+//
+// Запросы отправляются в JSON API сервера так же, как это делает агент:
+// тело сжимается gzip, шифруется публичным ключом сервера (если он задан, см.
+// WithCryptoKey) и подписывается HMAC-SHA256 в заголовке HashSHA256 (если
+// задан ключ, см. WithKey и WithKeyID); подпись ответа сервера при этом
+// проверяется.
+// Запросы повторяются при сетевых ошибках и статусах 502, 503 и 504;
+// обновления метрик, в том числе одиночные, передаются пакетом с ключом
+// идемпотентности, поэтому повтор не применяет их повторно.
+//
+//	c, err := client.New("localhost:8080", client.WithKey(key))
+//	if err != nil {
+//		return err
+//	}
+//	_, err = c.Counter(ctx, "requests", 1, map[string]string{"handler": "/login"})
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	httpretry "github.com/PiskarevSA/go-advanced/internal/app/agent/workers/http_retry"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/PiskarevSA/go-advanced/internal/middleware"
+	rsamiddleware "github.com/PiskarevSA/go-advanced/internal/middleware/rsa"
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+const defaultTimeout = 15 * time.Second
+
+// ErrNotFound возвращается, если запрошенной метрики нет на сервере
+var ErrNotFound = errors.New("metric not found")
+
+// ErrInvalidSignature возвращается, если подпись ответа сервера отсутствует
+// или не совпадает с ключом клиента
+var ErrInvalidSignature = errors.New("invalid response signature")
+
+// StatusError возвращается, если сервер ответил статусом, отличным от 200 OK
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string // тело ответа сервера
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("%v %v returns %v %v", e.Method, e.URL, e.StatusCode,
+			http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%v %v returns %v %v: %v", e.Method, e.URL, e.StatusCode,
+		http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is позволяет проверить ответ 404 Not Found с помощью errors.Is(err, ErrNotFound)
+func (e *StatusError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Client отправляет метрики на сервер; безопасен для одновременного
+// использования из нескольких горутин
+type Client struct {
+	baseURL    string
+	keys       *keyring.Keyring // ключ подписи HashSHA256, запросы не подписываются, если его нет
+	realIP     string           // значение заголовка X-Real-IP, не передается, если пусто
+	httpClient *http.Client
+	encoder    func(*http.Request) error // шифрует тело запроса, если не nil
+}
+
+// Option задает необязательный параметр клиента
+type Option func(*Client) error
+
+// WithKey задает ключ, которым подписываются запросы, как у агента и сервера
+// (флаг -k, переменная окружения KEY)
+func WithKey(key string) Option {
+	return WithKeyID("", key)
+}
+
+// WithKeyID задает ключ с идентификатором из файла ключей сервера (флаг
+// -key-file, переменная окружения KEY_FILE); идентификатор передается в
+// заголовке KeyID, чтобы сервер с несколькими ключами выбрал нужный
+func WithKeyID(id string, key string) Option {
+	return func(c *Client) error {
+		c.keys = keyring.New(keyring.Key{ID: id, Secret: key})
+		return nil
+	}
+}
+
+// WithCryptoKey задает путь к файлу с публичным ключом сервера, которым
+// шифруются запросы (флаг -crypto-key, переменная окружения CRYPTO_KEY)
+func WithCryptoKey(pubKeyPath string) Option {
+	return func(c *Client) error {
+		if len(pubKeyPath) == 0 {
+			return nil
+		}
+		encoder, err := rsamiddleware.Encoder(pubKeyPath)
+		if err != nil {
+			return fmt.Errorf("rsa encoder: %w", err)
+		}
+		c.encoder = encoder
+		return nil
+	}
+}
+
+// WithRealIP задает значение заголовка X-Real-IP, которое сервер проверяет
+// на принадлежность доверенной подсети
+func WithRealIP(ip string) Option {
+	return func(c *Client) error {
+		c.realIP = ip
+		return nil
+	}
+}
+
+// WithTimeout задает общее время выполнения запроса с учетом повторов; по
+// умолчанию 15 секунд
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.httpClient.Timeout = timeout
+		return nil
+	}
+}
+
+// WithHTTPClient заменяет HTTP-клиент, например, чтобы отключить повторы
+// запросов или настроить TLS
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("http client expected")
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// New создает клиента сервера с адресом address, например, localhost:8080
+// или http://localhost:8080
+func New(address string, options ...Option) (*Client, error) {
+	if len(address) == 0 {
+		return nil, errors.New("server address expected")
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	c := &Client{
+		baseURL: strings.TrimSuffix(address, "/"),
+		keys:    keyring.New(),
+		httpClient: &http.Client{
+			Timeout:   defaultTimeout,
+			Transport: httpretry.NewRetryableTransport(),
+		},
+	}
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Gauge устанавливает значение метрики типа Gauge
+func (c *Client) Gauge(ctx context.Context, name string, value float64,
+	labels map[string]string,
+) error {
+	_, err := c.update(ctx, GaugeMetric(name, value, labels))
+	return err
+}
+
+// Counter увеличивает метрику типа Counter на delta и возвращает её
+// аккумулированное значение
+func (c *Client) Counter(ctx context.Context, name string, delta int64,
+	labels map[string]string,
+) (int64, error) {
+	result, err := c.update(ctx, CounterMetric(name, delta, labels))
+	if err != nil {
+		return 0, err
+	}
+	return result.Delta, nil
+}
+
+// Batch отправляет пакет метрик одним запросом и возвращает их значения
+// после обновления. Пакет передается с ключом идемпотентности, поэтому
+// повтор запроса после сетевой ошибки не применяет пакет повторно
+func (c *Client) Batch(ctx context.Context, metrics []Metric) ([]Metric, error) {
+	if len(metrics) == 0 {
+		return nil, nil
+	}
+	request := make([]models.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		model, err := m.model()
+		if err != nil {
+			return nil, err
+		}
+		request = append(request, model)
+	}
+	idempotencyKey, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var response []models.Metric
+	header := http.Header{}
+	header.Set(models.IdempotencyKeyHeader, idempotencyKey)
+	if err := c.do(ctx, http.MethodPost, "/updates/", nil, header, request,
+		&response); err != nil {
+		return nil, err
+	}
+	result := make([]Metric, 0, len(response))
+	for _, m := range response {
+		result = append(result, metricFromModel(m))
+	}
+	return result, nil
+}
+
+// Get возвращает текущее значение метрики; если метрики нет на сервере,
+// возвращается ошибка, удовлетворяющая errors.Is(err, ErrNotFound)
+func (c *Client) Get(ctx context.Context, metricType MetricType, name string,
+	labels map[string]string,
+) (Metric, error) {
+	request := models.Metric{
+		ID:     name,
+		MType:  string(metricType),
+		Labels: labels,
+	}
+	var response models.Metric
+	if err := c.do(ctx, http.MethodPost, "/value/", nil, nil, request,
+		&response); err != nil {
+		return Metric{}, err
+	}
+	return metricFromModel(response), nil
+}
+
+// Filter задает условия отбора метрик в Find; пустой фильтр отбирает все
+// метрики
+type Filter struct {
+	Types      []MetricType // типы метрик, любые, если не заданы
+	Prefix     string       // префикс имени метрики
+	Regex      string       // регулярное выражение для имени метрики
+	Sort       string       // сортировка: type (по умолчанию), name или value
+	Descending bool         // сортировка по убыванию
+	Limit      int          // максимальное количество метрик, без ограничения, если 0
+	Offset     int          // количество пропускаемых метрик
+}
+
+func (f Filter) query() url.Values {
+	query := url.Values{}
+	for _, t := range f.Types {
+		query.Add("type", string(t))
+	}
+	if len(f.Prefix) > 0 {
+		query.Set("prefix", f.Prefix)
+	}
+	if len(f.Regex) > 0 {
+		query.Set("regex", f.Regex)
+	}
+	if len(f.Sort) > 0 {
+		query.Set("sort", f.Sort)
+	}
+	if f.Descending {
+		query.Set("order", "desc")
+	}
+	if f.Limit > 0 {
+		query.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset > 0 {
+		query.Set("offset", strconv.Itoa(f.Offset))
+	}
+	return query
+}
+
+// Find возвращает текущие значения метрик, отобранных фильтром
+func (c *Client) Find(ctx context.Context, filter Filter) ([]Metric, error) {
+	var response []models.Metric
+	if err := c.do(ctx, http.MethodGet, "/values", filter.query(), nil, nil,
+		&response); err != nil {
+		return nil, err
+	}
+	result := make([]Metric, 0, len(response))
+	for _, m := range response {
+		result = append(result, metricFromModel(m))
+	}
+	return result, nil
+}
+
+// Ping проверяет доступность сервера и его хранилища
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/ping", nil, nil, nil, nil)
+}
+
+// update отправляет метрику пакетом из одного элемента, так как у /update/
+// нет ключа идемпотентности и повтор запроса учел бы приращение дважды
+func (c *Client) update(ctx context.Context, metric Metric) (Metric, error) {
+	result, err := c.Batch(ctx, []Metric{metric})
+	if err != nil {
+		return Metric{}, err
+	}
+	if len(result) != 1 {
+		return Metric{}, fmt.Errorf("unexpected response: %v metrics instead of 1",
+			len(result))
+	}
+	return result[0], nil
+}
+
+// do отправляет запрос с телом request в JSON-формате, если он не nil, и
+// декодирует ответ в response, если он не nil
+func (c *Client) do(ctx context.Context, method string, path string,
+	query url.Values, header http.Header, request any, response any,
+) error {
+	var body []byte
+	if request != nil {
+		plain, err := json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(): %w", err)
+		}
+		if body, err = compress(plain); err != nil {
+			return err
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bodyReader)
+	if err != nil {
+		return fmt.Errorf("http.NewRequest(): %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	// ответ распаковывается клиентом, а не http.Transport, чтобы подпись
+	// проверялась по полученным байтам
+	req.Header.Set("Accept-Encoding", "gzip")
+	if len(c.realIP) > 0 {
+		req.Header.Set(middleware.RealIPHeader, c.realIP)
+	}
+	if c.encoder != nil && body != nil {
+		if err := c.encoder(req); err != nil {
+			return fmt.Errorf("rsa encode: %w", err)
+		}
+	}
+	// подпись покрывает тело в том виде, в котором оно передается, то есть
+	// сжатое и зашифрованное; запрос без тела подписывается как пустое тело
+	key, signed := c.keys.Primary()
+	if signed {
+		if err := middleware.SignRequest(req, key); err != nil {
+			return fmt.Errorf("sign: %w", err)
+		}
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("httpClient.Do(): %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("io.ReadAll(): %w", err)
+	}
+	// ответы 5xx может возвращать прокси, который их не подписывает
+	if signed && res.StatusCode < http.StatusInternalServerError {
+		if err := middleware.VerifyResponse(res, resBody, c.keys); err != nil {
+			return fmt.Errorf("%w: %v %v returns %v: %w", ErrInvalidSignature,
+				method, target, res.Status, err)
+		}
+	}
+	if res.Header.Get("Content-Encoding") == "gzip" {
+		if resBody, err = decompress(resBody); err != nil {
+			return err
+		}
+	}
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{
+			Method:     method,
+			URL:        target,
+			StatusCode: res.StatusCode,
+			Message:    strings.TrimSpace(string(resBody)),
+		}
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resBody, response); err != nil {
+		return fmt.Errorf("json.Unmarshal(): %w", err)
+	}
+	return nil
+}
+
+func compress(body []byte) ([]byte, error) {
+	buffer := bytes.NewBuffer(nil)
+	gzipWriter := gzip.NewWriter(buffer)
+	if _, err := gzipWriter.Write(body); err != nil {
+		return nil, fmt.Errorf("gzipWriter.Write(): %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("gzipWriter.Close(): %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func decompress(body []byte) ([]byte, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("gzip.NewReader(): %w", err)
+	}
+	defer gzipReader.Close()
+	result, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return nil, fmt.Errorf("gzipReader.Read(): %w", err)
+	}
+	return result, nil
+}
+
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("rand.Read(): %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
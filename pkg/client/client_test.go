@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PiskarevSA/go-advanced/internal/handlers"
+	"github.com/PiskarevSA/go-advanced/internal/keyring"
+	"github.com/PiskarevSA/go-advanced/internal/middleware"
+	rsamiddleware "github.com/PiskarevSA/go-advanced/internal/middleware/rsa"
+	"github.com/PiskarevSA/go-advanced/internal/storage/memstorage"
+	"github.com/PiskarevSA/go-advanced/internal/usecases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeys generates rsa key pair and stores it in temporary directory
+func writeKeys(t *testing.T) (privKeyPath string, pubKeyPath string) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	privKeyPath = filepath.Join(dir, "private.pem")
+	privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(privKeyPath, pem.EncodeToMemory(
+		&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}), 0o600))
+
+	pubKeyPath = filepath.Join(dir, "public.pem")
+	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(pubKeyPath, pem.EncodeToMemory(
+		&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0o600))
+
+	return privKeyPath, pubKeyPath
+}
+
+// newServer starts server with the same middlewares as cmd/server
+func newServer(t *testing.T, keys []keyring.Key, privKeyPath string) *httptest.Server {
+	middlewares := []func(http.Handler) http.Handler{
+		middleware.IntegrityKeyring(keyring.New(keys...)),
+	}
+	if len(privKeyPath) > 0 {
+		decoder, err := rsamiddleware.Decoder(privKeyPath)
+		require.NoError(t, err)
+		middlewares = append(middlewares, decoder)
+	}
+	middlewares = append(middlewares, middleware.Encoding)
+
+	usecase := usecases.NewMetricsUsecase(memstorage.New()).
+		WithIdempotencyTTL(time.Hour)
+	r := handlers.NewMetricsRouter(usecase).
+		WithMiddlewares(middlewares...).
+		WithAllHandlers()
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClient(t *testing.T) {
+	privKeyPath, pubKeyPath := writeKeys(t)
+	tests := []struct {
+		name          string
+		serverKeys    []keyring.Key
+		serverPrivKey string
+		options       []Option
+	}{
+		{
+			name: "plain",
+		},
+		{
+			name:       "signed",
+			serverKeys: []keyring.Key{{Secret: "secret"}},
+			options:    []Option{WithKey("secret")},
+		},
+		{
+			name:       "signed with previous key of rotation",
+			serverKeys: []keyring.Key{{ID: "2", Secret: "new"}, {ID: "1", Secret: "old"}},
+			options:    []Option{WithKeyID("1", "old")},
+		},
+		{
+			name:          "signed and encrypted",
+			serverKeys:    []keyring.Key{{Secret: "secret"}},
+			serverPrivKey: privKeyPath,
+			options:       []Option{WithKey("secret"), WithCryptoKey(pubKeyPath)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newServer(t, tt.serverKeys, tt.serverPrivKey)
+			c, err := New(ts.URL, tt.options...)
+			require.NoError(t, err)
+			ctx := context.Background()
+			labels := map[string]string{"service": "billing"}
+
+			require.NoError(t, c.Gauge(ctx, "Temperature", 36.6, labels))
+			total, err := c.Counter(ctx, "Requests", 2, labels)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), total)
+
+			updated, err := c.Batch(ctx, []Metric{
+				CounterMetric("Requests", 3, labels),
+				{
+					Name: "Latency",
+					Type: Histogram,
+					Histogram: &HistogramValue{
+						Buckets: []float64{0.1, 1},
+						Counts:  []uint64{1, 0, 1},
+						Count:   2,
+						Sum:     5.05,
+					},
+				},
+			})
+			require.NoError(t, err)
+			require.Len(t, updated, 2)
+			assert.Equal(t, int64(5), updated[0].Delta)
+			require.NotNil(t, updated[1].Histogram)
+			assert.Equal(t, uint64(2), updated[1].Histogram.Count)
+
+			gauge, err := c.Get(ctx, Gauge, "Temperature", labels)
+			require.NoError(t, err)
+			assert.Equal(t, GaugeMetric("Temperature", 36.6, labels), gauge)
+
+			_, err = c.Get(ctx, Gauge, "Temperature", nil)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			found, err := c.Find(ctx, Filter{Types: []MetricType{Counter, Gauge}, Sort: "name"})
+			require.NoError(t, err)
+			assert.Equal(t, []Metric{
+				CounterMetric("Requests", 5, labels),
+				GaugeMetric("Temperature", 36.6, labels),
+			}, found)
+
+			assert.NoError(t, c.Ping(ctx))
+		})
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	ts := newServer(t, []keyring.Key{{Secret: "secret"}}, "")
+	ctx := context.Background()
+
+	t.Run("unsigned request", func(t *testing.T) {
+		c, err := New(ts.URL)
+		require.NoError(t, err)
+
+		err = c.Gauge(ctx, "Temperature", 36.6, nil)
+		var statusError *StatusError
+		require.ErrorAs(t, err, &statusError)
+		assert.Equal(t, http.StatusBadRequest, statusError.StatusCode)
+		assert.NotErrorIs(t, err, ErrNotFound)
+	})
+	t.Run("wrong key", func(t *testing.T) {
+		c, err := New(ts.URL, WithKey("wrong"))
+		require.NoError(t, err)
+
+		err = c.Gauge(ctx, "Temperature", 36.6, nil)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+		err = c.Ping(ctx)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+	t.Run("invalid metric type", func(t *testing.T) {
+		c, err := New(ts.URL, WithKey("secret"))
+		require.NoError(t, err)
+
+		_, err = c.Batch(ctx, []Metric{{Name: "Temperature", Type: "summary"}})
+		require.Error(t, err)
+	})
+	t.Run("missing crypto key", func(t *testing.T) {
+		_, err := New(ts.URL, WithCryptoKey(filepath.Join(t.TempDir(), "missing.pem")))
+		require.Error(t, err)
+	})
+	t.Run("empty address", func(t *testing.T) {
+		_, err := New("")
+		require.Error(t, err)
+	})
+}
+
+// the response to the update is lost, so the client retries the request; the
+// increment must be applied once
+func TestClient_CounterRetry(t *testing.T) {
+	ts := newServer(t, nil, "")
+	target, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	var requests atomic.Int32
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = func(res *http.Response) error {
+		if requests.Add(1) == 1 {
+			res.StatusCode = http.StatusServiceUnavailable
+		}
+		return nil
+	}
+	ps := httptest.NewServer(proxy)
+	t.Cleanup(ps.Close)
+
+	c, err := New(ps.URL)
+	require.NoError(t, err)
+	total, err := c.Counter(context.Background(), "Requests", 2, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, int32(2), requests.Load())
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/PiskarevSA/go-advanced/internal/models"
+)
+
+// MetricType — тип метрики
+type MetricType string
+
+const (
+	Gauge     MetricType = "gauge"
+	Counter   MetricType = "counter"
+	Histogram MetricType = "histogram"
+)
+
+// Metric описывает метрику, отправляемую на сервер или полученную от него.
+//
+// Примечание: в ответе от сервера в Delta и Histogram передается
+// аккумулированное значение
+type Metric struct {
+	Name      string            // имя метрики
+	Type      MetricType        // тип метрики
+	Labels    map[string]string // необязательные метки; входят в идентификатор метрики
+	Value     float64           // значение метрики типа Gauge
+	Delta     int64             // значение метрики типа Counter
+	Histogram *HistogramValue   // значение метрики типа Histogram
+}
+
+// HistogramValue описывает значение метрики типа Histogram
+type HistogramValue struct {
+	Buckets []float64 // верхние границы корзин
+	Counts  []uint64  // количество наблюдений в каждой корзине и в +Inf
+	Count   uint64    // общее количество наблюдений
+	Sum     float64   // сумма наблюдений
+}
+
+// GaugeMetric возвращает метрику типа Gauge
+func GaugeMetric(name string, value float64, labels map[string]string) Metric {
+	return Metric{Name: name, Type: Gauge, Labels: labels, Value: value}
+}
+
+// CounterMetric возвращает метрику типа Counter
+func CounterMetric(name string, delta int64, labels map[string]string) Metric {
+	return Metric{Name: name, Type: Counter, Labels: labels, Delta: delta}
+}
+
+// MarshalJSON кодирует метрику в формате JSON API сервера, например,
+// {"id":"Requests","type":"counter","delta":1}
+func (m Metric) MarshalJSON() ([]byte, error) {
+	model, err := m.model()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(model)
+}
+
+// UnmarshalJSON декодирует метрику в формате JSON API сервера
+func (m *Metric) UnmarshalJSON(data []byte) error {
+	var model models.Metric
+	if err := json.Unmarshal(data, &model); err != nil {
+		return err
+	}
+	*m = metricFromModel(model)
+	return nil
+}
+
+func (m Metric) model() (models.Metric, error) {
+	result := models.Metric{
+		ID:     m.Name,
+		MType:  string(m.Type),
+		Labels: m.Labels,
+	}
+	switch m.Type {
+	case Gauge:
+		result.Value = &m.Value
+	case Counter:
+		result.Delta = &m.Delta
+	case Histogram:
+		if m.Histogram == nil {
+			return result, fmt.Errorf("histogram %v: value expected", m.Name)
+		}
+		result.Buckets = m.Histogram.Buckets
+		result.Counts = m.Histogram.Counts
+		result.Count = &m.Histogram.Count
+		result.Sum = &m.Histogram.Sum
+	default:
+		return result, fmt.Errorf("metric %v: unexpected type %q", m.Name, m.Type)
+	}
+	return result, nil
+}
+
+func metricFromModel(m models.Metric) Metric {
+	result := Metric{
+		Name:   m.ID,
+		Type:   MetricType(m.MType),
+		Labels: m.Labels,
+	}
+	if m.Value != nil {
+		result.Value = *m.Value
+	}
+	if m.Delta != nil {
+		result.Delta = *m.Delta
+	}
+	if result.Type == Histogram {
+		result.Histogram = &HistogramValue{
+			Buckets: m.Buckets,
+			Counts:  m.Counts,
+		}
+		if m.Count != nil {
+			result.Histogram.Count = *m.Count
+		}
+		if m.Sum != nil {
+			result.Histogram.Sum = *m.Sum
+		}
+	}
+	return result
+}
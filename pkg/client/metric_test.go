@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetric_JSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric Metric
+		json   string
+	}{
+		{
+			name:   "gauge",
+			metric: GaugeMetric("Temperature", 36.6, nil),
+			json:   `{"id":"Temperature","type":"gauge","value":36.6}`,
+		},
+		{
+			name:   "counter with labels",
+			metric: CounterMetric("Requests", 1, map[string]string{"path": "/"}),
+			json:   `{"id":"Requests","type":"counter","labels":{"path":"/"},"delta":1}`,
+		},
+		{
+			name: "histogram",
+			metric: Metric{
+				Name: "Latency",
+				Type: Histogram,
+				Histogram: &HistogramValue{
+					Buckets: []float64{1},
+					Counts:  []uint64{1, 0},
+					Count:   1,
+					Sum:     0.5,
+				},
+			},
+			json: `{"id":"Latency","type":"histogram","buckets":[1],"counts":[1,0],"count":1,"sum":0.5}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.metric)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.json, string(data))
+
+			var metric Metric
+			require.NoError(t, json.Unmarshal([]byte(tt.json), &metric))
+			assert.Equal(t, tt.metric, metric)
+		})
+	}
+}
+
+func TestMetric_JSONInvalidType(t *testing.T) {
+	_, err := json.Marshal(Metric{Name: "Temperature", Type: "summary"})
+	assert.Error(t, err)
+}